@@ -63,24 +63,47 @@ func main() {
 	if err != nil {
 		log.Fatal().Err(err).Msg("cannot read config")
 	}
-	utils.SetSentryDSN(cfg.Get().SentryDSN)
+	utils.SetSentryDSN(cfg.Get().GetSentryDSN())
+	utils.SetSentryOptions(cfg.Get().Sentry.SampleRate, cfg.Get().Sentry.TracesSampleRate, cfg.Get().Sentry.Environment)
+	utils.SetMaxConcurrentRequests(cfg.Get().Workers.Requests)
+	utils.SetContact(cfg.Get().Public.Contact, cfg.Get().Public.From)
 	log = zerolog.Ctx(utils.NewContext())
+	if err := utils.SetTLS(cfg.Get().TLS.CABundle, cfg.Get().TLS.InsecureSkipVerify); err != nil {
+		log.Fatal().Err(err).Msg("cannot configure TLS")
+	}
+	if cfg.Get().TLS.InsecureSkipVerify {
+		log.Warn().Msg("TLS verification of outbound requests is disabled, NEVER use it in production")
+	}
+	if err := utils.SetProxy(cfg.Get().Proxy.URL, cfg.Get().Proxy.Fallback, cfg.Get().Proxy.Direct); err != nil {
+		log.Fatal().Err(err).Msg("cannot configure outbound proxy")
+	}
+	utils.CheckProxy(utils.NewContext())
 
-	dataRepo, err = data.New(cfg.Get().Path.Data)
+	dataRepo, err = data.New(cfg.Get().Path.Data, cfg.Get().Batch.Data, time.Duration(cfg.Get().Batch.DataInterval)*time.Second, cfg.Get().Cache.Servers)
 	if err != nil {
 		log.Fatal().Err(err).Msg("cannot open data repo")
 	}
 
 	detector := getLanguageDetector(cfg.Get().Languages)
-	index, err = search.NewIndex(cfg.Get().Path.Index, detector, "en")
+	search.Register(detector, "en", cfg.Get().Search.FoldAccents)
+	if snapshot := cfg.Get().Path.Snapshot; snapshot != "" {
+		if _, err := os.Stat(snapshot); err == nil {
+			if err := search.LoadSnapshot(utils.NewContext(), cfg.Get().Path.Index, snapshot); err != nil {
+				log.Fatal().Err(err).Msg("cannot load index snapshot")
+			}
+		}
+	}
+
+	index, err = search.NewIndex(cfg.Get().Path.Index, detector, "en", cfg.Get().Search.FoldAccents)
 	if err != nil {
 		log.Fatal().Err(err).Msg("cannot open index repo")
 	}
 	robotsSvc := services.NewRobots()
-	blockSvc := services.NewBlocklist(cfg)
+	blockSvc := services.NewBlocklist(cfg, dataRepo, index)
 	statsSvc := services.NewStats(cfg, dataRepo, index, blockSvc)
 	indexSvc := services.NewIndex(cfg, index)
 	searchSvc := services.NewSearch(cfg, dataRepo, index, blockSvc, statsSvc)
+	blockSvc.SetOnRemove(searchSvc.Purge)
 	matrixSvc, err := matrix.NewServer(cfg, dataRepo, searchSvc)
 	if err != nil {
 		log.Fatal().Err(err).Msg("cannot start matrix service")
@@ -89,16 +112,17 @@ func main() {
 	crawlerSvc := services.NewCrawler(cfg, matrixSvc, validatorSvc, blockSvc, dataRepo, detector)
 	matrixSvc.SetDiscover(crawlerSvc.AddServer)
 	cacheSvc := services.NewCache(cfg, statsSvc)
-	dataSvc := services.NewDataFacade(crawlerSvc, indexSvc, statsSvc)
+	avatarSvc := services.NewAvatars(cfg, matrixSvc)
+	dataSvc := services.NewDataFacade(cfg, crawlerSvc, indexSvc, statsSvc, cacheSvc, searchSvc, avatarSvc)
 	mailSvc := services.NewEmail(cfg)
-	modSvc := services.NewModeration(cfg, dataRepo, index, mailSvc)
+	modSvc := services.NewModeration(cfg, dataRepo, index, searchSvc, mailSvc)
 	plausibleSvc := services.NewPlausible(cfg)
 
 	e = echo.New()
 	e.Logger = lecho.From(*log)
-	controllers.ConfigureRouter(e, cfg, matrixSvc, dataSvc, cacheSvc, searchSvc, crawlerSvc, statsSvc, modSvc, plausibleSvc)
+	controllers.ConfigureRouter(e, cfg, matrixSvc, dataSvc, cacheSvc, searchSvc, crawlerSvc, statsSvc, modSvc, plausibleSvc, blockSvc, indexSvc, avatarSvc)
 
-	initCron(cfg, dataSvc)
+	initCron(cfg, dataSvc, blockSvc)
 	initShutdown(quit)
 
 	if err := e.Start(":" + cfg.Get().Port); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -156,7 +180,7 @@ func initShutdown(quit chan struct{}) {
 	}()
 }
 
-func initCron(cfg *services.Config, dataSvc *services.DataFacade) {
+func initCron(cfg *services.Config, dataSvc *services.DataFacade, blockSvc *services.Blocklist) {
 	ctx := utils.NewContext()
 	cron = crontab.New()
 	if schedule := cfg.Get().Cron.Discovery; schedule != "" {
@@ -175,6 +199,14 @@ func initCron(cfg *services.Config, dataSvc *services.DataFacade) {
 		log.Info().Str("job", "full").Msg("cron job enabled")
 		cron.MustAddJob(schedule, dataSvc.Full, ctx, cfg.Get().Workers.Discovery, cfg.Get().Workers.Parsing)
 	}
+	if utils.HasProxy() {
+		log.Info().Str("job", "proxy").Msg("cron job enabled")
+		cron.MustAddJob(cfg.Get().Proxy.GetCheck(), utils.CheckProxy, ctx)
+	}
+	if schedule := cfg.Get().Cron.Blocklist; schedule != "" && cfg.Get().Blocklist.URL != "" {
+		log.Info().Str("job", "blocklist").Msg("cron job enabled")
+		cron.MustAddJob(schedule, blockSvc.Refresh, ctx)
+	}
 }
 
 func shutdown() {
@@ -2,81 +2,360 @@ package controllers
 
 import (
 	"context"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
-	"gopkg.in/yaml.v3"
+	"github.com/rs/zerolog"
 
+	"github.com/etkecc/mrs/internal/model"
 	"github.com/etkecc/mrs/internal/utils"
 )
 
+const (
+	defaultServersLimit = 100
+	maxServersLimit     = 1000
+)
+
 type dataService interface {
 	AddServer(context.Context, string) int
 	AddServers(context.Context, []string, int)
-	DiscoverServers(context.Context, int)
-	ParseRooms(context.Context, int)
-	Ingest(context.Context)
-	Full(context.Context, int, int)
+	DiscoverServers(context.Context, int) *model.RunSummary
+	ParseRooms(context.Context, int) *model.RunSummary
+	Ingest(context.Context) *model.RunSummary
+	Reindex(context.Context) *model.RunSummary
+	Full(context.Context, int, int) *model.RunSummary
+	Runs() map[string]*model.RunSummary
 	GetServersRoomsCount(ctx context.Context) map[string]int
+	RebuildCatalog(ctx context.Context) (*model.CatalogRebuild, error)
+	InspectRoom(context.Context, string) (*model.RoomInspection, error)
+	PurgeServer(context.Context, string) (*model.ServerPurge, error)
 }
 
 type crawlerService interface {
-	OnlineServers(context.Context) []string
+	ListServers(context.Context, model.ServersFilter, int, int) *model.ServersList
+	ServerInfo(context.Context, string) (*model.MatrixServer, error)
+	ServersReport(context.Context) *model.ServersReport
+	SetIndexable(context.Context, string, bool) (*model.MatrixServer, error)
+	ApproveServer(context.Context, string) (*model.MatrixServer, error)
+	SeedServers() []string
+	SetSeedServers(context.Context, []string) ([]string, []string, error)
+	Pause(context.Context) *model.PauseState
+	Resume(context.Context) *model.PauseState
+	PauseState() *model.PauseState
+	SetRoomTags(context.Context, string, []string) ([]string, []string, error)
 }
 
+type indexService interface {
+	Info() (*model.IndexInfo, error)
+	Mapping() ([]byte, error)
+	Optimize(context.Context) error
+	Snapshot(context.Context, io.Writer) error
+}
+
+// servers returns a page of the known servers, filtered by online=true|false, indexable=true|false, quarantined=true|false and name substring
 func servers(crawler crawlerService) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		servers := crawler.OnlineServers(c.Request().Context())
-		serversb, err := yaml.Marshal(servers)
+		limit, err := getIntParam(c, "limit")
+		if err != nil || limit < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid limit")
+		}
+		if limit == 0 {
+			limit = defaultServersLimit
+		}
+		limit = min(limit, maxServersLimit)
+		offset, err := getIntParam(c, "offset")
+		if err != nil || offset < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid offset")
+		}
+
+		filter := model.ServersFilter{Name: strings.TrimSpace(c.QueryParam("name"))}
+		if filter.Online, err = getBoolParam(c, "online"); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if filter.Indexable, err = getBoolParam(c, "indexable"); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if filter.Quarantined, err = getBoolParam(c, "quarantined"); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		return c.JSON(http.StatusOK, crawler.ListServers(c.Request().Context(), filter, limit, offset))
+	}
+}
+
+func serverInfo(crawler crawlerService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		server, err := crawler.ServerInfo(c.Request().Context(), c.Param("name"))
+		if err != nil {
+			return err
+		}
+		if server == nil {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return c.JSON(http.StatusOK, server)
+	}
+}
+
+func serversReport(crawler crawlerService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, crawler.ServersReport(c.Request().Context()))
+	}
+}
+
+func purgeServer(data dataService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		purge, err := data.PurgeServer(c.Request().Context(), utils.NormalizeServer(c.Param("name")))
+		if err != nil {
+			return err
+		}
+		if !purge.Found && purge.Rooms == 0 {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return c.JSON(http.StatusOK, purge)
+	}
+}
+
+func rebuildCatalog(data dataService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		result, err := data.RebuildCatalog(c.Request().Context())
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, result)
+	}
+}
+
+func setServerIndexable(crawler crawlerService) echo.HandlerFunc {
+	type request struct {
+		Indexable *bool `json:"indexable"`
+	}
+	return func(c echo.Context) error {
+		var req request
+		if err := c.Bind(&req); err != nil || req.Indexable == nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "request body must be a JSON object with boolean \"indexable\" field")
+		}
+		server, err := crawler.SetIndexable(c.Request().Context(), c.Param("name"), *req.Indexable)
+		if err != nil {
+			return err
+		}
+		if server == nil {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return c.JSON(http.StatusOK, server)
+	}
+}
+
+func approveServer(crawler crawlerService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		server, err := crawler.ApproveServer(c.Request().Context(), c.Param("name"))
+		if err != nil {
+			return err
+		}
+		if server == nil {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return c.JSON(http.StatusOK, server)
+	}
+}
+
+func seedServers(crawler crawlerService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, crawler.SeedServers())
+	}
+}
+
+func setSeedServers(crawler crawlerService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		servers, err := bindStrings(c)
+		if err != nil {
+			return err
+		}
+		seeds, invalid, err := crawler.SetSeedServers(c.Request().Context(), servers)
+		if err != nil {
+			return err
+		}
+		if len(invalid) > 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid server names: "+strings.Join(invalid, ", "))
+		}
+		return c.JSON(http.StatusOK, seeds)
+	}
+}
+
+func inspectRoom(data dataService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		roomID, err := url.PathUnescape(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		inspection, err := data.InspectRoom(c.Request().Context(), roomID)
+		if err != nil {
+			return err
+		}
+		if inspection.IsEmpty() {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return c.JSON(http.StatusOK, inspection)
+	}
+}
+
+// setRoomTags replaces tags of the room, they are merged into the search index on the next ingest
+func setRoomTags(crawler crawlerService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		roomID, err := url.PathUnescape(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		tags, err := bindStrings(c)
+		if err != nil {
+			return err
+		}
+		stored, invalid, err := crawler.SetRoomTags(c.Request().Context(), roomID, tags)
+		if err != nil {
+			return err
+		}
+		if len(invalid) > 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid tags: "+strings.Join(invalid, ", "))
+		}
+		if stored == nil {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return c.JSON(http.StatusOK, stored)
+	}
+}
+
+func status(stats statsService, crawler crawlerService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, &model.IndexStatus{
+			IndexStats: stats.Get(),
+			Pause:      crawler.PauseState(),
+		})
+	}
+}
+
+func indexStats(index indexService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		info, err := index.Info()
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, info)
+	}
+}
+
+func indexMapping(index indexService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		mapping, err := index.Mapping()
 		if err != nil {
 			return err
 		}
-		return c.Blob(http.StatusOK, "application/x-yaml", serversb)
+		return c.JSONBlob(http.StatusOK, mapping)
 	}
 }
 
-func status(stats statsService) echo.HandlerFunc {
+// indexSnapshot streams gzipped tarball of the live index, to be loaded by replicas using the path.snapshot config option
+func indexSnapshot(index indexService) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		return c.JSON(http.StatusOK, stats.Get())
+		filename := "mrs-index-" + time.Now().UTC().Format("20060102150405") + ".tar.gz"
+		c.Response().Header().Set(echo.HeaderContentType, "application/gzip")
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+		// response is committed on the first write only, so errors before that are returned as usual
+		if err := index.Snapshot(c.Request().Context(), c.Response()); err != nil {
+			if !c.Response().Committed {
+				c.Response().Header().Del(echo.HeaderContentDisposition)
+				return err
+			}
+			zerolog.Ctx(c.Request().Context()).Error().Err(err).Msg("cannot stream index snapshot")
+		}
+		return nil
+	}
+}
+
+func purgeCache(cache cacheService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, cache.Purge(c.Request().Context()))
+	}
+}
+
+// runJob runs the data pipeline job in background,
+// or waits for it to finish and returns its summary, if the wait=true query param is set
+func runJob(c echo.Context, job func(context.Context) *model.RunSummary) error {
+	wait, err := getBoolParam(c, "wait")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	ctx := c.Request().Context()
+	ctx = context.WithoutCancel(ctx)
+	ctx = utils.NewContext(ctx)
+	if wait != nil && *wait {
+		return c.JSON(http.StatusOK, job(ctx))
+	}
+	go job(ctx)
+	return c.NoContent(http.StatusCreated)
+}
+
+func runs(data dataService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, data.Runs())
+	}
+}
+
+// pause stops dispatch of new servers by the running (and future) discovery and parsing jobs, until resumed
+func pause(crawler crawlerService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, crawler.Pause(c.Request().Context()))
+	}
+}
+
+func resume(crawler crawlerService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, crawler.Resume(c.Request().Context()))
 	}
 }
 
 func discover(data dataService, cfg configService) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		ctx := c.Request().Context()
-		ctx = context.WithoutCancel(ctx)
-		ctx = utils.NewContext(ctx)
-		go data.DiscoverServers(ctx, cfg.Get().Workers.Discovery)
-		return c.NoContent(http.StatusCreated)
+		return runJob(c, func(ctx context.Context) *model.RunSummary {
+			return data.DiscoverServers(ctx, cfg.Get().Workers.Discovery)
+		})
 	}
 }
 
 func parse(data dataService, cfg configService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return runJob(c, func(ctx context.Context) *model.RunSummary {
+			return data.ParseRooms(ctx, cfg.Get().Workers.Parsing)
+		})
+	}
+}
+
+func optimizeIndex(index indexService) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		ctx := c.Request().Context()
 		ctx = context.WithoutCancel(ctx)
 		ctx = utils.NewContext(ctx)
-		go data.ParseRooms(ctx, cfg.Get().Workers.Parsing)
+		go func() {
+			if err := index.Optimize(ctx); err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("cannot optimize index")
+			}
+		}()
 		return c.NoContent(http.StatusCreated)
 	}
 }
 
 func reindex(data dataService) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		ctx := c.Request().Context()
-		ctx = context.WithoutCancel(ctx)
-		ctx = utils.NewContext(ctx)
-		go data.Ingest(ctx)
-		return c.NoContent(http.StatusCreated)
+		return runJob(c, data.Reindex)
 	}
 }
 
 func full(data dataService, cfg configService) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		ctx := c.Request().Context()
-		ctx = context.WithoutCancel(ctx)
-		ctx = utils.NewContext(ctx)
-		go data.Full(ctx, cfg.Get().Workers.Discovery, cfg.Get().Workers.Parsing)
-		return c.NoContent(http.StatusCreated)
+		return runJob(c, func(ctx context.Context) *model.RunSummary {
+			return data.Full(ctx, cfg.Get().Workers.Discovery, cfg.Get().Workers.Parsing)
+		})
 	}
 }
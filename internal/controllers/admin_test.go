@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/etkecc/mrs/internal/model"
+)
+
+type fakeStats struct {
+	statsService
+	stats *model.IndexStats
+}
+
+func (s *fakeStats) Get() *model.IndexStats {
+	return s.stats
+}
+
+type fakeCrawler struct {
+	crawlerService
+	pause *model.PauseState
+}
+
+func (c *fakeCrawler) PauseState() *model.PauseState {
+	return c.pause
+}
+
+func TestStatus_Pause(t *testing.T) {
+	pausedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	stats := &fakeStats{stats: &model.IndexStats{Servers: model.IndexStatsServers{Online: 3}}}
+	tests := []struct {
+		name  string
+		pause *model.PauseState
+	}{
+		{"running", &model.PauseState{}},
+		{"paused", &model.PauseState{Paused: true, PausedAt: &pausedAt}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/-/status", http.NoBody), rec)
+			if err := status(stats, &fakeCrawler{pause: test.pause})(c); err != nil {
+				t.Fatal(err)
+			}
+
+			var resp struct {
+				Servers model.IndexStatsServers `json:"servers"`
+				Pause   model.PauseState        `json:"pause"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Servers.Online != 3 {
+				t.Errorf("servers.online: got %d, want 3", resp.Servers.Online)
+			}
+			if resp.Pause.Paused != test.pause.Paused {
+				t.Errorf("pause.paused: got %t, want %t", resp.Pause.Paused, test.pause.Paused)
+			}
+			if test.pause.PausedAt != nil && (resp.Pause.PausedAt == nil || !resp.Pause.PausedAt.Equal(pausedAt)) {
+				t.Errorf("pause.paused_at: got %v, want %v", resp.Pause.PausedAt, pausedAt)
+			}
+		})
+	}
+}
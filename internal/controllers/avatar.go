@@ -1,12 +1,22 @@
 package controllers
 
 import (
+	"context"
+	"io"
 	"net/http"
+	"net/url"
 
 	"github.com/labstack/echo/v4"
 )
 
-func avatar(svc matrixService) echo.HandlerFunc {
+// maxAvatarSize is the max size of the avatar thumbnail, bigger ones are not served
+const maxAvatarSize = 5 * 1024 * 1024
+
+type avatarService interface {
+	Get(ctx context.Context, serverName, mediaID string, params url.Values) (io.Reader, string)
+}
+
+func avatar(svc avatarService) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		name := c.Param("name")
 		id := c.Param("id")
@@ -14,18 +24,30 @@ func avatar(svc matrixService) echo.HandlerFunc {
 			return c.NoContent(http.StatusNoContent)
 		}
 
-		// attempt to get unauthenticated media thumbnail first (CS API, faster)
-		avatar, contentType := svc.GetClientMediaThumbnail(c.Request().Context(), name, id, c.QueryParams())
+		avatar, contentType := svc.Get(c.Request().Context(), name, id, c.QueryParams())
 		if contentType != "" {
-			return c.Stream(http.StatusOK, contentType, avatar)
+			return serveAvatar(c, contentType, avatar)
 		}
 
-		// fallback to authenticated media thumbnail (S2S API, slower)
-		avatar, contentType = svc.GetMediaThumbnail(c.Request().Context(), name, id, c.QueryParams())
-		if contentType != "" {
-			return c.Stream(http.StatusOK, contentType, avatar)
-		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
 
+// serveAvatar buffers avatar to send it with Content-Length and ETag (HEAD requests get headers only),
+// and closes it, if possible
+func serveAvatar(c echo.Context, contentType string, avatar io.Reader) error {
+	if closer, ok := avatar.(io.Closer); ok {
+		defer closer.Close()
+	}
+	body, err := io.ReadAll(io.LimitReader(avatar, maxAvatarSize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxAvatarSize {
 		return c.NoContent(http.StatusNoContent)
 	}
+	if notModified(c, body) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.Blob(http.StatusOK, contentType, body)
 }
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/goccy/go-json"
+	"github.com/labstack/echo/v4"
+
+	"github.com/etkecc/mrs/internal/model"
+)
+
+type blocklistService interface {
+	List(kind string) []string
+	Block(ctx context.Context, kind string, entries []string) error
+	Unblock(ctx context.Context, kind string, entries []string) error
+}
+
+func blocklist(svc blocklistService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, blocklistResponse(svc))
+	}
+}
+
+func block(svc blocklistService, kind string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		entries, err := bindStrings(c)
+		if err != nil {
+			return err
+		}
+		if err := svc.Block(c.Request().Context(), kind, entries); err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, blocklistResponse(svc))
+	}
+}
+
+func unblock(svc blocklistService, kind string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		entries, err := bindStrings(c)
+		if err != nil {
+			return err
+		}
+		if err := svc.Unblock(c.Request().Context(), kind, entries); err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, blocklistResponse(svc))
+	}
+}
+
+func bindStrings(c echo.Context) ([]string, error) {
+	defer c.Request().Body.Close()
+	jsonb, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return nil, err
+	}
+	var entries []string
+	if err := json.Unmarshal(jsonb, &entries); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "request body must be a JSON array of strings")
+	}
+	return entries, nil
+}
+
+func blocklistResponse(svc blocklistService) map[string][]string {
+	return map[string][]string{
+		model.BlocklistServers: svc.List(model.BlocklistServers),
+		model.BlocklistRooms:   svc.List(model.BlocklistRooms),
+	}
+}
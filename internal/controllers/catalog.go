@@ -2,8 +2,16 @@ package controllers
 
 import (
 	"net/http"
+	"sort"
 
 	"github.com/labstack/echo/v4"
+
+	"github.com/etkecc/mrs/internal/model"
+)
+
+const (
+	defaultTopServers = 10
+	maxTopServers     = 1000
 )
 
 func catalogServers(dataSvc dataService) echo.HandlerFunc {
@@ -11,3 +19,33 @@ func catalogServers(dataSvc dataService) echo.HandlerFunc {
 		return c.JSON(http.StatusOK, dataSvc.GetServersRoomsCount(c.Request().Context()))
 	}
 }
+
+// topServers returns servers with the most rooms, sorted descending
+func topServers(dataSvc dataService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		limit, err := getIntParam(c, "limit")
+		if err != nil || limit < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid limit")
+		}
+		if limit == 0 {
+			limit = defaultTopServers
+		}
+		limit = min(limit, maxTopServers)
+
+		counts := dataSvc.GetServersRoomsCount(c.Request().Context())
+		top := make([]model.ServerRoomsCount, 0, len(counts))
+		for server, rooms := range counts {
+			top = append(top, model.ServerRoomsCount{Server: server, Rooms: rooms})
+		}
+		sort.Slice(top, func(i, j int) bool {
+			if top[i].Rooms == top[j].Rooms {
+				return top[i].Server < top[j].Server
+			}
+			return top[i].Rooms > top[j].Rooms
+		})
+		if len(top) > limit {
+			top = top[:limit]
+		}
+		return c.JSON(http.StatusOK, top)
+	}
+}
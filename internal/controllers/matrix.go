@@ -5,7 +5,6 @@ import (
 	"context"
 	"io"
 	"net/http"
-	"net/url"
 
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
@@ -23,8 +22,6 @@ type matrixService interface {
 	GetClientDirectory(ctx context.Context, alias string) (int, []byte)
 	GetClientRoomVisibility(ctx context.Context, roomID string) (int, []byte)
 	GetClientRoomSummary(ctx context.Context, roomAliasOrID string) (int, []byte)
-	GetClientMediaThumbnail(ctx context.Context, serverName, mediaID string, params url.Values) (io.Reader, string)
-	GetMediaThumbnail(ctx context.Context, serverName, mediaID string, params url.Values) (io.Reader, string)
 	PublicRooms(context.Context, *http.Request, *model.RoomDirectoryRequest) (int, []byte)
 	QueryDirectory(ctx context.Context, req *http.Request, alias string) (int, []byte)
 }
@@ -46,7 +43,7 @@ func configureMatrixS2SEndpoints(e *echo.Echo, matrixSvc matrixService, cacheSvc
 	e.POST("/_matrix/federation/v1/publicRooms", matrixRoomDirectory(matrixSvc, plausibleSvc), cacheSvc.MiddlewareSearch())
 }
 
-func configureMatrixCSEndpoints(e *echo.Echo, matrixSvc matrixService, cacheSvc cacheService) {
+func configureMatrixCSEndpoints(e *echo.Echo, matrixSvc matrixService, cacheSvc cacheService, avatarSvc avatarService) {
 	rl := getRL(30)
 	e.GET("/.well-known/matrix/client", func(c echo.Context) error {
 		return c.JSONBlob(http.StatusOK, matrixSvc.GetClientWellKnown())
@@ -57,8 +54,8 @@ func configureMatrixCSEndpoints(e *echo.Echo, matrixSvc matrixService, cacheSvc
 	e.GET("/_matrix/client/versions", func(c echo.Context) error {
 		return c.JSONBlob(http.StatusOK, matrixSvc.GetClientVersion())
 	}, cacheSvc.MiddlewareImmutable())
-	e.GET("/_matrix/media/r0/thumbnail/:name/:id", avatar(matrixSvc), rl, cacheSvc.MiddlewareImmutable())
-	e.GET("/_matrix/media/v3/thumbnail/:name/:id", avatar(matrixSvc), rl, cacheSvc.MiddlewareImmutable())
+	e.GET("/_matrix/media/r0/thumbnail/:name/:id", avatar(avatarSvc), rl, cacheSvc.MiddlewareImmutable())
+	e.GET("/_matrix/media/v3/thumbnail/:name/:id", avatar(avatarSvc), rl, cacheSvc.MiddlewareImmutable())
 	e.GET("/_matrix/client/r0/directory/room/:room_alias", func(c echo.Context) error {
 		return c.JSONBlob(matrixSvc.GetClientDirectory(c.Request().Context(), c.Param("room_alias")))
 	}, rl)
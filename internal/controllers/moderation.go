@@ -2,15 +2,19 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 
+	echobasicauth "github.com/etkecc/go-echo-basic-auth"
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
+
+	"github.com/etkecc/mrs/internal/utils"
 )
 
 type moderationService interface {
-	Report(context.Context, string, string, bool) error
+	Report(context.Context, string, string, string, bool) error
 	List(context.Context, ...string) ([]string, error)
 	Ban(context.Context, string) error
 	Unban(context.Context, string) error
@@ -31,7 +35,14 @@ func report(svc moderationService) echo.HandlerFunc {
 			return err
 		}
 
-		if err := svc.Report(c.Request().Context(), report.RoomID, report.Reason, report.NoMSC1929); err != nil {
+		reportedBy := c.RealIP()
+		if login, ok := c.Get(echobasicauth.ContextLoginKey).(string); ok && login != "" {
+			reportedBy = login
+		}
+		if err := svc.Report(c.Request().Context(), report.RoomID, report.Reason, reportedBy, report.NoMSC1929); err != nil {
+			if errors.Is(err, utils.ErrInvalidRoomID) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
 			log.Error().Err(err).Msg("cannot report room")
 			return err
 		}
@@ -73,6 +84,9 @@ func ban(svc moderationService) echo.HandlerFunc {
 
 		roomID := c.Param("room_id")
 		if err := svc.Ban(c.Request().Context(), roomID); err != nil {
+			if errors.Is(err, utils.ErrInvalidRoomID) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
 			return err
 		}
 
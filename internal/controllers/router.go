@@ -24,12 +24,14 @@ type configService interface {
 type statsService interface {
 	Get() *model.IndexStats
 	GetTL(context.Context) map[time.Time]*model.IndexStats
+	Diff(context.Context) *model.IndexStatsDiff
 }
 
 type cacheService interface {
 	Middleware() echo.MiddlewareFunc
 	MiddlewareSearch() echo.MiddlewareFunc
 	MiddlewareImmutable() echo.MiddlewareFunc
+	Purge(context.Context) map[string]string
 }
 
 type plausibleService interface {
@@ -48,25 +50,31 @@ func ConfigureRouter(
 	statsSvc statsService,
 	modSvc moderationService,
 	plausibleSvc plausibleService,
+	blockSvc blocklistService,
+	indexSvc indexService,
+	avatarSvc avatarService,
 ) {
 	configureRouter(e, cacheSvc)
 	configureMatrixS2SEndpoints(e, matrixSvc, cacheSvc, plausibleSvc)
-	configureMatrixCSEndpoints(e, matrixSvc, cacheSvc)
+	configureMatrixCSEndpoints(e, matrixSvc, cacheSvc, avatarSvc)
 	rl := getRL(1)
+	cors := getCORS(cfg.Get().CORS.Public)
 	e.GET("/metrics", echo.WrapHandler(&metrics.Handler{}), echobasicauth.NewMiddleware(&cfg.Get().Auth.Metrics))
-	e.GET("/stats", stats(statsSvc))
-	e.GET("/avatar/:name/:id", avatar(matrixSvc), getRL(30))
+	e.GET("/stats", stats(statsSvc), cors)
+	e.GET("/avatar/:name/:id", avatar(avatarSvc), cors, getRL(30))
+	e.HEAD("/avatar/:name/:id", avatar(avatarSvc), cors, getRL(30))
 
 	searchCache := cacheSvc.MiddlewareSearch()
-	e.GET("/search", search(searchSvc, plausibleSvc, cfg, false), searchCache, rl)
-	e.GET("/search/:q", search(searchSvc, plausibleSvc, cfg, true), searchCache, rl)
-	e.GET("/search/:q/:l", search(searchSvc, plausibleSvc, cfg, true), searchCache, rl)
-	e.GET("/search/:q/:l/:o", search(searchSvc, plausibleSvc, cfg, true), searchCache, rl)
-	e.GET("/search/:q/:l/:o/:s", search(searchSvc, plausibleSvc, cfg, true), searchCache, rl)
+	e.GET("/search", search(searchSvc, plausibleSvc, cfg, false), cors, searchCache, rl)
+	e.GET("/search/:q", search(searchSvc, plausibleSvc, cfg, true), cors, searchCache, rl)
+	e.GET("/search/:q/:l", search(searchSvc, plausibleSvc, cfg, true), cors, searchCache, rl)
+	e.GET("/search/:q/:l/:o", search(searchSvc, plausibleSvc, cfg, true), cors, searchCache, rl)
+	e.GET("/search/:q/:l/:o/:s", search(searchSvc, plausibleSvc, cfg, true), cors, searchCache, rl)
 
-	e.GET("/catalog/servers", catalogServers(dataSvc), cacheSvc.Middleware(), rl)
+	e.GET("/catalog/servers", catalogServers(dataSvc), cors, cacheSvc.Middleware(), rl)
 
-	e.POST("/discover/bulk", addServers(dataSvc, cfg), echobasicauth.NewMiddleware(&cfg.Get().Auth.Discovery))
+	bodyLimit := middleware.BodyLimit(cfg.Get().GetBodyLimit())
+	e.POST("/discover/bulk", addServers(dataSvc, cfg), echobasicauth.NewMiddleware(&cfg.Get().Auth.Discovery), bodyLimit)
 	e.POST("/discover/:name", addServer(dataSvc), discoveryProtection(rl, cfg))
 
 	e.POST("/mod/report/:room_id", report(modSvc), rl) // doesn't use mod group to allow without auth
@@ -77,14 +85,41 @@ func ConfigureRouter(
 	m.GET("/ban/:room_id", ban(modSvc), rl)
 	m.GET("/unban/:room_id", unban(modSvc), rl)
 
+	e.Pre(getPrefixCORS("/-/", cfg.Get().CORS.Admin)) // router level, to answer preflight requests before routing and auth
 	a := e.Group("-")
-	a.Use(echobasicauth.NewMiddleware(&cfg.Get().Auth.Admin))
+	a.Use(adminAuth(&cfg.Get().Auth.Admin, cfg.Get().Auth.AdminToken))
+	a.Use(bodyLimit)
 	a.GET("/servers", servers(crawlerSvc))
-	a.GET("/status", status(statsSvc))
+	a.GET("/servers/attention", serversReport(crawlerSvc))
+	a.GET("/servers/top", topServers(dataSvc))
+	a.GET("/servers/seed", seedServers(crawlerSvc))
+	a.PUT("/servers/seed", setSeedServers(crawlerSvc))
+	a.GET("/servers/:name", serverInfo(crawlerSvc))
+	a.PUT("/servers/:name/indexable", setServerIndexable(crawlerSvc))
+	a.POST("/servers/:name/approve", approveServer(crawlerSvc))
+	a.DELETE("/servers/:name", purgeServer(dataSvc))
+	a.GET("/rooms/:id", inspectRoom(dataSvc))
+	a.PUT("/rooms/:id/tags", setRoomTags(crawlerSvc))
+	a.GET("/status", status(statsSvc, crawlerSvc))
+	a.GET("/stats/diff", statsDiff(statsSvc))
+	a.GET("/index/stats", indexStats(indexSvc))
+	a.GET("/index/mapping", indexMapping(indexSvc))
+	a.POST("/index/optimize", optimizeIndex(indexSvc))
+	a.GET("/index/snapshot", indexSnapshot(indexSvc))
+	a.POST("/cache/purge", purgeCache(cacheSvc))
+	a.POST("/catalog/rebuild", rebuildCatalog(dataSvc))
 	a.POST("/discover", discover(dataSvc, cfg))
 	a.POST("/parse", parse(dataSvc, cfg))
 	a.POST("/reindex", reindex(dataSvc))
 	a.POST("/full", full(dataSvc, cfg))
+	a.GET("/runs", runs(dataSvc))
+	a.POST("/pause", pause(crawlerSvc))
+	a.POST("/resume", resume(crawlerSvc))
+	a.GET("/blocklist", blocklist(blockSvc))
+	a.POST("/blocklist/servers", block(blockSvc, model.BlocklistServers))
+	a.DELETE("/blocklist/servers", unblock(blockSvc, model.BlocklistServers))
+	a.POST("/blocklist/rooms", block(blockSvc, model.BlocklistRooms))
+	a.DELETE("/blocklist/rooms", unblock(blockSvc, model.BlocklistRooms))
 }
 
 func configureRouter(e *echo.Echo, cacheSvc cacheService) {
@@ -109,6 +144,10 @@ func configureRouter(e *echo.Echo, cacheSvc cacheService) {
 	e.GET("/_health", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
 	})
+	buildInfo := version.Info()
+	e.GET("/version", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, buildInfo)
+	})
 	e.GET("/_docs", func(c echo.Context) error {
 		return c.Redirect(http.StatusMovedPermanently, "/_docs/index.html")
 	})
@@ -2,10 +2,18 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/goccy/go-json"
 	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
 
 	"github.com/etkecc/mrs/internal/metrics"
 	"github.com/etkecc/mrs/internal/model"
@@ -13,9 +21,22 @@ import (
 )
 
 type searchService interface {
-	Search(ctx context.Context, originServer, query, sortBy string, limit, offset int) ([]*model.Entry, int, error)
+	Search(ctx context.Context, originServer, query, sortBy string, limit, offset int, opts model.SearchOptions) ([]*model.Entry, int, error)
+	Suggest(ctx context.Context, query string) string
+	ValidateQuery(query string) error
 }
 
+const (
+	// headerDidYouMean contains search query suggestion when search returns no results
+	headerDidYouMean = "X-Did-You-Mean"
+	// headerNextCursor contains cursor of the next page, see model.EncodeCursor
+	headerNextCursor = "X-Next-Cursor"
+	// headerLimit contains effective limit of the search request
+	headerLimit = "X-Limit"
+	// defaultMaxLimit is used when search.max_limit is not configured
+	defaultMaxLimit = 200
+)
+
 func search(svc searchService, plausible plausibleService, cfg configService, path bool) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		origin := getOrigin(cfg, c.Request())
@@ -30,18 +51,189 @@ func search(svc searchService, plausible plausibleService, cfg configService, pa
 		if err != nil {
 			return err
 		}
+		if err := svc.ValidateQuery(query); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
 		go plausible.TrackSearch(c.Request().Context(), c.Request(), c.RealIP(), query)
 
 		limit := utils.StringToInt(paramfunc("l"))
 		offset := utils.StringToInt(paramfunc("o"))
+		if limit < 0 || offset < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit and offset must not be negative")
+		}
+		if limit == 0 {
+			limit = cfg.Get().Search.Defaults.Limit
+		}
+		limit = min(limit, getMaxLimit(cfg))
+		c.Response().Header().Set(headerLimit, strconv.Itoa(limit))
 		sortBy := paramfunc("s")
-		entries, _, err := svc.Search(c.Request().Context(), origin, query, sortBy, limit, offset)
+		opts, err := getSearchOptions(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		entries, _, err := svc.Search(c.Request().Context(), origin, query, sortBy, limit, offset, opts)
 		if err != nil {
+			if errors.Is(err, model.ErrInvalidCursor) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
 			return err
 		}
+		if deepOffset := cfg.Get().Search.DeepOffset; deepOffset > 0 && offset > deepOffset && opts.Cursor == "" {
+			c.Response().Header().Set("Warning", `299 - "deep offset pagination is slow, use cursor instead"`)
+		}
+		if len(entries) >= limit {
+			if cursor := getNextCursor(entries); cursor != "" {
+				c.Response().Header().Set(headerNextCursor, cursor)
+			}
+		}
 		if len(entries) == 0 {
+			if suggestion := svc.Suggest(c.Request().Context(), query); suggestion != "" {
+				c.Response().Header().Set(headerDidYouMean, suggestion)
+			}
 			return c.NoContent(http.StatusNoContent)
 		}
-		return c.JSON(http.StatusOK, entries)
+
+		body, err := marshalEntries(c, entries)
+		if err != nil {
+			return err
+		}
+		if notModified(c, body) {
+			return c.NoContent(http.StatusNotModified)
+		}
+		return c.JSONBlob(http.StatusOK, body)
+	}
+}
+
+// marshalEntries marshals search results, projected to the fields of the select param (if any).
+// Unknown fields are ignored
+func marshalEntries(c echo.Context, entries []*model.Entry) ([]byte, error) {
+	fields := []string{}
+	for _, field := range strings.Split(c.QueryParam("select"), ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !slices.Contains(model.EntryFields, field) {
+			zerolog.Ctx(c.Request().Context()).Warn().Str("field", field).Msg("unknown field in select param, ignoring")
+			continue
+		}
+		fields = append(fields, field)
 	}
+	if len(fields) == 0 {
+		return json.Marshal(entries)
+	}
+
+	projected := make([]map[string]any, 0, len(entries))
+	for _, entry := range entries {
+		item, err := entry.Project(fields)
+		if err != nil {
+			return nil, err
+		}
+		projected = append(projected, item)
+	}
+	return json.Marshal(projected)
+}
+
+// getMaxLimit returns configured max limit of the search results per page
+func getMaxLimit(cfg configService) int {
+	if maxLimit := cfg.Get().Search.MaxLimit; maxLimit > 0 {
+		return maxLimit
+	}
+	return defaultMaxLimit
+}
+
+// getNextCursor returns cursor of the next page, based on the last search hit
+func getNextCursor(entries []*model.Entry) string {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if len(entries[i].Sort) > 0 { // highlights don't have sort values
+			return model.EncodeCursor(entries[i].Sort)
+		}
+	}
+	return ""
+}
+
+// getIntParam parses optional integer query param, returns 0 if it's empty
+func getIntParam(c echo.Context, name string) (int, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return parsed, nil
+}
+
+// getBoolParam parses optional boolean query param, returns nil if it's empty
+func getBoolParam(c echo.Context, name string) (*bool, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return nil, nil //nolint:nilnil // nil means the param is not set
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return &parsed, nil
+}
+
+// getTimeParam parses optional time query param (RFC3339 or YYYY-MM-DD), returns zero time if it's empty
+func getTimeParam(c echo.Context, name string) (time.Time, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if parsed, err := time.Parse(time.DateOnly, value); err == nil {
+		return parsed, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: must be RFC3339 or YYYY-MM-DD", name)
+	}
+	return parsed, nil
+}
+
+// getSearchOptions parses optional search params from the query string:
+// mode=and, fields=name,topic, boost:name=20, cursor=<X-Next-Cursor of the previous page>,
+// min_members=10, max_members=1000, first_seen_after=2024-01-01, first_seen_before=2024-02-01
+func getSearchOptions(c echo.Context) (model.SearchOptions, error) {
+	opts := model.SearchOptions{
+		Mode:   c.QueryParam("mode"),
+		Cursor: c.QueryParam("cursor"),
+	}
+	for _, field := range strings.Split(c.QueryParam("fields"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			opts.Fields = append(opts.Fields, field)
+		}
+	}
+	for key, values := range c.QueryParams() {
+		field, ok := strings.CutPrefix(key, "boost:")
+		if !ok || len(values) == 0 {
+			continue
+		}
+		boost, err := strconv.ParseFloat(values[0], 64)
+		if err != nil {
+			return opts, fmt.Errorf("invalid boost of %q: %w", field, err)
+		}
+		if opts.Boost == nil {
+			opts.Boost = map[string]float64{}
+		}
+		opts.Boost[field] = boost
+	}
+	var err error
+	if opts.MinMembers, err = getIntParam(c, "min_members"); err != nil {
+		return opts, err
+	}
+	if opts.MaxMembers, err = getIntParam(c, "max_members"); err != nil {
+		return opts, err
+	}
+	if opts.SeenAfter, err = getTimeParam(c, "first_seen_after"); err != nil {
+		return opts, err
+	}
+	if opts.SeenBefore, err = getTimeParam(c, "first_seen_before"); err != nil {
+		return opts, err
+	}
+
+	return opts, opts.Validate()
 }
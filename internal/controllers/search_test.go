@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/etkecc/mrs/internal/model"
+)
+
+type fakeConfig struct {
+	cfg *model.Config
+}
+
+func (c *fakeConfig) Get() *model.Config {
+	return c.cfg
+}
+
+type nopPlausible struct{}
+
+func (nopPlausible) TrackSearch(context.Context, *http.Request, string, string) {}
+
+// fakeSearch returns the err for any search request
+type fakeSearch struct {
+	searchService
+	err error
+}
+
+func (s *fakeSearch) ValidateQuery(string) error { return nil }
+
+func (s *fakeSearch) Search(context.Context, string, string, string, int, int, model.SearchOptions) ([]*model.Entry, int, error) {
+	return nil, 0, s.err
+}
+
+func TestSearch_InvalidCursor(t *testing.T) {
+	cfg := &fakeConfig{cfg: &model.Config{
+		Matrix: &model.ConfigMatrix{ServerName: "example.com"},
+		Search: &model.ConfigSearch{},
+	}}
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"invalid cursor", fmt.Errorf("%w: 1 sort values, sort order has 2", model.ErrInvalidCursor), http.StatusBadRequest},
+		{"other error", errors.New("index is closed"), 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/search?q=test&cursor=WyIxLjAiXQ", http.NoBody), httptest.NewRecorder())
+			err := search(&fakeSearch{err: test.err}, nopPlausible{}, cfg, false)(c)
+			var httpErr *echo.HTTPError
+			if !errors.As(err, &httpErr) {
+				if test.expected != 0 {
+					t.Fatalf("got %v, want HTTP %d", err, test.expected)
+				}
+				return
+			}
+			if httpErr.Code != test.expected {
+				t.Errorf("got HTTP %d, want %d", httpErr.Code, test.expected)
+			}
+		})
+	}
+}
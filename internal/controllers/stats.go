@@ -40,6 +40,16 @@ func stats(stats statsService) echo.HandlerFunc {
 	}
 }
 
+func statsDiff(stats statsService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		diff := stats.Diff(c.Request().Context())
+		if diff == nil {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return c.JSON(http.StatusOK, diff)
+	}
+}
+
 func statsDetails(stats *model.IndexStats) map[string]any {
 	return map[string]any{
 		"servers": map[string]any{
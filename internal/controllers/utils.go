@@ -2,10 +2,14 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	echobasicauth "github.com/etkecc/go-echo-basic-auth"
 	"github.com/getsentry/sentry-go"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -52,6 +56,60 @@ func SentryTransaction() echo.MiddlewareFunc {
 	}
 }
 
+// adminAuth is a middleware that accepts either a bearer token (if configured) or basic auth credentials,
+// the IP allowlist (IPs and CIDRs, validated on startup) applies to both methods
+func adminAuth(auth *echobasicauth.Auth, token string) echo.MiddlewareFunc {
+	prefixes, _ := utils.ParseIPPrefixes(auth.IPs) //nolint:errcheck // validated on startup
+	basicAuth := echobasicauth.NewMiddleware(&echobasicauth.Auth{Login: auth.Login, Password: auth.Password})
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		basicNext := basicAuth(next)
+		return func(c echo.Context) error {
+			allowedIP := len(prefixes) == 0 || utils.IPAllowed(prefixes, c.RealIP())
+			bearer, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if token == "" || !ok {
+				if !allowedIP {
+					c.Logger().Infof("authorization attempt from %s to %s (allowed_ip=%t allowed_credentials=%t)", c.RealIP(), c.Request().URL.Path, false, false)
+					return echo.ErrUnauthorized
+				}
+				return basicNext(c)
+			}
+
+			match := echobasicauth.Equals(token, bearer)
+			c.Logger().Infof("token authorization attempt from %s to %s (allowed_ip=%t allowed_token=%t)", c.RealIP(), c.Request().URL.Path, allowedIP, match)
+			if !match || !allowedIP {
+				return echo.ErrUnauthorized
+			}
+			return next(c)
+		}
+	}
+}
+
+// getCORS returns CORS middleware allowing the origins, or no-op middleware if there are no origins
+func getCORS(origins []string) echo.MiddlewareFunc {
+	if len(origins) == 0 {
+		return func(next echo.HandlerFunc) echo.HandlerFunc {
+			return next
+		}
+	}
+	return middleware.CORSWithConfig(middleware.CORSConfig{AllowOrigins: origins})
+}
+
+// getPrefixCORS returns CORS middleware allowing the origins on the paths with the prefix only.
+// It is meant for echo.Pre, because route middlewares don't run for preflight requests (there is no OPTIONS route)
+func getPrefixCORS(prefix string, origins []string) echo.MiddlewareFunc {
+	if len(origins) == 0 {
+		return func(next echo.HandlerFunc) echo.HandlerFunc {
+			return next
+		}
+	}
+	return middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins: origins,
+		Skipper: func(c echo.Context) bool {
+			return !strings.HasPrefix(c.Request().URL.Path, prefix)
+		},
+	})
+}
+
 // getOrigin returns the origin of the request (if provided), or referer (if provided), or the MRS server name
 func getOrigin(cfg configService, r *http.Request) string {
 	var origin string
@@ -86,3 +144,12 @@ func getRL(limit rate.Limit) echo.MiddlewareFunc {
 	rls[limit] = middleware.RateLimiterWithConfig(cfg)
 	return rls[limit]
 }
+
+// notModified sets ETag header based on the response body
+// and returns true if the client already has the same version of it
+func notModified(c echo.Context, body []byte) bool {
+	hash := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+	c.Response().Header().Set("ETag", etag)
+	return c.Request().Header.Get("If-None-Match") == etag
+}
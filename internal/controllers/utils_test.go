@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	echobasicauth "github.com/etkecc/go-echo-basic-auth"
+	"github.com/labstack/echo/v4"
+)
+
+func TestGetPrefixCORS_Preflight(t *testing.T) {
+	e := echo.New()
+	e.Pre(getPrefixCORS("/-/", []string{"https://admin.example.com"}))
+	a := e.Group("-")
+	a.Use(adminAuth(&echobasicauth.Auth{Login: "admin", Password: "secret"}, ""))
+	a.GET("/status", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	e.GET("/stats", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		origin string
+	}{
+		{"admin", "/-/status", http.StatusNoContent, "https://admin.example.com"},
+		{"public", "/stats", http.StatusNoContent, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, test.path, http.NoBody)
+			req.Header.Set(echo.HeaderOrigin, "https://admin.example.com")
+			req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodGet)
+			req.Header.Set(echo.HeaderAccessControlRequestHeaders, echo.HeaderAuthorization)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != test.status {
+				t.Errorf("status: got %d, want %d", rec.Code, test.status)
+			}
+			if origin := rec.Header().Get(echo.HeaderAccessControlAllowOrigin); origin != test.origin {
+				t.Errorf("allowed origin: got %q, want %q", origin, test.origin)
+			}
+		})
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	auth := &echobasicauth.Auth{Login: "admin", Password: "secret", IPs: []string{"192.0.2.0/24"}}
+	basic := func(login, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(login+":"+password))
+	}
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		ip            string
+		status        int
+	}{
+		{"basic auth", "", basic("admin", "secret"), "192.0.2.1", http.StatusOK},
+		{"wrong password", "", basic("admin", "secreT"), "192.0.2.1", http.StatusUnauthorized},
+		{"wrong login", "", basic("Admin", "secret"), "192.0.2.1", http.StatusUnauthorized},
+		{"no credentials", "", "", "192.0.2.1", http.StatusUnauthorized},
+		{"basic auth, disallowed IP", "", basic("admin", "secret"), "198.51.100.1", http.StatusUnauthorized},
+		{"token", "token", "Bearer token", "192.0.2.1", http.StatusOK},
+		{"wrong token", "token", "Bearer tokeN", "192.0.2.1", http.StatusUnauthorized},
+		{"token, disallowed IP", "token", "Bearer token", "198.51.100.1", http.StatusUnauthorized},
+		{"token is not configured", "", "Bearer token", "192.0.2.1", http.StatusUnauthorized},
+		{"basic auth with token configured", "token", basic("admin", "secret"), "192.0.2.1", http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			e.GET("/-/status", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, adminAuth(auth, test.token))
+			req := httptest.NewRequest(http.MethodGet, "/-/status", http.NoBody)
+			req.RemoteAddr = test.ip + ":1234"
+			if test.authorization != "" {
+				req.Header.Set(echo.HeaderAuthorization, test.authorization)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != test.status {
+				t.Errorf("status: got %d, want %d", rec.Code, test.status)
+			}
+		})
+	}
+}
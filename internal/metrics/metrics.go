@@ -17,6 +17,12 @@ var (
 	RoomsParsed = metrics.NewCounter("mrs_rooms_parsed")
 	// RoomsIndexed - The total number of rooms indexed from the indexable servers
 	RoomsIndexed = metrics.NewCounter("mrs_rooms_indexed")
+	// RoomsBlocked - The total number of times rooms were dropped during parsing due to the blocklist
+	RoomsBlocked = metrics.NewCounter("mrs_rooms_blocked_total")
+	// ServersBlocked - The total number of times servers were considered not indexable during discovery due to the blocklist
+	ServersBlocked = metrics.NewCounter("mrs_servers_blocked_total")
+	// AliasCollisions - The number of rooms that claimed canonical alias of another room during the last indexing
+	AliasCollisions = metrics.NewCounter("mrs_alias_collisions")
 )
 
 // IncSearchQueries increments search queries counter with labels
@@ -24,6 +30,33 @@ func IncSearchQueries(api, server string) {
 	metrics.GetOrCreateCounter(fmt.Sprintf("mrs_search_queries{api=%q,server=%q}", api, server)).Inc()
 }
 
+// IncRoomsDenied increments counter of rooms dropped by the topics denylist pattern
+func IncRoomsDenied(pattern string) {
+	metrics.GetOrCreateCounter(fmt.Sprintf("mrs_rooms_denied{pattern=%q}", pattern)).Inc()
+}
+
+// IncServersRejectedTLD increments counter of servers skipped during discovery due to the TLD allowlist/denylist
+func IncServersRejectedTLD(tld string) {
+	metrics.GetOrCreateCounter(fmt.Sprintf("mrs_servers_rejected_tld{tld=%q}", tld)).Inc()
+}
+
+// SetProxyUp sets reachability of the outbound proxy, checked by the proxy health check
+func SetProxyUp(proxy string, up bool) {
+	metrics.GetOrCreateGauge(fmt.Sprintf("mrs_proxy_up{proxy=%q}", proxy), nil).Set(boolToFloat(up))
+}
+
+// SetProxyDirect sets whether outbound requests bypass the proxy, because no proxy is reachable
+func SetProxyDirect(direct bool) {
+	metrics.GetOrCreateGauge("mrs_proxy_direct", nil).Set(boolToFloat(direct))
+}
+
+func boolToFloat(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
 // Handler for metrics
 type Handler struct{}
 
@@ -1,19 +1,34 @@
 package model
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
 	echobasicauth "github.com/etkecc/go-echo-basic-auth"
 	"github.com/etkecc/go-msc1929"
+
+	"github.com/etkecc/mrs/internal/utils"
 )
 
 // Config is MRS configuration model
 type Config struct {
 	Port      string           `yaml:"port"`
-	SentryDSN string           `yaml:"sentry_dsn"`
+	SentryDSN string           `yaml:"sentry_dsn"` // Deprecated: use Sentry.DSN
+	Sentry    ConfigSentry     `yaml:"sentry"`
 	Public    *ConfigPublic    `yaml:"public"`
 	Matrix    *ConfigMatrix    `yaml:"matrix"`
 	Search    *ConfigSearch    `yaml:"search"`
 	Path      *ConfigPaths     `yaml:"path"`
 	Batch     *ConfigBatch     `yaml:"batch"`
+	Parsing   ConfigParsing    `yaml:"parsing"`
+	TLS       ConfigTLS        `yaml:"tls"`
+	Proxy     ConfigProxy      `yaml:"proxy"`
+	CORS      ConfigCORS       `yaml:"cors"`
+	BodyLimit string           `yaml:"body_limit"` // max request body size of the write endpoints, e.g. 10M
 	Auth      *ConfigAuth      `yaml:"auth"`
 	Cron      *ConfigCron      `yaml:"cron"`
 	Cache     *ConfigCache     `yaml:"cache"`
@@ -26,17 +41,128 @@ type Config struct {
 	Blocklist *ConfigBlocklist `yaml:"blocklist"`
 }
 
+// ConfigSentry - sentry (errors and tracing) configuration
+type ConfigSentry struct {
+	DSN              string  `yaml:"dsn"`
+	SampleRate       float64 `yaml:"sample_rate"`        // errors sample rate, 0 = default (0.25)
+	TracesSampleRate float64 `yaml:"traces_sample_rate"` // traces sample rate, 0 = default (0.25)
+	Environment      string  `yaml:"environment"`
+}
+
+// GetSentryDSN returns sentry DSN, supporting the deprecated sentry_dsn config option
+func (c *Config) GetSentryDSN() string {
+	if c.Sentry.DSN != "" {
+		return c.Sentry.DSN
+	}
+	return c.SentryDSN
+}
+
+// defaultBodyLimit is the default max request body size of the write endpoints
+const defaultBodyLimit = "10M"
+
+// GetBodyLimit returns max request body size of the write endpoints
+func (c *Config) GetBodyLimit() string {
+	if c.BodyLimit == "" {
+		return defaultBodyLimit
+	}
+	return c.BodyLimit
+}
+
+// ConfigTLS - TLS configuration of outbound requests, intended for testing against self-hosted servers
+type ConfigTLS struct {
+	CABundle           string `yaml:"ca_bundle"`            // path to PEM file with CA certificates to trust in addition to the system ones
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"` // disable TLS verification completely, NEVER use it in production
+}
+
+// ConfigProxy - outbound proxy of federation requests, with health check and fallback
+type ConfigProxy struct {
+	URL      string `yaml:"url"`      // primary proxy, e.g. http://proxy:3128 or socks5://proxy:1080
+	Fallback string `yaml:"fallback"` // secondary proxy, used when the primary one is unreachable
+	Direct   bool   `yaml:"direct"`   // fall back to direct requests when no proxy is reachable
+	Check    string `yaml:"check"`    // health check schedule, using cron syntax, default: every minute
+}
+
+// DefaultProxyCheck is the default schedule of the proxy health check
+const DefaultProxyCheck = "* * * * *"
+
+// GetCheck returns the schedule of the proxy health check
+func (c ConfigProxy) GetCheck() string {
+	if c.Check == "" {
+		return DefaultProxyCheck
+	}
+	return c.Check
+}
+
+// ConfigCORS - CORS policies, lists of allowed origins ("*" allows any origin).
+// Empty list disables CORS, so browsers will block cross-origin requests
+type ConfigCORS struct {
+	Public []string `yaml:"public"` // public read endpoints, like /search, /stats, /avatar
+	Admin  []string `yaml:"admin"`  // admin endpoints (/-/*)
+}
+
 // ConfigPublic - instance public information
 type ConfigPublic struct {
-	Name string `yaml:"name"`
-	UI   string `yaml:"ui"`
-	API  string `yaml:"api"`
+	Name    string `yaml:"name"`
+	UI      string `yaml:"ui"`
+	API     string `yaml:"api"`
+	Contact string `yaml:"contact"` // operator contact (URL or email) appended to the User-Agent of outbound requests
+	From    string `yaml:"from"`    // operator email sent in the From header of outbound requests
+	Links   string `yaml:"links"`   // base URL of room deep links, room alias or ID is appended to it, default: DefaultRoomLinks
 }
 
 // ConfigSearch - search-related configuration
 type ConfigSearch struct {
-	Defaults   ConfigSearchDefaults     `yaml:"defaults"`
-	Highlights []*ConfigSearchHighlight `yaml:"highlights"`
+	Defaults    ConfigSearchDefaults     `yaml:"defaults"`
+	Highlights  []*ConfigSearchHighlight `yaml:"highlights"`
+	Suggestions bool                     `yaml:"suggestions"`
+	Swap        ConfigSearchSwap         `yaml:"swap"`
+	Cache       ConfigSearchCache        `yaml:"cache"`
+	Fields      ConfigSearchFields       `yaml:"fields"`
+	MaxLimit    int                      `yaml:"max_limit"`   // max results per page, requested limit is clamped to it
+	DeepOffset  int                      `yaml:"deep_offset"` // offsets above that value get Warning header recommending cursor pagination, 0 = disabled
+	Incremental bool                     `yaml:"incremental"` // re-index only changed rooms on ingest, instead of building a fresh index
+	Optimize    bool                     `yaml:"optimize"`    // merge index segments after each ingest
+	// FoldAccents makes search accent-insensitive ("cafe" matches "café"), changing it requires a fresh reindex
+	FoldAccents bool `yaml:"fold_accents"`
+	// MembersWeight blends the text relevance with the joined members count when sorting by the default sort, 0 = disabled.
+	// See services.MembersBoostSaturation for the formula
+	MembersWeight float64 `yaml:"members_weight"`
+}
+
+func (c *ConfigSearch) validate() error {
+	if c.MembersWeight < 0 {
+		return fmt.Errorf("search.members_weight: must not be negative")
+	}
+	return nil
+}
+
+// ConfigSearchFields - fields used for full-text search, unless restricted by the request
+type ConfigSearchFields struct {
+	Default   []string            `yaml:"default"`   // default: all SearchFields
+	Languages map[string][]string `yaml:"languages"` // language code => fields, used when query contains language:XX
+}
+
+// ConfigSearchCache - in-memory cache of search results
+type ConfigSearchCache struct {
+	Size int `yaml:"size"` // max number of cached queries, 0 = disabled
+	TTL  int `yaml:"ttl"`  // in seconds, entries are invalidated after new index anyway, 0 = DefaultSearchCacheTTL
+}
+
+// DefaultSearchCacheTTL is the default lifetime of the cached search results
+const DefaultSearchCacheTTL = 5 * time.Minute
+
+// GetTTL returns lifetime of the cached search results
+func (c ConfigSearchCache) GetTTL() time.Duration {
+	if c.TTL <= 0 {
+		return DefaultSearchCacheTTL
+	}
+	return time.Duration(c.TTL) * time.Second
+}
+
+// ConfigSearchSwap - verification of the new index before it replaces the live one
+type ConfigSearchSwap struct {
+	MinDocs  int     `yaml:"min_docs"`
+	MinRatio float64 `yaml:"min_ratio"`
 }
 
 // ConfigSearchDefaults default params
@@ -93,8 +219,25 @@ type ConfigPlausible struct {
 
 // ConfigCache - cache-related configuration
 type ConfigCache struct {
-	MaxAge       int `yaml:"max_age"`
-	MaxAgeSearch int `yaml:"max_age_search"`
+	MaxAge               int                   `yaml:"max_age"`
+	MaxAgeSearch         int                   `yaml:"max_age_search"`
+	StaleWhileRevalidate int                   `yaml:"stale_while_revalidate"` // seconds, 0 = disabled
+	StaleIfError         int                   `yaml:"stale_if_error"`         // seconds, 0 = disabled
+	Servers              bool                  `yaml:"servers"`                // keep in-memory snapshot of the servers info to reduce db reads
+	Bunny                ConfigCacheBunny      `yaml:"bunny"`
+	Cloudflare           ConfigCacheCloudflare `yaml:"cloudflare"`
+}
+
+// ConfigCacheBunny - BunnyCDN purge configuration
+type ConfigCacheBunny struct {
+	URL string `yaml:"url"` // pull zone purge URL, e.g. https://api.bunny.net/pullzone/12345/purgeCache
+	Key string `yaml:"key"`
+}
+
+// ConfigCacheCloudflare - Cloudflare purge configuration
+type ConfigCacheCloudflare struct {
+	Zone  string `yaml:"zone"`
+	Token string `yaml:"token"`
 }
 
 // ConfigAuth - auth-related configuration
@@ -103,12 +246,14 @@ type ConfigAuth struct {
 	Metrics    echobasicauth.Auth `yaml:"metrics"`
 	Discovery  echobasicauth.Auth `yaml:"discovery"`
 	Moderation echobasicauth.Auth `yaml:"moderation"`
+	AdminToken string             `yaml:"admin_token"` // Bearer token for admin endpoints, alternative to the admin basic auth
 }
 
 // ConfigWebhooks - webhooks related config
 type ConfigWebhooks struct {
 	Moderation string `json:"moderation"`
 	Stats      string `json:"stats"`
+	Reports    string `json:"reports"` // machine-readable JSON notification on each room report
 }
 
 // ConfigCron - cronjobs config
@@ -117,29 +262,217 @@ type ConfigCron struct {
 	Parsing   string `yaml:"parsing"`
 	Indexing  string `yaml:"indexing"`
 	Full      string `yaml:"full"`
+	Blocklist string `yaml:"blocklist"`
 }
 
 // ConfigPaths - paths configuration
 type ConfigPaths struct {
-	Index string `yaml:"index"`
-	Data  string `yaml:"data"`
+	Index    string `yaml:"index"`
+	Data     string `yaml:"data"`
+	Snapshot string `yaml:"snapshot"` // index snapshot to load on startup, if the file exists
+	Avatars  string `yaml:"avatars"`  // local avatars cache directory, populated during ingest, empty = disabled
 }
 
 // ConfigBatch - batches related configuration
 type ConfigBatch struct {
 	Rooms int `yaml:"rooms"`
+	// RoomsBytes flushes rooms batch when serialized size of its rooms reaches that many bytes (even if the Rooms count is not reached yet),
+	// 0 = disabled
+	RoomsBytes int `yaml:"rooms_bytes"`
+	Data       int `yaml:"data"` // parsed rooms stored in the data repository at once
+	// DataInterval (in seconds) flushes partial batch of parsed rooms periodically, 0 = flush full batches only
+	DataInterval int `yaml:"data_interval"`
+	// Timeout (in seconds) of a single rooms batch indexing, 0 = default (DefaultBatchTimeout)
+	Timeout int `yaml:"timeout"`
+}
+
+// DefaultBatchTimeout is the default timeout of a single rooms batch indexing
+const DefaultBatchTimeout = 5 * time.Minute
+
+// GetTimeout returns timeout of a single rooms batch indexing
+func (c ConfigBatch) GetTimeout() time.Duration {
+	if c.Timeout <= 0 {
+		return DefaultBatchTimeout
+	}
+	return time.Duration(c.Timeout) * time.Second
+}
+
+// ConfigParsing - rooms parsing configuration
+type ConfigParsing struct {
+	MinMembers     int      `yaml:"min_members"`     // rooms with less members are not stored and indexed at all, 0 = disabled
+	Languages      []string `yaml:"languages"`       // ISO 639-1 codes of allowed rooms languages, empty = all languages are allowed
+	ExcludeUnknown bool     `yaml:"exclude_unknown"` // drop rooms with undetected language, only if languages list is set
+	// MaxRoomsPerServer stops parsing of the server's room directory once that many rooms are stored, 0 = unlimited
+	MaxRoomsPerServer int `yaml:"max_rooms_per_server"`
+	// Retries of the whole server within the same parsing run, if the first page of its room directory cannot be fetched, 0 = disabled
+	Retries int `yaml:"retries"`
+	// RetryDelay (in seconds) between the whole server retries, 0 = default (DefaultParsingRetryDelay)
+	RetryDelay int                     `yaml:"retry_delay"`
+	Quarantine ConfigParsingQuarantine `yaml:"quarantine"`
+	Topic      ConfigParsingTopic      `yaml:"topic"`
+	Spaces     ConfigParsingSpaces     `yaml:"spaces"`
+	// Order of servers dispatch within the parsing run, one of ParsingOrder* constants, empty = ParsingOrderAlphabetical
+	Order string `yaml:"order"`
+	// Priority servers are dispatched first (in the listed order), before the rest of the servers ordered by the Order strategy
+	Priority []string `yaml:"priority"`
+}
+
+const (
+	// ParsingOrderAlphabetical dispatches servers in alphabetical order
+	ParsingOrderAlphabetical = "alphabetical"
+	// ParsingOrderRooms dispatches servers with more known rooms first, ties are ordered alphabetically
+	ParsingOrderRooms = "rooms"
+)
+
+// ConfigParsingTopic - rooms topic sanitization, applied before the room is stored and indexed
+type ConfigParsingTopic struct {
+	MaxLength int  `yaml:"max_length"` // topics are truncated to that many characters, 0 = default (DefaultTopicMaxLength)
+	StripHTML bool `yaml:"strip_html"` // remove HTML tags and unescape HTML entities
+}
+
+// DefaultTopicMaxLength is the default max length of the room topic
+const DefaultTopicMaxLength = 400
+
+// GetMaxLength returns max length of the room topic
+func (c ConfigParsingTopic) GetMaxLength() int {
+	if c.MaxLength <= 0 {
+		return DefaultTopicMaxLength
+	}
+	return c.MaxLength
+}
+
+// ConfigParsingSpaces - spaces hierarchy parsing, children of the spaces are fetched with extra federation request per space
+type ConfigParsingSpaces struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxDepth of nested spaces traversal, only for nested spaces missing from the room directories, 0 = default (DefaultSpacesMaxDepth)
+	MaxDepth int `yaml:"max_depth"`
+	// MaxChildren stored per space, the rest is ignored, 0 = default (DefaultSpacesMaxChildren)
+	MaxChildren int `yaml:"max_children"`
+}
+
+const (
+	// DefaultSpacesMaxDepth is the default max depth of nested spaces traversal
+	DefaultSpacesMaxDepth = 3
+	// DefaultSpacesMaxChildren is the default max number of children stored per space
+	DefaultSpacesMaxChildren = 1000
+)
+
+// GetMaxDepth returns max depth of nested spaces traversal
+func (c ConfigParsingSpaces) GetMaxDepth() int {
+	if c.MaxDepth <= 0 {
+		return DefaultSpacesMaxDepth
+	}
+	return c.MaxDepth
+}
+
+// GetMaxChildren returns max number of children stored per space
+func (c ConfigParsingSpaces) GetMaxChildren() int {
+	if c.MaxChildren <= 0 {
+		return DefaultSpacesMaxChildren
+	}
+	return c.MaxChildren
+}
+
+// ConfigParsingQuarantine - newly discovered servers are held for review, their rooms are not parsed until approved
+type ConfigParsingQuarantine struct {
+	Enabled     bool `yaml:"enabled"`      // quarantine newly discovered servers, when disabled, quarantined servers are parsed as usual
+	AutoApprove int  `yaml:"auto_approve"` // approve quarantined servers automatically after that many days since discovery, 0 = manual approval only
+}
+
+// IsExpired checks if the quarantine of the server discovered at the given time is over
+func (c ConfigParsingQuarantine) IsExpired(discoveredAt time.Time) bool {
+	if c.AutoApprove <= 0 || discoveredAt.IsZero() {
+		return false
+	}
+	return time.Since(discoveredAt) >= time.Duration(c.AutoApprove)*24*time.Hour
+}
+
+// DefaultParsingRetryDelay is the default delay between the whole server retries
+const DefaultParsingRetryDelay = 30 * time.Second
+
+// GetRetryDelay returns delay between the whole server retries
+func (c ConfigParsing) GetRetryDelay() time.Duration {
+	if c.RetryDelay <= 0 {
+		return DefaultParsingRetryDelay
+	}
+	return time.Duration(c.RetryDelay) * time.Second
+}
+
+func (c ConfigParsing) validate() error {
+	switch c.Order {
+	case "", ParsingOrderAlphabetical, ParsingOrderRooms:
+		return nil
+	default:
+		return fmt.Errorf("parsing.order: %q is not supported, use %q or %q", c.Order, ParsingOrderAlphabetical, ParsingOrderRooms)
+	}
+}
+
+// IsLanguageAllowed checks if rooms of the language should be stored and indexed
+func (c ConfigParsing) IsLanguageAllowed(lang string) bool {
+	if len(c.Languages) == 0 {
+		return true
+	}
+	if lang == "" || lang == utils.UnknownLang {
+		return !c.ExcludeUnknown
+	}
+	for _, allowed := range c.Languages {
+		if strings.EqualFold(allowed, lang) {
+			return true
+		}
+	}
+	return false
 }
 
 // ConfigWorkers - workers related configuration
 type ConfigWorkers struct {
+	Requests  int `yaml:"requests"`
 	Discovery int `yaml:"discovery"`
 	Parsing   int `yaml:"parsing"`
 }
 
 // ConfigBlocklist - blocklist related configuration
 type ConfigBlocklist struct {
-	Servers []string `json:"servers"`
-	Queries []string `json:"queries"`
+	Servers []string            `json:"servers"`
+	Queries []string            `json:"queries"`
+	Topics  []string            `json:"topics"` // case-insensitive regular expressions matched against room name and topic
+	TLDs    ConfigBlocklistTLDs `json:"tlds"`
+	URL     string              `json:"url"` // remote list of servers (one per line, # for comments), fetched on startup and by the cron.blocklist job
+}
+
+// ConfigBlocklistTLDs - servers filtering by top-level domain, checked before any network request during discovery
+type ConfigBlocklistTLDs struct {
+	Allow []string `json:"allow"` // if set, only servers within the TLDs are discovered
+	Deny  []string `json:"deny"`  // servers within the TLDs are not discovered
+}
+
+// Validate checks that all TLDs in the lists are valid
+func (c ConfigBlocklistTLDs) Validate() error {
+	for _, list := range [][]string{c.Allow, c.Deny} {
+		for _, tld := range list {
+			if _, err := utils.NormalizeTLD(tld); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// IsAllowed checks if servers within the (normalized) TLD should be discovered
+func (c ConfigBlocklistTLDs) IsAllowed(tld string) bool {
+	if containsTLD(c.Deny, tld) {
+		return false
+	}
+	return len(c.Allow) == 0 || containsTLD(c.Allow, tld)
+}
+
+// containsTLD checks if the list contains the (normalized) TLD, invalid list entries are ignored
+func containsTLD(list []string, tld string) bool {
+	for _, entry := range list {
+		if normalized, err := utils.NormalizeTLD(entry); err == nil && normalized == tld {
+			return true
+		}
+	}
+	return false
 }
 
 // ConfigEmail - email related configuration
@@ -147,6 +480,19 @@ type ConfigEmail struct {
 	Postmark   ConfigEmailPostmark  `yaml:"postmark"`
 	Moderation string               `yaml:"moderation"`
 	Templates  ConfigEmailTemplates `yaml:"templates"`
+	// ReportInterval is min interval (in seconds) between report emails sent to the same server's MSC1929 contacts, 0 = default (DefaultReportInterval)
+	ReportInterval int `yaml:"report_interval"`
+}
+
+// DefaultReportInterval is the default min interval between report emails sent to the same server's MSC1929 contacts
+const DefaultReportInterval = time.Hour
+
+// GetReportInterval returns min interval between report emails sent to the same server's MSC1929 contacts
+func (c *ConfigEmail) GetReportInterval() time.Duration {
+	if c.ReportInterval <= 0 {
+		return DefaultReportInterval
+	}
+	return time.Duration(c.ReportInterval) * time.Second
 }
 
 // ConfigEmailPostmark is Postmark config
@@ -175,7 +521,271 @@ type ConfigEmailTemplate struct {
 // ConfigMatrix - matrix server config
 type ConfigMatrix struct {
 	ServerName string            `yaml:"server_name"`
+	SelfNames  []string          `yaml:"self_names"` // other names of the MRS instance itself (e.g. delegated names), never crawled
 	Support    *msc1929.Response `yaml:"support"`
 	Keys       []string          `yaml:"keys"`
 	OldKeys    []string          `yaml:"old_keys"`
+	Media      ConfigMatrixMedia `yaml:"media"`
+}
+
+// IsSelf checks if server name belongs to the MRS instance itself: matrix.server_name,
+// matrix.self_names or the host of public.api, to avoid crawling own federation directory
+func (c *Config) IsSelf(server string) bool {
+	server = utils.NormalizeServer(server)
+	if server == "" {
+		return false
+	}
+	if c.Matrix != nil {
+		if server == utils.NormalizeServer(c.Matrix.ServerName) {
+			return true
+		}
+		for _, name := range c.Matrix.SelfNames {
+			if server == utils.NormalizeServer(name) {
+				return true
+			}
+		}
+	}
+	if c.Public != nil {
+		if apiURL, err := url.Parse(c.Public.API); err == nil && apiURL.Host != "" && server == utils.NormalizeServer(apiURL.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigMatrixMedia - media (avatars) download configuration
+type ConfigMatrixMedia struct {
+	Fallbacks []string `yaml:"fallbacks"` // CS API URLs used when the room's server doesn't respond, default: matrix.org
+	Timeout   int      `yaml:"timeout"`   // in seconds, timeout of the room's server request before trying fallbacks, 0 = default (5)
+	Race      bool     `yaml:"race"`      // request the room's server and all fallbacks at once and use the first response
+	// LocalOnly serves avatars from the local cache (path.avatars) only, missing ones are replaced with a placeholder,
+	// so no requests to the media servers are made at serve time
+	LocalOnly bool `yaml:"local_only"`
+}
+
+// Validate checks the config on startup (and on reload), to fail fast instead of runtime surprises:
+// required sections and fields, URLs, and fields required by each enabled feature
+func (c *Config) Validate() error {
+	if c == nil {
+		return errors.New("config is empty")
+	}
+	errs := []error{}
+	sections := []struct {
+		name    string
+		missing bool
+	}{
+		{"public", c.Public == nil},
+		{"matrix", c.Matrix == nil},
+		{"search", c.Search == nil},
+		{"path", c.Path == nil},
+		{"batch", c.Batch == nil},
+		{"auth", c.Auth == nil},
+		{"workers", c.Workers == nil},
+		{"blocklist", c.Blocklist == nil},
+	}
+	for _, section := range sections {
+		if section.missing {
+			errs = append(errs, fmt.Errorf("%s: section is required", section.name))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	errs = append(errs,
+		validateURL("public.api", c.Public.API, true),
+		validateURL("public.ui", c.Public.UI, true),
+		validateURL("public.links", c.Public.Links, false),
+		c.Matrix.validate(),
+		c.Path.validate(),
+		c.Auth.validate(),
+		c.Parsing.validate(),
+		c.Search.validate(),
+		c.Proxy.validate(),
+		c.Blocklist.validate(),
+	)
+	if c.Matrix.Media.LocalOnly && c.Path.Avatars == "" {
+		errs = append(errs, errors.New("matrix.media.local_only: path.avatars is required"))
+	}
+
+	// optional sections, validated only when set
+	if c.Cache != nil {
+		errs = append(errs, c.Cache.validate())
+	}
+	if c.Webhooks != nil {
+		errs = append(errs, c.Webhooks.validate())
+	}
+	if c.Email != nil {
+		errs = append(errs, c.Email.validate())
+	}
+	if c.Plausible != nil {
+		errs = append(errs, c.Plausible.validate())
+	}
+	return errors.Join(errs...)
+}
+
+// InitOptional initializes missing optional sections (cron, cache, webhooks, email, plausible) as empty ones,
+// so the features of the missing sections are disabled
+func (c *Config) InitOptional() {
+	if c.Cron == nil {
+		c.Cron = &ConfigCron{}
+	}
+	if c.Cache == nil {
+		c.Cache = &ConfigCache{}
+	}
+	if c.Webhooks == nil {
+		c.Webhooks = &ConfigWebhooks{}
+	}
+	if c.Email == nil {
+		c.Email = &ConfigEmail{}
+	}
+	if c.Plausible == nil {
+		c.Plausible = &ConfigPlausible{}
+	}
+}
+
+func (c *ConfigMatrix) validate() error {
+	errs := []error{}
+	if !utils.IsValidServerName(c.ServerName) {
+		errs = append(errs, fmt.Errorf("matrix.server_name: invalid server name %q", c.ServerName))
+	}
+	for _, name := range c.SelfNames {
+		if !utils.IsValidServerName(name) {
+			errs = append(errs, fmt.Errorf("matrix.self_names: invalid server name %q", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *ConfigPaths) validate() error {
+	errs := []error{}
+	if c.Index == "" {
+		errs = append(errs, errors.New("path.index: required"))
+	}
+	if c.Data == "" {
+		errs = append(errs, errors.New("path.data: required"))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *ConfigAuth) validate() error {
+	errs := []error{}
+	if _, err := utils.ParseIPPrefixes(c.Admin.IPs); err != nil {
+		errs = append(errs, fmt.Errorf("auth.admin.ips: %w", err))
+	}
+	if c.AdminToken == "" && (c.Admin.Login == "" || c.Admin.Password == "") {
+		errs = append(errs, errors.New("auth.admin: either auth.admin_token or both auth.admin.login and auth.admin.password are required"))
+	}
+	auths := []struct {
+		name string
+		auth echobasicauth.Auth
+	}{
+		{"auth.metrics", c.Metrics},
+		{"auth.discovery", c.Discovery},
+		{"auth.moderation", c.Moderation},
+	}
+	for _, auth := range auths {
+		if (auth.auth.Login == "") != (auth.auth.Password == "") {
+			errs = append(errs, fmt.Errorf("%s: both login and password are required", auth.name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *ConfigCache) validate() error {
+	errs := []error{validateURL("cache.bunny.url", c.Bunny.URL, c.Bunny.Key != "")}
+	if c.Bunny.URL != "" && c.Bunny.Key == "" {
+		errs = append(errs, errors.New("cache.bunny.key: required when cache.bunny.url is set"))
+	}
+	if (c.Cloudflare.Zone == "") != (c.Cloudflare.Token == "") {
+		errs = append(errs, errors.New("cache.cloudflare: both zone and token are required"))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *ConfigWebhooks) validate() error {
+	return errors.Join(
+		validateURL("webhooks.moderation", c.Moderation, false),
+		validateURL("webhooks.stats", c.Stats, false),
+		validateURL("webhooks.reports", c.Reports, false),
+	)
+}
+
+func (c *ConfigEmail) validate() error {
+	if c.Postmark.Token != "" && c.Postmark.Report.From == "" {
+		return errors.New("email.postmark.report.from: required when email.postmark.server_token is set")
+	}
+	return nil
+}
+
+func (c ConfigProxy) validate() error {
+	errs := []error{
+		validateProxyURL("proxy.url", c.URL),
+		validateProxyURL("proxy.fallback", c.Fallback),
+	}
+	if c.URL == "" && (c.Fallback != "" || c.Direct) {
+		errs = append(errs, errors.New("proxy.url: required when proxy.fallback or proxy.direct is set"))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *ConfigPlausible) validate() error {
+	if (c.Host == "") != (c.Domain == "") {
+		return errors.New("plausible: both host and domain are required")
+	}
+	if strings.Contains(c.Host, "/") {
+		return fmt.Errorf("plausible.host: %q must be a host name, without scheme and path", c.Host)
+	}
+	return nil
+}
+
+func (c *ConfigBlocklist) validate() error {
+	errs := []error{validateURL("blocklist.url", c.URL, false)}
+	if err := c.TLDs.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("blocklist.tlds: %w", err))
+	}
+	for _, pattern := range c.Topics {
+		if _, err := regexp.Compile("(?i)" + pattern); err != nil {
+			errs = append(errs, fmt.Errorf("blocklist.topics: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateProxyURL checks that value is an absolute http(s) or socks5 URL, empty value is allowed
+func validateProxyURL(name, value string) error {
+	if value == "" {
+		return nil
+	}
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("%s: %q is not an absolute http(s) or socks5 URL", name, value)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("%s: %q has no host", name, value)
+	}
+	return nil
+}
+
+// validateURL checks that value is an absolute http(s) URL, empty value is allowed only if not required
+func validateURL(name, value string, required bool) error {
+	if value == "" {
+		if required {
+			return fmt.Errorf("%s: required", name)
+		}
+		return nil
+	}
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%s: %q is not an absolute http(s) URL", name, value)
+	}
+	return nil
 }
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+// minimalConfig returns a valid config with only the required sections
+func minimalConfig() *Config {
+	return &Config{
+		Public:    &ConfigPublic{API: "https://api.example.com", UI: "https://example.com"},
+		Matrix:    &ConfigMatrix{ServerName: "example.com"},
+		Search:    &ConfigSearch{},
+		Path:      &ConfigPaths{Index: "index", Data: "data.db"},
+		Batch:     &ConfigBatch{},
+		Auth:      &ConfigAuth{AdminToken: "token"},
+		Workers:   &ConfigWorkers{},
+		Blocklist: &ConfigBlocklist{},
+	}
+}
+
+func TestValidate_OptionalSections(t *testing.T) {
+	cfg := minimalConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("config without optional sections: %v", err)
+	}
+
+	cfg.InitOptional()
+	if cfg.Cron == nil || cfg.Cache == nil || cfg.Webhooks == nil || cfg.Email == nil || cfg.Plausible == nil {
+		t.Fatal("optional sections are not initialized")
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("config with empty optional sections: %v", err)
+	}
+}
+
+func TestValidate_RequiredSections(t *testing.T) {
+	cfg := minimalConfig()
+	cfg.Public = nil
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "public: section is required") {
+		t.Fatalf("expected missing public section error, got %v", err)
+	}
+}
+
+func TestValidate_OptionalSectionsWhenSet(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutate   func(*Config)
+		expected string
+	}{
+		{"webhooks", func(c *Config) { c.Webhooks = &ConfigWebhooks{Stats: "not a url"} }, "webhooks.stats"},
+		{"email", func(c *Config) { c.Email = &ConfigEmail{Postmark: ConfigEmailPostmark{Token: "token"}} }, "email.postmark.report.from"},
+		{"plausible", func(c *Config) { c.Plausible = &ConfigPlausible{Host: "plausible.io"} }, "plausible"},
+		{"cache", func(c *Config) { c.Cache = &ConfigCache{Bunny: ConfigCacheBunny{URL: "https://cdn.example.com"}} }, "cache.bunny.key"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := minimalConfig()
+			test.mutate(cfg)
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("expected %q error, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestValidate_Proxy(t *testing.T) {
+	tests := []struct {
+		name  string
+		proxy ConfigProxy
+		valid bool
+	}{
+		{"empty", ConfigProxy{}, true},
+		{"http", ConfigProxy{URL: "http://proxy:3128"}, true},
+		{"socks5 with fallback", ConfigProxy{URL: "socks5://proxy:1080", Fallback: "https://proxy2", Direct: true}, true},
+		{"no scheme", ConfigProxy{URL: "proxy:3128"}, false},
+		{"unsupported scheme", ConfigProxy{URL: "ftp://proxy"}, false},
+		{"invalid fallback", ConfigProxy{URL: "http://proxy:3128", Fallback: "http://"}, false},
+		{"fallback without primary", ConfigProxy{Fallback: "http://proxy:3128"}, false},
+		{"direct without primary", ConfigProxy{Direct: true}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := minimalConfig()
+			cfg.Proxy = test.proxy
+			err := cfg.Validate()
+			if (err == nil) != test.valid {
+				t.Errorf("valid: got %v, want %v (%v)", err == nil, test.valid, err)
+			}
+		})
+	}
+}
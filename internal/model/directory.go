@@ -1,5 +1,7 @@
 package model
 
+import "strings"
+
 // RoomDirectoryRequest sent when calling POST /_matrix/federation/v1/publicRooms
 type RoomDirectoryRequest struct {
 	Filter RoomDirectoryFilter `json:"filter"`
@@ -37,10 +39,17 @@ type RoomDirectoryRoom struct {
 	RoomType      string `json:"room_type,omitempty"`
 	Topic         string `json:"topic,omitempty"`
 	WorldReadable bool   `json:"world_readable"`
+
+	// Link is the deep link of the room in MRS responses, namespaced, because it's not a part of the spec
+	Link string `json:"cc.etke.mrs.link,omitempty"`
 }
 
 // Convert room directory's room to matrix room
 func (r *RoomDirectoryRoom) Convert() *MatrixRoom {
+	joinRule := r.JoinRule
+	if joinRule == "" { // as per spec, the room is assumed to be public when join_rule is not present
+		joinRule = "public"
+	}
 	return &MatrixRoom{
 		ID:            r.ID,
 		Alias:         r.Alias,
@@ -49,8 +58,53 @@ func (r *RoomDirectoryRoom) Convert() *MatrixRoom {
 		Avatar:        r.Avatar,
 		Members:       r.Members,
 		RoomType:      r.RoomType,
-		JoinRule:      r.JoinRule,
+		JoinRule:      joinRule,
 		GuestJoinable: r.GuestJoinable,
 		WorldReadable: r.WorldReadable,
 	}
 }
+
+// RoomTypeSpace is the room type of the spaces
+const RoomTypeSpace = "m.space"
+
+// RoomHierarchyResponse of /_matrix/federation/v1/hierarchy/{roomID}
+type RoomHierarchyResponse struct {
+	Room                 *RoomHierarchyRoom   `json:"room"`
+	Children             []*RoomHierarchyRoom `json:"children"`
+	InaccessibleChildren []string             `json:"inaccessible_children"`
+}
+
+// RoomHierarchyRoom is RoomDirectoryRoom with the m.space.child state events of the space
+type RoomHierarchyRoom struct {
+	RoomDirectoryRoom
+	ChildrenState []*RoomHierarchyChild `json:"children_state"`
+}
+
+// RoomHierarchyChild is m.space.child state event, stripped
+type RoomHierarchyChild struct {
+	Type     string `json:"type"`
+	StateKey string `json:"state_key"` // child room ID
+	Content  struct {
+		Via []string `json:"via"`
+	} `json:"content"`
+}
+
+// ChildrenIDs returns unique IDs of the space's children, as per spec, children without via servers are ignored
+func (r *RoomHierarchyRoom) ChildrenIDs() []string {
+	if r == nil {
+		return nil
+	}
+	ids := make([]string, 0, len(r.ChildrenState))
+	seen := make(map[string]struct{}, len(r.ChildrenState))
+	for _, child := range r.ChildrenState {
+		if child == nil || child.Type != "m.space.child" || !strings.HasPrefix(child.StateKey, "!") || len(child.Content.Via) == 0 {
+			continue
+		}
+		if _, ok := seen[child.StateKey]; ok {
+			continue
+		}
+		seen[child.StateKey] = struct{}{}
+		ids = append(ids, child.StateKey)
+	}
+	return ids
+}
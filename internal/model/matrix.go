@@ -12,6 +12,15 @@ import (
 	"github.com/etkecc/mrs/internal/utils"
 )
 
+const (
+	// BlocklistServers is the kind of dynamic blocklist entries containing server names
+	BlocklistServers = "servers"
+	// BlocklistRooms is the kind of dynamic blocklist entries containing room IDs and aliases
+	BlocklistRooms = "rooms"
+	// BlocklistRemote is the kind of blocklist entries containing server names from the last fetched remote blocklist (blocklist.url)
+	BlocklistRemote = "remote"
+)
+
 type BlocklistService interface {
 	ByID(matrixID string) bool
 	ByServer(server string) bool
@@ -29,15 +38,36 @@ func (e MatrixError) Error() string {
 	return fmt.Sprintf("%s (%s): %s", e.HTTP, e.Code, e.Message)
 }
 
+const (
+	// ServerStatusIndexable - server is online and indexable (it still may have no public rooms)
+	ServerStatusIndexable = "indexable"
+	// ServerStatusExcluded - server is online, but excluded from indexing by the instance operator
+	ServerStatusExcluded = "excluded"
+	// ServerStatusNotIndexable - server is online, but rejected by the validation (e.g. robots.txt, opt-out, blocklist)
+	ServerStatusNotIndexable = "not_indexable"
+	// ServerStatusNotFederatable - server name is resolved, but federation API is not available
+	ServerStatusNotFederatable = "not_federatable"
+	// ServerStatusUnreachable - server name cannot be resolved (e.g. timeout, invalid well-known)
+	ServerStatusUnreachable = "unreachable"
+	// ServerStatusQuarantined - server is online and indexable, but newly discovered and waiting for approval
+	ServerStatusQuarantined = "quarantined"
+)
+
 // MatrixServer info
 type MatrixServer struct {
 	Name      string               `json:"name"`
 	URL       string               `json:"url"`
 	Online    bool                 `json:"online"`
 	Indexable bool                 `json:"indexable"`
-	Contacts  MatrixServerContacts `json:"contacts"` // Contacts as per MSC1929
-	OnlineAt  time.Time            `json:"online_at"`
-	UpdatedAt time.Time            `json:"updated_at"` // Deprecated
+	Excluded  bool                 `json:"excluded"`             // Excluded from indexing by the instance operator
+	Contacts  MatrixServerContacts `json:"contacts"`             // Contacts as per MSC1929
+	Status    string               `json:"status"`               // Result of the last discovery, one of ServerStatus* constants
+	LastError string               `json:"last_error,omitempty"` // Reason of the Status, if the server is not indexable
+	// Quarantined servers are newly discovered ones held for review, their rooms are not parsed until approved
+	Quarantined  bool      `json:"quarantined"`
+	DiscoveredAt time.Time `json:"discovered_at"` // first discovery, zero for servers discovered before the field was introduced
+	OnlineAt     time.Time `json:"online_at"`
+	UpdatedAt    time.Time `json:"updated_at"` // Deprecated
 }
 
 // MatrixServerContacts - MSC1929
@@ -69,6 +99,12 @@ type MatrixRoom struct {
 	Language  string    `json:"language"`
 	AvatarURL string    `json:"avatar_url_http"`
 	ParsedAt  time.Time `json:"parsed_at"`
+	// LastActive is a crude activity signal: the last time the members count was changed between parsing runs,
+	// because room directory doesn't provide any activity information
+	LastActive time.Time `json:"last_active"`
+	// FirstSeen is the time when the room was stored in the catalog for the first time (parsed from any server),
+	// it is preserved when the room is re-parsed, but reset if the room was removed from the catalog (e.g. delisted) and found again later
+	FirstSeen time.Time `json:"first_seen"`
 }
 
 // Entry converts matrix room to search entry
@@ -88,6 +124,8 @@ func (r *MatrixRoom) Entry() *Entry {
 		JoinRule:      r.JoinRule,
 		GuestJoinable: r.GuestJoinable,
 		WorldReadable: r.WorldReadable,
+		LastActive:    r.LastActive,
+		FirstSeen:     r.FirstSeen,
 	}
 }
 
@@ -107,8 +145,22 @@ func (r *MatrixRoom) DirectoryEntry() *RoomDirectoryRoom {
 	}
 }
 
+// AvatarURL converts mxc://server/mediaID URI into HTTP URL of the MRS /avatar endpoint,
+// returns empty string if the URI is malformed
+func AvatarURL(mrsPublicURL, mxc string) string {
+	server, mediaID, ok := utils.ParseMXC(mxc)
+	if !ok {
+		return ""
+	}
+	base, err := url.Parse(mrsPublicURL)
+	if err != nil {
+		return ""
+	}
+	return base.JoinPath("/avatar", server, mediaID).String()
+}
+
 // Parse matrix room info to prepare custom fields
-func (r *MatrixRoom) Parse(detector lingua.LanguageDetector, mrsPublicURL string) {
+func (r *MatrixRoom) Parse(detector lingua.LanguageDetector, mrsPublicURL string, topicCfg ConfigParsingTopic) {
 	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
 	defer cancel()
 
@@ -117,7 +169,7 @@ func (r *MatrixRoom) Parse(detector lingua.LanguageDetector, mrsPublicURL string
 		return
 	}
 
-	r.Topic = utils.Truncate(r.Topic, 400)
+	r.parseTopic(topicCfg)
 	if ctx.Err() != nil {
 		return
 	}
@@ -135,6 +187,52 @@ func (r *MatrixRoom) Parse(detector lingua.LanguageDetector, mrsPublicURL string
 	r.parseLanguage(detector)
 }
 
+// SetLastActive updates the LastActive signal, based on the previously parsed version of the room
+func (r *MatrixRoom) SetLastActive(previous *MatrixRoom) {
+	if previous == nil || previous.Members != r.Members || previous.LastActive.IsZero() {
+		r.LastActive = r.ParsedAt
+		return
+	}
+	r.LastActive = previous.LastActive
+}
+
+// SetFirstSeen preserves the FirstSeen timestamp of the previously parsed version of the room.
+// Rooms stored before the FirstSeen was introduced get the previous parsing time, as the best known approximation
+func (r *MatrixRoom) SetFirstSeen(previous *MatrixRoom) {
+	switch {
+	case previous == nil:
+		r.FirstSeen = r.ParsedAt
+	case !previous.FirstSeen.IsZero():
+		r.FirstSeen = previous.FirstSeen
+	case !previous.ParsedAt.IsZero():
+		r.FirstSeen = previous.ParsedAt
+	default:
+		r.FirstSeen = r.ParsedAt
+	}
+}
+
+// Validate checks if room ID, alias and server have valid format
+func (r *MatrixRoom) Validate() error {
+	if !strings.HasPrefix(r.ID, "!") || utils.ServerFrom(r.ID) == "" {
+		return fmt.Errorf("invalid room ID %q", r.ID)
+	}
+	if !utils.IsValidServerName(r.Server) {
+		return fmt.Errorf("invalid server %q", r.Server)
+	}
+	if r.Alias == "" {
+		return nil
+	}
+
+	idx := strings.Index(r.Alias, ":")
+	if !strings.HasPrefix(r.Alias, "#") || idx < 2 {
+		return fmt.Errorf("invalid alias %q", r.Alias)
+	}
+	if !utils.IsValidServerName(r.Alias[idx+1:]) {
+		return fmt.Errorf("invalid alias server %q", r.Alias)
+	}
+	return nil
+}
+
 // Servers returns all servers from the room object, except own server
 func (r *MatrixRoom) Servers(ownServerName string) []string {
 	servers := []string{}
@@ -151,6 +249,15 @@ func (r *MatrixRoom) Servers(ownServerName string) []string {
 	return utils.Uniq(servers)
 }
 
+// parseTopic sanitizes the topic: removes control characters, HTML (if enabled), and truncates it
+func (r *MatrixRoom) parseTopic(cfg ConfigParsingTopic) {
+	topic := utils.StripControl(r.Topic)
+	if cfg.StripHTML {
+		topic = utils.StripHTML(topic)
+	}
+	r.Topic = utils.Truncate(strings.TrimSpace(topic), cfg.GetMaxLength())
+}
+
 // parseServer from room ID
 func (r *MatrixRoom) parseServer() {
 	parts := strings.SplitN(r.ID, ":", 2)
@@ -165,18 +272,16 @@ func (r *MatrixRoom) parseLanguage(detector lingua.LanguageDetector) {
 	r.Language, _ = utils.DetectLanguage(detector, r.Name+" "+r.Topic)
 }
 
-// parseAvatar builds HTTP URL to access room avatar
+// parseAvatar builds HTTP URL to access room avatar through the MRS /avatar endpoint,
+// which resolves the media server of the avatar's origin (using well-known) when requested.
+// Malformed MXC URIs result in empty URL
 func (r *MatrixRoom) parseAvatar(mrsPublicURL string) {
-	if r.Avatar == "" {
-		return
-	}
-	parts := strings.Split(strings.TrimPrefix(r.Avatar, "mxc://"), "/")
-	if len(parts) != 2 {
-		return
-	}
-	base, err := url.Parse(mrsPublicURL)
-	if err != nil {
-		return
-	}
-	r.AvatarURL = base.JoinPath("/avatar", parts[0], parts[1]).String()
+	r.AvatarURL = AvatarURL(mrsPublicURL, r.Avatar)
+}
+
+// RoomReport describes report of a room
+type RoomReport struct {
+	Reason     string     `json:"reason"`
+	ReportedBy string     `json:"reported_by,omitempty"` // reporter's IP or login (if authenticated)
+	ReportedAt *time.Time `json:"reported_at,omitempty"` // nil for reports stored before the reporter metadata was introduced
 }
@@ -0,0 +1,62 @@
+package model
+
+import "time"
+
+const (
+	// QueryMatch - analyzed full-text match of the value in the field
+	QueryMatch = "match"
+	// QueryPhrase - analyzed full-text match of the value as a phrase (terms in order) in the field
+	QueryPhrase = "phrase"
+	// QueryFuzzy - match of the value in the field, tolerating typos
+	QueryFuzzy = "fuzzy"
+	// QueryTerm - exact (not analyzed) match of the value in the field
+	QueryTerm = "term"
+	// QueryNumericRange - numeric field within [Min, Max], nil boundary = unbounded
+	QueryNumericRange = "numeric_range"
+	// QueryDateRange - datetime field within [After, Before), zero boundary = unbounded
+	QueryDateRange = "date_range"
+	// QueryAnd - all of the Queries must match
+	QueryAnd = "and"
+	// QueryOr - at least one of the Queries must match
+	QueryOr = "or"
+	// QueryNumericBoost - matches of the Queries[0] with the score multiplied by
+	// 1 + Boost × min(1, log10(1 + value) / log10(1 + Max)), where value is the numeric Field of the match
+	QueryNumericBoost = "numeric_boost"
+)
+
+// Query is a backend-neutral search query spec, built by the search service
+// and translated into the native query by the search backend (SearchRepository implementation)
+type Query struct {
+	Kind     string   `json:"kind"` // one of Query* constants
+	Field    string   `json:"field,omitempty"`
+	Value    string   `json:"value,omitempty"`
+	Analyzer string   `json:"analyzer,omitempty"` // match and phrase queries only, empty = field's analyzer
+	Boost    float64  `json:"boost,omitempty"`    // score multiplier of the matches, field queries only
+	Queries  []*Query `json:"queries,omitempty"`  // and/or queries only
+
+	Min    *float64  `json:"min,omitempty"` // numeric range only
+	Max    *float64  `json:"max,omitempty"` // numeric range, numeric boost (value of the full boost)
+	After  time.Time `json:"after"`         // date range only, inclusive, zero = unbounded
+	Before time.Time `json:"before"`        // date range only, exclusive, zero = unbounded
+}
+
+// NewFieldQuery creates match, phrase, fuzzy, or term query of the value in the field
+func NewFieldQuery(kind, field, value string, boost float64) *Query {
+	return &Query{Kind: kind, Field: field, Value: value, Boost: boost}
+}
+
+// NewAndQuery creates query matching all the queries
+func NewAndQuery(queries ...*Query) *Query {
+	return &Query{Kind: QueryAnd, Queries: queries}
+}
+
+// NewOrQuery creates query matching at least one of the queries
+func NewOrQuery(queries ...*Query) *Query {
+	return &Query{Kind: QueryOr, Queries: queries}
+}
+
+// NewNumericBoostQuery creates query boosting the matches of the query by the log-scaled numeric field,
+// matches with the field value of saturation (or more) get the full weight boost
+func NewNumericBoostQuery(q *Query, field string, weight, saturation float64) *Query {
+	return &Query{Kind: QueryNumericBoost, Field: field, Boost: weight, Max: &saturation, Queries: []*Query{q}}
+}
@@ -0,0 +1,75 @@
+package model
+
+import "time"
+
+const (
+	// RunDiscovery - servers discovery job
+	RunDiscovery = "discovery"
+	// RunParsing - rooms parsing job
+	RunParsing = "parsing"
+	// RunIndexing - search index ingestion job
+	RunIndexing = "indexing"
+	// RunFull - full data pipeline: discovery, parsing, and indexing
+	RunFull = "full"
+)
+
+// RunSummary is machine-readable result of a data pipeline job
+type RunSummary struct {
+	Job        string        `json:"job"` // one of Run* constants
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Took       string        `json:"took"`
+	Skipped    bool          `json:"skipped,omitempty"` // the job was already in progress, so the run was ignored
+	Servers    *RunServers   `json:"servers,omitempty"`
+	Rooms      *RunRooms     `json:"rooms,omitempty"`
+	Errors     []string      `json:"errors,omitempty"`
+	Steps      []*RunSummary `json:"steps,omitempty"` // summaries of the steps of the full pipeline
+}
+
+// RunServers - servers processed during the run
+type RunServers struct {
+	Total      int `json:"total"`                // discovery: servers checked, parsing: servers parsed
+	Online     int `json:"online,omitempty"`     // discovery only
+	Offline    int `json:"offline,omitempty"`    // discovery only
+	Indexable  int `json:"indexable,omitempty"`  // discovery only
+	Completed  int `json:"completed,omitempty"`  // parsing only: servers with fully parsed room directory
+	Discovered int `json:"discovered,omitempty"` // parsing only: new servers found in the parsed rooms
+}
+
+// RunRooms - rooms processed during the run
+type RunRooms struct {
+	Parsed            int `json:"parsed,omitempty"`              // parsing only: rooms stored in the catalog
+	Rejected          int `json:"rejected,omitempty"`            // parsing only: rooms with invalid format
+	DroppedByLanguage int `json:"dropped_by_language,omitempty"` // parsing only: rooms in not allowed languages
+	Spaces            int `json:"spaces,omitempty"`              // parsing only: spaces with stored children
+	Removed           int `json:"removed,omitempty"`             // parsing: stale and delisted rooms removed from the catalog, indexing: gone rooms removed from the index
+	Indexed           int `json:"indexed,omitempty"`             // indexing only: new and changed rooms
+	Unchanged         int `json:"unchanged,omitempty"`           // indexing only: rooms skipped by the incremental ingest
+	AliasCollisions   int `json:"alias_collisions,omitempty"`    // indexing only
+}
+
+// PauseState - pause state of the data pipeline, while paused, no new servers are dispatched by discovery and parsing
+type PauseState struct {
+	Paused   bool       `json:"paused"`
+	PausedAt *time.Time `json:"paused_at,omitempty"`
+}
+
+// NewRunSummary starts summary of the job run
+func NewRunSummary(job string) *RunSummary {
+	return &RunSummary{Job: job, StartedAt: time.Now().UTC()}
+}
+
+// AddError records non-fatal error of the run
+func (s *RunSummary) AddError(err error) {
+	if err == nil {
+		return
+	}
+	s.Errors = append(s.Errors, err.Error())
+}
+
+// Finish records the end of the run
+func (s *RunSummary) Finish() *RunSummary {
+	s.FinishedAt = time.Now().UTC()
+	s.Took = s.FinishedAt.Sub(s.StartedAt).String()
+	return s
+}
@@ -1,5 +1,136 @@
 package model
 
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/etkecc/mrs/internal/utils"
+)
+
+// ErrInvalidCursor is returned when a cursor is malformed or doesn't belong to the search request
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// DefaultRoomLinks is the default base URL of the room deep links
+const DefaultRoomLinks = "https://matrix.to/#/"
+
+const (
+	// SearchModePhrase matches multi-word query as a phrase (default)
+	SearchModePhrase = "phrase"
+	// SearchModeAnd matches each word of multi-word query separately, all of them must be present
+	SearchModeAnd = "and"
+)
+
+// SearchFields are fields used for full-text search
+var SearchFields = []string{"name", "alias", "topic", "server"}
+
+// SearchOptions are optional search parameters, zero value means defaults
+type SearchOptions struct {
+	Mode   string             // multi-word query mode, SearchModePhrase or SearchModeAnd
+	Fields []string           // restrict full-text search to the fields, default: all SearchFields
+	Boost  map[string]float64 // per-field boost overrides
+	Cursor string             // opaque cursor of the previous page (see EncodeCursor), replaces offset
+
+	MinMembers int // min joined members (inclusive), 0 = unbounded
+	MaxMembers int // max joined members (inclusive), 0 = unbounded
+
+	SeenAfter  time.Time // rooms first seen at or after that time (see MatrixRoom.FirstSeen), zero = unbounded
+	SeenBefore time.Time // rooms first seen before that time (exclusive), zero = unbounded
+}
+
+// HasMembersRange returns true if the results are restricted by the joined members count
+func (o SearchOptions) HasMembersRange() bool {
+	return o.MinMembers > 0 || o.MaxMembers > 0
+}
+
+// HasFirstSeenRange returns true if the results are restricted by the first seen time
+func (o SearchOptions) HasFirstSeenRange() bool {
+	return !o.SeenAfter.IsZero() || !o.SeenBefore.IsZero()
+}
+
+// Validate search options
+func (o SearchOptions) Validate() error {
+	if o.Mode != "" && o.Mode != SearchModePhrase && o.Mode != SearchModeAnd {
+		return fmt.Errorf("unknown search mode %q", o.Mode)
+	}
+	for _, field := range o.Fields {
+		if !slices.Contains(SearchFields, field) {
+			return fmt.Errorf("unknown search field %q", field)
+		}
+	}
+	for field, boost := range o.Boost {
+		if !slices.Contains(SearchFields, field) {
+			return fmt.Errorf("unknown boost field %q", field)
+		}
+		if boost <= 0 {
+			return fmt.Errorf("boost of %q must be positive", field)
+		}
+	}
+	if o.Cursor != "" {
+		if _, err := DecodeCursor(o.Cursor); err != nil {
+			return err
+		}
+	}
+	if o.MinMembers < 0 || o.MaxMembers < 0 {
+		return fmt.Errorf("min_members and max_members must not be negative")
+	}
+	if o.MaxMembers > 0 && o.MinMembers > o.MaxMembers {
+		return fmt.Errorf("min_members must not be greater than max_members")
+	}
+	if !o.SeenAfter.IsZero() && !o.SeenBefore.IsZero() && !o.SeenAfter.Before(o.SeenBefore) {
+		return fmt.Errorf("first_seen_after must be before first_seen_before")
+	}
+	return nil
+}
+
+// String representation of the options, suitable for cache keys
+func (o SearchOptions) String() string {
+	boosts := make([]string, 0, len(o.Boost))
+	for field, boost := range o.Boost {
+		boosts = append(boosts, field+"="+strconv.FormatFloat(boost, 'f', -1, 64))
+	}
+	sort.Strings(boosts)
+	return o.Mode + "|" + strings.Join(o.Fields, ",") + "|" + strings.Join(boosts, ",") + "|" + o.Cursor +
+		"|" + strconv.Itoa(o.MinMembers) + "-" + strconv.Itoa(o.MaxMembers) +
+		"|" + strconv.FormatInt(o.SeenAfter.Unix(), 10) + "-" + strconv.FormatInt(o.SeenBefore.Unix(), 10)
+}
+
+// EncodeCursor encodes sort values of the last hit of the page into opaque cursor:
+// base64url (without padding) of the JSON array of the sort values, as returned by the search index (e.g., prefix-coded numbers)
+func EncodeCursor(sortValues []string) string {
+	if len(sortValues) == 0 {
+		return ""
+	}
+	datab, err := json.Marshal(sortValues)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(datab)
+}
+
+// DecodeCursor decodes cursor created by EncodeCursor into sort values
+func DecodeCursor(cursor string) ([]string, error) {
+	datab, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	var sortValues []string
+	if err := json.Unmarshal(datab, &sortValues); err != nil || len(sortValues) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return sortValues, nil
+}
+
 // Entry represents indexable and/or indexed matrix room
 type Entry struct {
 	ID            string `json:"id" yaml:"id"`
@@ -16,6 +147,99 @@ type Entry struct {
 	JoinRule      string `json:"join_rule" yaml:"join_rule"`
 	GuestJoinable bool   `json:"guest_can_join" yaml:"guest_can_join"`
 	WorldReadable bool   `json:"world_readable" yaml:"world_readable"`
+
+	LastActive time.Time `json:"last_active" yaml:"last_active"` // see MatrixRoom.LastActive
+	FirstSeen  time.Time `json:"first_seen" yaml:"first_seen"`   // see MatrixRoom.FirstSeen
+
+	// Tags curated by admins, see Crawler.SetRoomTags
+	Tags []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+
+	// Link is the deep link of the room (see DeepLink), set on search results only, not indexed
+	Link string `json:"link,omitempty" yaml:"-"`
+
+	// Sort values of the search hit, used to build pagination cursor, not indexed
+	Sort []string `json:"-" yaml:"-"`
+}
+
+// EntryFields are JSON field names of the Entry, used to validate search results projection
+var EntryFields = entryFields()
+
+func entryFields() []string {
+	t := reflect.TypeOf(Entry{})
+	fields := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// Project returns only the requested fields of the entry, fields must be valid EntryFields
+func (r *Entry) Project(fields []string) (map[string]any, error) {
+	datab, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	var full map[string]any
+	if err := json.Unmarshal(datab, &full); err != nil {
+		return nil, err
+	}
+	projected := make(map[string]any, len(fields))
+	for _, field := range fields {
+		projected[field] = full[field]
+	}
+	return projected, nil
+}
+
+// Hash returns content hash of the entry, used to detect changed rooms between ingests
+func (r *Entry) Hash() uint64 {
+	h := fnv.New64a()
+	json.NewEncoder(h).Encode(r) //nolint:errcheck // hash writer never fails
+	return h.Sum64()
+}
+
+// Size returns serialized (JSON) size of the entry in bytes, a rough estimate of its indexing memory footprint
+func (r *Entry) Size() int {
+	datab, err := json.Marshal(r)
+	if err != nil {
+		return 0
+	}
+	return len(datab)
+}
+
+// BleveType returns document type for the search index,
+// rooms with detected language are indexed using language-specific mapping
+func (r *Entry) BleveType() string {
+	if r.Language == "" || r.Language == utils.UnknownLang {
+		return r.Type
+	}
+	return r.Type + "_" + strings.ToLower(r.Language)
+}
+
+// DeepLink builds link to the room: base URL + room alias, or room ID with the via parameter,
+// if the room has no alias. Empty or invalid (non-http) base URL is replaced with DefaultRoomLinks
+func (r *Entry) DeepLink(baseURL string) string {
+	if !isHTTPURL(baseURL) {
+		baseURL = DefaultRoomLinks
+	}
+	if r.Alias != "" {
+		return baseURL + r.Alias
+	}
+	link := baseURL + r.ID
+	if server := utils.ServerFrom(r.ID); server != "" {
+		link += "?via=" + url.QueryEscape(server)
+	}
+	return link
+}
+
+func isHTTPURL(uri string) bool {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "https" || parsed.Scheme == "http") && parsed.Host != ""
 }
 
 // IsBlocked checks if room's server is blocked
@@ -45,5 +269,6 @@ func (r *Entry) RoomDirectory() *RoomDirectoryRoom {
 		JoinRule:      r.JoinRule,
 		GuestJoinable: r.GuestJoinable,
 		WorldReadable: r.WorldReadable,
+		Link:          r.Link,
 	}
 }
@@ -0,0 +1,31 @@
+package model
+
+import "testing"
+
+func TestEntry_DeepLink(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    *Entry
+		baseURL  string
+		expected string
+	}{
+		{"alias", &Entry{ID: "!room:example.com", Alias: "#room:example.com"}, "", "https://matrix.to/#/#room:example.com"},
+		{"id only", &Entry{ID: "!room:example.com"}, "", "https://matrix.to/#/!room:example.com?via=example.com"},
+		{"custom", &Entry{ID: "!room:example.com", Alias: "#room:example.com"}, "https://app.element.io/#/room/", "https://app.element.io/#/room/#room:example.com"},
+		{"invalid base", &Entry{ID: "!room:example.com"}, "matrix.to/#/", "https://matrix.to/#/!room:example.com?via=example.com"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if link := test.entry.DeepLink(test.baseURL); link != test.expected {
+				t.Errorf("got %q, want %q", link, test.expected)
+			}
+		})
+	}
+}
+
+func TestEntry_RoomDirectoryLink(t *testing.T) {
+	entry := &Entry{ID: "!room:example.com", Link: "https://matrix.to/#/!room:example.com?via=example.com"}
+	if link := entry.RoomDirectory().Link; link != entry.Link {
+		t.Errorf("got %q, want %q", link, entry.Link)
+	}
+}
@@ -1,6 +1,9 @@
 package model
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // IndexStats structure
 type IndexStats struct {
@@ -11,6 +14,12 @@ type IndexStats struct {
 	Indexing  IndexStatsTime    `json:"indexing"`
 }
 
+// IndexStatus - index stats along with the pause state of the data pipeline, served by the admin status endpoint
+type IndexStatus struct {
+	*IndexStats
+	Pause *PauseState `json:"pause"`
+}
+
 // IndexStatsServers structure
 type IndexStatsServers struct {
 	Online    int `json:"online"`
@@ -31,3 +40,118 @@ type IndexStatsTime struct {
 	StartedAt  time.Time `json:"started_at"`
 	FinishedAt time.Time `json:"finished_at"`
 }
+
+// IndexStatsDiff describes changes between two stats snapshots,
+// positive values mean growth, negative - decline
+type IndexStatsDiff struct {
+	From    time.Time         `json:"from"`
+	To      time.Time         `json:"to"`
+	Servers IndexStatsServers `json:"servers"`
+	Rooms   IndexStatsRooms   `json:"rooms"`
+}
+
+// NewIndexStatsDiff calculates field-wise difference between the from and to stats snapshots
+func NewIndexStatsDiff(fromAt time.Time, from *IndexStats, toAt time.Time, to *IndexStats) *IndexStatsDiff {
+	return &IndexStatsDiff{
+		From: fromAt,
+		To:   toAt,
+		Servers: IndexStatsServers{
+			Online:    to.Servers.Online - from.Servers.Online,
+			Indexable: to.Servers.Indexable - from.Servers.Indexable,
+			Blocked:   to.Servers.Blocked - from.Servers.Blocked,
+		},
+		Rooms: IndexStatsRooms{
+			Indexed:  to.Rooms.Indexed - from.Rooms.Indexed,
+			Parsed:   to.Rooms.Parsed - from.Rooms.Parsed,
+			Banned:   to.Rooms.Banned - from.Rooms.Banned,
+			Reported: to.Rooms.Reported - from.Rooms.Reported,
+		},
+	}
+}
+
+// IndexInfo structure, describes the search index itself
+type IndexInfo struct {
+	DocCount   uint64         `json:"doc_count"`
+	SizeOnDisk int64          `json:"size_on_disk"`
+	Stats      map[string]any `json:"stats"`
+}
+
+// RoomInspection describes everything MRS knows about the room, used for debugging
+type RoomInspection struct {
+	Room         *MatrixRoom `json:"room"`                    // stored room, nil if not in the catalog
+	Entry        *Entry      `json:"entry"`                   // computed search entry, nil if not in the catalog
+	Servers      []string    `json:"servers"`                 // servers advertising the room in their room directories
+	Children     []string    `json:"children,omitempty"`      // children of the space, if spaces hierarchy parsing is enabled
+	Indexed      bool        `json:"indexed"`                 // room is in the search index
+	Blocked      bool        `json:"blocked"`                 // room or its server is in the blocklist
+	Banned       bool        `json:"banned"`                  // room is banned by moderators
+	Reported     bool        `json:"reported"`                // room has been reported
+	ReportReason string      `json:"report_reason,omitempty"` // reason of the report
+	ReportedBy   string      `json:"reported_by,omitempty"`   // reporter's IP or login
+	ReportedAt   *time.Time  `json:"reported_at,omitempty"`   // time of the report, if known
+}
+
+// IsEmpty returns true if the room is unknown
+func (r *RoomInspection) IsEmpty() bool {
+	return r.Room == nil && !r.Indexed && !r.Banned && !r.Reported
+}
+
+// ServerPurge describes what was removed with the server
+type ServerPurge struct {
+	Server  string `json:"server"`
+	Found   bool   `json:"found"`   // server was known
+	Rooms   int    `json:"rooms"`   // rooms removed from the catalog
+	Indexed int    `json:"indexed"` // rooms removed from the search index
+}
+
+// CatalogRebuild is the result of the servers rooms catalog rebuild
+type CatalogRebuild struct {
+	Servers int    `json:"servers"` // servers with at least one room
+	Rooms   int    `json:"rooms"`   // rooms grouped by servers
+	Exposed int    `json:"exposed"` // servers exposed in the public catalog (/catalog/servers), with at least 100 rooms
+	Took    string `json:"took"`
+}
+
+// ServerRoomsCount is the count of rooms of the server
+type ServerRoomsCount struct {
+	Server string `json:"server"`
+	Rooms  int    `json:"rooms"`
+}
+
+// ServersFilter restricts the servers list, zero value matches all servers
+type ServersFilter struct {
+	Online      *bool  // nil = any
+	Indexable   *bool  // nil = any
+	Quarantined *bool  // nil = any
+	Name        string // case-insensitive substring of the server name, empty = any
+}
+
+// Match checks if server matches the filter
+func (f ServersFilter) Match(server *MatrixServer) bool {
+	if server == nil {
+		return false
+	}
+	if f.Online != nil && server.Online != *f.Online {
+		return false
+	}
+	if f.Indexable != nil && server.Indexable != *f.Indexable {
+		return false
+	}
+	if f.Quarantined != nil && server.Quarantined != *f.Quarantined {
+		return false
+	}
+	return f.Name == "" || strings.Contains(strings.ToLower(server.Name), strings.ToLower(f.Name))
+}
+
+// ServersList is a page of the known servers, sorted by name
+type ServersList struct {
+	Total   int             `json:"total"` // servers matching the filter
+	Servers []*MatrixServer `json:"servers"`
+}
+
+// ServersReport lists servers that need attention
+type ServersReport struct {
+	Offline      []string `json:"offline"`       // dead servers
+	NotIndexable []string `json:"not_indexable"` // online servers that are not indexable (e.g. opted out via robots.txt)
+	NoRooms      []string `json:"no_rooms"`      // online and indexable servers without public rooms (e.g. private room directory)
+}
@@ -2,6 +2,7 @@ package batch
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 
@@ -13,43 +14,107 @@ import (
 // Batch struct
 type Batch[T any] struct {
 	mu        sync.Mutex
-	flushfunc func(ctx context.Context, items []T)
+	flushfunc func(ctx context.Context, items []T) error
+	errs      []error // errors of the automatic flushes, returned by the next Flush call
 	data      []T
 	size      int
+	stop      chan struct{} // nil = interval flush is disabled
+	stopped   chan struct{}
+	stopOnce  sync.Once
 }
 
-// New creates new batch object
-func New[T any](size int, flushfunc func(ctx context.Context, items []T)) *Batch[T] {
-	return &Batch[T]{
+// New creates new batch object.
+// If interval is set, buffered items are flushed on that interval as well, even if the batch is not full yet
+func New[T any](size int, interval time.Duration, flushfunc func(ctx context.Context, items []T) error) *Batch[T] {
+	b := &Batch[T]{
 		data:      make([]T, 0, size),
 		flushfunc: flushfunc,
 		size:      size,
 	}
+	if interval > 0 {
+		b.stop = make(chan struct{})
+		b.stopped = make(chan struct{})
+		go b.flushOnInterval(interval)
+	}
+	return b
+}
+
+// Close stops the interval flush (if enabled) and waits for the running one to finish, buffered items are not flushed
+func (b *Batch[T]) Close() {
+	if b.stop == nil {
+		return
+	}
+	b.stopOnce.Do(func() {
+		close(b.stop)
+	})
+	<-b.stopped
 }
 
-// Add items from channel to batch and automatically flush them
+// flushOnInterval flushes non-empty batch on each tick, until the batch is closed
+func (b *Batch[T]) flushOnInterval(interval time.Duration) {
+	defer close(b.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			b.mu.Lock()
+			if len(b.data) > 0 {
+				b.errs = appendErr(b.errs, b.flush(utils.NewContext()))
+			}
+			b.mu.Unlock()
+		}
+	}
+}
+
+// Add items from channel to batch and automatically flush them.
+// Size check and flush are done under the same lock, so concurrent calls can't flush the same items twice.
+// Errors of the automatic flushes are accumulated and returned by the next Flush call
 func (b *Batch[T]) Add(ctx context.Context, item T) {
 	b.mu.Lock()
-	b.data = append(b.data, item)
-	b.mu.Unlock()
+	defer b.mu.Unlock()
 
+	b.data = append(b.data, item)
 	if len(b.data) >= b.size {
-		b.Flush(ctx)
+		b.errs = appendErr(b.errs, b.flush(ctx))
 	}
 }
 
-// Flush / store batch
-func (b *Batch[T]) Flush(ctx context.Context) {
+// Flush / store batch, returns its error joined with errors of the automatic flushes since the previous Flush call
+func (b *Batch[T]) Flush(ctx context.Context) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	errs := appendErr(b.errs, b.flush(ctx))
+	b.errs = nil
+	return errors.Join(errs...)
+}
+
+// flush stores batch, must be called under lock.
+// Items are dropped even if flushfunc fails, to not retry the same failing batch forever
+func (b *Batch[T]) flush(ctx context.Context) error {
 	span := utils.StartSpan(ctx, "batch.Flush")
 	defer span.Finish()
 	log := zerolog.Ctx(span.Context())
 
 	started := time.Now().UTC()
 	log.Info().Int("len", len(b.data)).Msg("storing data batch")
-	b.flushfunc(span.Context(), b.data)
-	log.Info().Int("len", len(b.data)).Str("took", time.Since(started).String()).Msg("stored data batch")
+	err := b.flushfunc(span.Context(), b.data)
+	if err != nil {
+		log.Error().Err(err).Int("len", len(b.data)).Str("took", time.Since(started).String()).Msg("cannot store data batch")
+	} else {
+		log.Info().Int("len", len(b.data)).Str("took", time.Since(started).String()).Msg("stored data batch")
+	}
 	b.data = make([]T, 0, b.size)
+	return err
+}
+
+// appendErr appends non-nil error to the list
+func appendErr(errs []error, err error) []error {
+	if err == nil {
+		return errs
+	}
+	return append(errs, err)
 }
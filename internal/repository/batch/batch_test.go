@@ -0,0 +1,53 @@
+package batch
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestAdd_Concurrent(t *testing.T) {
+	const size = 10
+	const workers = 20
+	const perWorker = 105
+
+	flushed := map[int]int{}
+	var sizes []int
+	b := New(size, 0, func(_ context.Context, items []int) error {
+		sizes = append(sizes, len(items)) // flushfunc is called under the batch lock
+		for _, item := range items {
+			flushed[item]++
+		}
+		return nil
+	})
+	defer b.Close()
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range perWorker {
+				b.Add(context.Background(), w*perWorker+n)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := b.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(flushed) != workers*perWorker {
+		t.Errorf("flushed items: got %d, want %d", len(flushed), workers*perWorker)
+	}
+	for item, count := range flushed {
+		if count != 1 {
+			t.Errorf("item %d is flushed %d times", item, count)
+		}
+	}
+	for i, flushSize := range sizes[:len(sizes)-1] {
+		if flushSize != size {
+			t.Errorf("flush %d: got %d items, want %d", i, flushSize, size)
+		}
+	}
+}
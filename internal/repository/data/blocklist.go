@@ -0,0 +1,86 @@
+package data
+
+import (
+	"context"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/etkecc/mrs/internal/model"
+	"github.com/etkecc/mrs/internal/utils"
+)
+
+func blocklistBucket(kind string) ([]byte, error) {
+	switch kind {
+	case model.BlocklistServers:
+		return blocklistServersBucket, nil
+	case model.BlocklistRooms:
+		return blocklistRoomsBucket, nil
+	case model.BlocklistRemote:
+		return blocklistRemoteBucket, nil
+	default:
+		return nil, fmt.Errorf("unknown blocklist kind: %s", kind)
+	}
+}
+
+// GetBlocklist returns all dynamic blocklist entries of the given kind
+func (d *Data) GetBlocklist(ctx context.Context, kind string) ([]string, error) {
+	span := utils.StartSpan(ctx, "data.GetBlocklist")
+	defer span.Finish()
+
+	bucket, err := blocklistBucket(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []string{}
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(k, _ []byte) error {
+			list = append(list, string(k))
+			return nil
+		})
+	})
+	return list, err
+}
+
+// AddToBlocklist stores entries of the given kind in the dynamic blocklist
+func (d *Data) AddToBlocklist(ctx context.Context, kind string, entries []string) error {
+	span := utils.StartSpan(ctx, "data.AddToBlocklist")
+	defer span.Finish()
+
+	bucket, err := blocklistBucket(kind)
+	if err != nil {
+		return err
+	}
+
+	return d.db.Batch(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucket)
+		for _, entry := range entries {
+			if err := b.Put([]byte(entry), []byte(`true`)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveFromBlocklist removes entries of the given kind from the dynamic blocklist
+func (d *Data) RemoveFromBlocklist(ctx context.Context, kind string, entries []string) error {
+	span := utils.StartSpan(ctx, "data.RemoveFromBlocklist")
+	defer span.Finish()
+
+	bucket, err := blocklistBucket(kind)
+	if err != nil {
+		return err
+	}
+
+	return d.db.Batch(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucket)
+		for _, entry := range entries {
+			if err := b.Delete([]byte(entry)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
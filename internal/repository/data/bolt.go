@@ -33,8 +33,32 @@ var (
 	// index_timeline bucket
 	// contains index stats by date
 	indexTLBucket = []byte(`index_timeline`)
+	// blocklist_servers bucket
+	// contains servers added to the blocklist at runtime
+	blocklistServersBucket = []byte(`blocklist_servers`)
+	// blocklist_rooms bucket
+	// contains room IDs and aliases added to the blocklist at runtime
+	blocklistRoomsBucket = []byte(`blocklist_rooms`)
+	// blocklist_remote bucket
+	// contains servers of the last fetched remote blocklist
+	blocklistRemoteBucket = []byte(`blocklist_remote`)
+	// seed_servers bucket
+	// contains seed servers list set at runtime, overrides the config one
+	seedServersBucket = []byte(`seed_servers`)
+	// rooms_hashes bucket
+	// contains content hashes of the indexed rooms, used for incremental ingest
+	roomsHashesBucket = []byte(`rooms_hashes`)
+	// rooms_servers bucket
+	// contains mapping room_id -> servers advertising the room in their room directories
+	roomsServersBucket = []byte(`rooms_servers`)
+	// spaces_children bucket
+	// contains mapping space_id -> children room IDs, from the spaces hierarchy
+	spacesChildrenBucket = []byte(`spaces_children`)
+	// rooms_tags bucket
+	// contains mapping room_id -> tags curated by admins, kept when the room is delisted, merged into the index on ingest
+	roomsTagsBucket = []byte(`rooms_tags`)
 
-	buckets = [][]byte{serversBucket, serversInfoBucket, serversRoomsBucket, serversRoomsCountBucket, roomsBucket, biggestRoomsBucket, roomsBanlistBucket, roomsReportsBucket, indexBucket, indexTLBucket}
+	buckets = [][]byte{serversBucket, serversInfoBucket, serversRoomsBucket, serversRoomsCountBucket, roomsBucket, biggestRoomsBucket, roomsBanlistBucket, roomsReportsBucket, indexBucket, indexTLBucket, blocklistServersBucket, blocklistRoomsBucket, blocklistRemoteBucket, seedServersBucket, roomsHashesBucket, roomsServersBucket, spacesChildrenBucket, roomsTagsBucket}
 )
 
 func initBuckets(db *bbolt.DB) error {
@@ -2,14 +2,20 @@ package data
 
 import (
 	"context"
+	"encoding/json"
 	"strconv"
+	"time"
 
 	"github.com/rs/zerolog"
 	"go.etcd.io/bbolt"
 
+	"github.com/etkecc/mrs/internal/model"
 	"github.com/etkecc/mrs/internal/utils"
 )
 
+// minCatalogRooms is the min count of rooms of the server to be exposed in the servers_rooms_count catalog
+const minCatalogRooms = 100
+
 // SetServersRoomsCount sets the count of rooms for each server
 func (d *Data) SetServersRoomsCount(ctx context.Context, data map[string]int) error {
 	span := utils.StartSpan(ctx, "data.SetServersRoomsCount")
@@ -18,7 +24,7 @@ func (d *Data) SetServersRoomsCount(ctx context.Context, data map[string]int) er
 	return d.db.Batch(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket(serversRoomsCountBucket)
 		for server, count := range data {
-			if count < 100 { // we don't want to expose servers with less than 100 rooms
+			if count < minCatalogRooms { // we don't want to expose servers with less than 100 rooms
 				continue
 			}
 			err := bucket.Put([]byte(server), []byte(strconv.Itoa(count)))
@@ -89,3 +95,68 @@ func (d *Data) SaveServersRooms(ctx context.Context, data map[string][]string) e
 		return nil
 	})
 }
+
+// RebuildServersRooms rebuilds servers_rooms and servers_rooms_count buckets from scratch,
+// by grouping rooms of the rooms bucket by their server, in a single transaction
+func (d *Data) RebuildServersRooms(ctx context.Context) (*model.CatalogRebuild, error) {
+	span := utils.StartSpan(ctx, "data.RebuildServersRooms")
+	defer span.Finish()
+
+	started := time.Now()
+	result := &model.CatalogRebuild{}
+	err := d.db.Update(func(tx *bbolt.Tx) error {
+		rBucket := tx.Bucket(roomsBucket)
+		serversRooms := map[string][]string{}
+		err := rBucket.ForEach(func(k, v []byte) error {
+			var room *model.MatrixRoom
+			if err := json.Unmarshal(v, &room); err != nil {
+				return err
+			}
+			if room.Server == "" {
+				return nil
+			}
+			serversRooms[room.Server] = append(serversRooms[room.Server], string(k))
+			result.Rooms++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, name := range [][]byte{serversRoomsBucket, serversRoomsCountBucket} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		srBucket := tx.Bucket(serversRoomsBucket)
+		countBucket := tx.Bucket(serversRoomsCountBucket)
+		for server, roomIDs := range serversRooms {
+			subBucket, err := srBucket.CreateBucket([]byte(server))
+			if err != nil {
+				return err
+			}
+			for _, roomID := range roomIDs {
+				if err := subBucket.Put([]byte(roomID), rBucket.Get([]byte(roomID))); err != nil {
+					return err
+				}
+			}
+			if len(roomIDs) < minCatalogRooms {
+				continue
+			}
+			if err := countBucket.Put([]byte(server), []byte(strconv.Itoa(len(roomIDs)))); err != nil {
+				return err
+			}
+			result.Exposed++
+		}
+		result.Servers = len(serversRooms)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Took = time.Since(started).String()
+	return result, nil
+}
@@ -2,6 +2,7 @@ package data
 
 import (
 	"context"
+	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/rs/zerolog"
@@ -11,12 +12,23 @@ import (
 	"github.com/etkecc/mrs/internal/repository/batch"
 )
 
+// defaultBatchSize of parsed rooms stored at once
+const defaultBatchSize = 10000
+
 type Data struct {
-	db *bbolt.DB
-	rb *batch.Batch[*model.MatrixRoom]
+	db      *bbolt.DB
+	rb      *batch.Batch[*model.MatrixRoom]
+	servers *serversCache // nil = disabled
 }
 
-func New(path string) (*Data, error) {
+// New data repository, batchSize is the size of parsed rooms batch, if 0 - defaultBatchSize is used.
+// batchInterval flushes partial batch of parsed rooms periodically, if 0 - only full batches are flushed.
+// cacheServers enables in-memory snapshot of the servers info to reduce db reads
+func New(path string, batchSize int, batchInterval time.Duration, cacheServers bool) (*Data, error) {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
 	db, err := bbolt.Open(path, 0o600, nil)
 	if err != nil {
 		return nil, err
@@ -26,10 +38,16 @@ func New(path string) (*Data, error) {
 		return nil, err
 	}
 
+	var servers *serversCache
+	if cacheServers {
+		servers = &serversCache{}
+	}
+
 	return &Data{
-		db: db,
-		rb: batch.New(10000, func(ctx context.Context, rooms []*model.MatrixRoom) {
-			db.Update(func(tx *bbolt.Tx) error { //nolint:errcheck // checked inside
+		db:      db,
+		servers: servers,
+		rb: batch.New(batchSize, batchInterval, func(ctx context.Context, rooms []*model.MatrixRoom) error {
+			return db.Update(func(tx *bbolt.Tx) error {
 				log := zerolog.Ctx(ctx)
 				for _, room := range rooms {
 					roomb, err := json.Marshal(room)
@@ -52,5 +70,6 @@ func New(path string) (*Data, error) {
 
 // Close data repository
 func (d *Data) Close() error {
+	d.rb.Close()
 	return d.db.Close()
 }
@@ -0,0 +1,74 @@
+package data
+
+import (
+	"context"
+	"encoding/binary"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/etkecc/mrs/internal/utils"
+)
+
+// GetRoomsHashes returns room ID => content hash of the indexed rooms
+func (d *Data) GetRoomsHashes(ctx context.Context) (map[string]uint64, error) {
+	span := utils.StartSpan(ctx, "data.GetRoomsHashes")
+	defer span.Finish()
+
+	hashes := map[string]uint64{}
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(roomsHashesBucket).ForEach(func(k, v []byte) error {
+			if len(v) != 8 {
+				return nil
+			}
+			hashes[string(k)] = binary.BigEndian.Uint64(v)
+			return nil
+		})
+	})
+	return hashes, err
+}
+
+// SetRoomsHashes atomically replaces content hashes of the indexed rooms
+func (d *Data) SetRoomsHashes(ctx context.Context, hashes map[string]uint64) error {
+	span := utils.StartSpan(ctx, "data.SetRoomsHashes")
+	defer span.Finish()
+
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.DeleteBucket(roomsHashesBucket); err != nil {
+			return err
+		}
+		bucket, err := tx.CreateBucket(roomsHashesBucket)
+		if err != nil {
+			return err
+		}
+		for roomID, hash := range hashes {
+			if err := bucket.Put([]byte(roomID), binary.BigEndian.AppendUint64(nil, hash)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveRoomsHashes removes content hashes of the rooms, so they will be re-indexed on the next ingest.
+// Must be called when rooms are removed from the search index outside of the ingest
+func (d *Data) RemoveRoomsHashes(ctx context.Context, roomIDs []string) error {
+	if len(roomIDs) == 0 {
+		return nil
+	}
+	span := utils.StartSpan(ctx, "data.RemoveRoomsHashes")
+	defer span.Finish()
+
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		return removeRoomsHashes(tx, roomIDs)
+	})
+}
+
+func removeRoomsHashes(tx *bbolt.Tx, roomIDs []string) error {
+	bucket := tx.Bucket(roomsHashesBucket)
+	for _, roomID := range roomIDs {
+		if err := bucket.Delete([]byte(roomID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
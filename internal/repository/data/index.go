@@ -29,6 +29,32 @@ func (d *Data) SetIndexStatsTL(ctx context.Context, calculatedAt time.Time, stat
 	})
 }
 
+// ReplaceIndexStatsTL atomically replaces the whole stats timeline
+func (d *Data) ReplaceIndexStatsTL(ctx context.Context, statsTL map[time.Time]*model.IndexStats) error {
+	span := utils.StartSpan(ctx, "data.ReplaceIndexStatsTL")
+	defer span.Finish()
+
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.DeleteBucket(indexTLBucket); err != nil {
+			return err
+		}
+		bucket, err := tx.CreateBucket(indexTLBucket)
+		if err != nil {
+			return err
+		}
+		for calculatedAt, stats := range statsTL {
+			statsb, err := json.Marshal(stats)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(calculatedAt.UTC().Format(time.RFC3339)), statsb); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func (d *Data) getIndexStatsFullTL(ctx context.Context) (map[time.Time]*model.IndexStats, error) {
 	span := utils.StartSpan(ctx, "data.getIndexStatsFullTL")
 	defer span.Finish()
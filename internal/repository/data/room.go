@@ -20,9 +20,9 @@ func (d *Data) AddRoomBatch(ctx context.Context, room *model.MatrixRoom) {
 	d.rb.Add(ctx, room)
 }
 
-// FlushRoomBatch to ensure nothing is left
-func (d *Data) FlushRoomBatch(ctx context.Context) {
-	d.rb.Flush(ctx)
+// FlushRoomBatch to ensure nothing is left, returns errors of all batches failed since the previous call
+func (d *Data) FlushRoomBatch(ctx context.Context) error {
+	return d.rb.Flush(ctx)
 }
 
 func (d *Data) SetBiggestRooms(ctx context.Context, ids []string) error {
@@ -60,8 +60,9 @@ func (d *Data) GetBiggestRooms(ctx context.Context, limit, offset int) []*model.
 	defer span.Finish()
 	log := zerolog.Ctx(ctx)
 
-	start := []byte(fmt.Sprintf("%06d", offset))
-	end := []byte(fmt.Sprintf("%06d", limit))
+	// keys are 1-based positions
+	start := []byte(fmt.Sprintf("%06d", offset+1))
+	end := []byte(fmt.Sprintf("%06d", offset+limit))
 	rooms := []*model.MatrixRoom{}
 
 	d.db.View(func(tx *bbolt.Tx) error { //nolint:errcheck // that's ok
@@ -110,36 +111,77 @@ func (d *Data) RemoveRooms(ctx context.Context, keys []string) {
 		for _, k := range keys {
 			bucket.Delete([]byte(k)) //nolint:errcheck // that's ok
 		}
-		return nil
+		if err := removeRoomsServers(tx, keys); err != nil {
+			return err
+		}
+		return removeSpacesChildren(tx, keys)
 	})
 }
 
-// EachRoom allows to work with each known room
-//
-//nolint:errcheck // that's ok
+// eachRoomPage is the max number of rooms read by EachRoom in a single transaction
+const eachRoomPage = 1000
+
+// EachRoom allows to work with each known room.
+// Rooms are read in pages, each in a separate short read transaction, and the handler is called outside of it,
+// so the handler may modify the rooms (e.g. remove them) without waiting for the whole iteration to finish
 func (d *Data) EachRoom(ctx context.Context, handler func(roomID string, data *model.MatrixRoom) bool) {
 	span := utils.StartSpan(ctx, "data.EachRoom")
 	defer span.Finish()
 
-	var room *model.MatrixRoom
-	d.db.View(func(tx *bbolt.Tx) error {
-		return tx.Bucket(roomsBucket).ForEach(func(k, v []byte) error {
-			err := json.Unmarshal(v, &room)
-			if err != nil {
-				return err
+	var after []byte
+	for {
+		ids, rooms, last, err := d.getRoomsPage(after, eachRoomPage)
+		if err != nil {
+			zerolog.Ctx(span.Context()).Error().Err(err).Msg("cannot read rooms")
+			return
+		}
+		for i, id := range ids {
+			if handler(id, rooms[i]) {
+				return
 			}
+		}
+		if last == nil {
+			return
+		}
+		after = last
+	}
+}
+
+// getRoomsPage reads up to limit rooms stored after the given key (from the first room, if after is nil), except banned ones.
+// Returns the key of the last read room, or nil if there are no rooms left
+func (d *Data) getRoomsPage(after []byte, limit int) (ids []string, rooms []*model.MatrixRoom, last []byte, err error) {
+	ids = make([]string, 0, limit)
+	rooms = make([]*model.MatrixRoom, 0, limit)
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		banlist := tx.Bucket(roomsBanlistBucket)
+		c := tx.Bucket(roomsBucket).Cursor()
+		k, v := c.First()
+		if after != nil {
+			k, v = c.Seek(after)
+			if bytes.Equal(k, after) {
+				k, v = c.Next()
+			}
+		}
+		for read := 0; k != nil && read < limit; k, v = c.Next() {
+			read++
+			last = bytes.Clone(k)
 			// ignore banned rooms
-			if tx.Bucket(roomsBanlistBucket).Get(k) != nil {
-				return nil
+			if banlist.Get(k) != nil {
+				continue
 			}
-
-			if handler(string(k), room) {
-				return fmt.Errorf("stop")
+			var room *model.MatrixRoom
+			if err := json.Unmarshal(v, &room); err != nil {
+				return err
 			}
-
-			return nil
-		})
+			ids = append(ids, string(k))
+			rooms = append(rooms, room)
+		}
+		if k == nil {
+			last = nil
+		}
+		return nil
 	})
+	return ids, rooms, last, err
 }
 
 // GetBannedRooms returns full list of the banned rooms
@@ -172,7 +214,11 @@ func (d *Data) BanRoom(ctx context.Context, roomID string) error {
 	defer span.Finish()
 
 	return d.db.Batch(func(tx *bbolt.Tx) error {
-		return tx.Bucket(roomsBanlistBucket).Put([]byte(roomID), []byte(`true`))
+		if err := tx.Bucket(roomsBanlistBucket).Put([]byte(roomID), []byte(`true`)); err != nil {
+			return err
+		}
+		// banned room is removed from the index, so it should be re-indexed after unban
+		return removeRoomsHashes(tx, []string{roomID})
 	})
 }
 
@@ -189,8 +235,8 @@ func (d *Data) UnbanRoom(ctx context.Context, roomID string) error {
 	})
 }
 
-// GetReportedRooms returns full list of the banned rooms with reasons
-func (d *Data) GetReportedRooms(ctx context.Context, serverName ...string) (map[string]string, error) {
+// GetReportedRooms returns full list of the reported rooms with reports
+func (d *Data) GetReportedRooms(ctx context.Context, serverName ...string) (map[string]*model.RoomReport, error) {
 	span := utils.StartSpan(ctx, "data.GetReportedRooms")
 	defer span.Finish()
 
@@ -198,7 +244,7 @@ func (d *Data) GetReportedRooms(ctx context.Context, serverName ...string) (map[
 	if len(serverName) > 0 {
 		server = serverName[0]
 	}
-	data := map[string]string{}
+	data := map[string]*model.RoomReport{}
 	err := d.db.View(func(tx *bbolt.Tx) error {
 		return tx.Bucket(roomsReportsBucket).ForEach(func(k, v []byte) error {
 			roomID := string(k)
@@ -206,13 +252,22 @@ func (d *Data) GetReportedRooms(ctx context.Context, serverName ...string) (map[
 				return nil
 			}
 
-			data[string(k)] = string(v)
+			data[string(k)] = parseRoomReport(v)
 			return nil
 		})
 	})
 	return data, err
 }
 
+// parseRoomReport parses stored report, supporting the legacy format (plain reason string)
+func parseRoomReport(v []byte) *model.RoomReport {
+	var report *model.RoomReport
+	if bytes.HasPrefix(v, []byte("{")) && json.Unmarshal(v, &report) == nil && report != nil {
+		return report
+	}
+	return &model.RoomReport{Reason: string(v)}
+}
+
 // IsReported returns true if room was already reported
 func (d *Data) IsReported(ctx context.Context, roomID string) bool {
 	span := utils.StartSpan(ctx, "data.IsReported")
@@ -229,12 +284,16 @@ func (d *Data) IsReported(ctx context.Context, roomID string) bool {
 }
 
 // ReportRoom
-func (d *Data) ReportRoom(ctx context.Context, roomID, reason string) error {
+func (d *Data) ReportRoom(ctx context.Context, roomID string, report *model.RoomReport) error {
 	span := utils.StartSpan(ctx, "data.ReportRoom")
 	defer span.Finish()
 
+	reportb, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
 	return d.db.Batch(func(tx *bbolt.Tx) error {
-		return tx.Bucket(roomsReportsBucket).Put([]byte(roomID), []byte(reason))
+		return tx.Bucket(roomsReportsBucket).Put([]byte(roomID), reportb)
 	})
 }
 
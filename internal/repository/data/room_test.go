@@ -0,0 +1,75 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/etkecc/mrs/internal/model"
+)
+
+func TestParseRoomReport(t *testing.T) {
+	reportedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	stored, err := json.Marshal(&model.RoomReport{Reason: "spam", ReportedBy: "127.0.0.1", ReportedAt: &reportedAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := parseRoomReport(stored)
+	if report.Reason != "spam" || report.ReportedBy != "127.0.0.1" {
+		t.Errorf("got %+v", report)
+	}
+	if report.ReportedAt == nil || !report.ReportedAt.Equal(reportedAt) {
+		t.Errorf("reported at: got %v, want %v", report.ReportedAt, reportedAt)
+	}
+
+	legacy := parseRoomReport([]byte("spam"))
+	if legacy.Reason != "spam" || legacy.ReportedAt != nil {
+		t.Errorf("legacy report: got %+v", legacy)
+	}
+	legacyb, err := json.Marshal(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(legacyb, []byte("reported_at")) {
+		t.Errorf("unknown report time is not omitted: %s", legacyb)
+	}
+}
+
+func TestAddRoomBatch_Size(t *testing.T) {
+	ctx := context.Background()
+	d, err := New(filepath.Join(t.TempDir(), "mrs.db"), 3, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	stored := func() int {
+		t.Helper()
+		var count int
+		for n := range 3 {
+			room, err := d.GetRoom(ctx, fmt.Sprintf("!%d:example.com", n))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if room != nil {
+				count++
+			}
+		}
+		return count
+	}
+	for n := range 2 {
+		d.AddRoomBatch(ctx, &model.MatrixRoom{ID: fmt.Sprintf("!%d:example.com", n), Server: "example.com"})
+	}
+	if count := stored(); count != 0 {
+		t.Errorf("partial batch: got %d stored rooms, want 0", count)
+	}
+	d.AddRoomBatch(ctx, &model.MatrixRoom{ID: "!2:example.com", Server: "example.com"})
+	if count := stored(); count != 3 {
+		t.Errorf("full batch: got %d stored rooms, want 3", count)
+	}
+}
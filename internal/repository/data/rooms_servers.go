@@ -0,0 +1,70 @@
+package data
+
+import (
+	"context"
+	"slices"
+
+	"github.com/goccy/go-json"
+	"go.etcd.io/bbolt"
+
+	"github.com/etkecc/mrs/internal/utils"
+)
+
+// AddRoomsServers stores servers advertising the rooms in their room directories,
+// merging them with the previously stored servers of the rooms (a run may parse only some of the servers)
+func (d *Data) AddRoomsServers(ctx context.Context, data map[string][]string) error {
+	if len(data) == 0 {
+		return nil
+	}
+	span := utils.StartSpan(ctx, "data.AddRoomsServers")
+	defer span.Finish()
+
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(roomsServersBucket)
+		for roomID, servers := range data {
+			if v := bucket.Get([]byte(roomID)); v != nil {
+				var stored []string
+				if err := json.Unmarshal(v, &stored); err != nil {
+					return err
+				}
+				servers = append(stored, servers...)
+			}
+			servers = utils.Uniq(servers)
+			slices.Sort(servers)
+			serversb, err := json.Marshal(servers)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(roomID), serversb); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetRoomServers returns servers advertising the room in their room directories
+func (d *Data) GetRoomServers(ctx context.Context, roomID string) ([]string, error) {
+	span := utils.StartSpan(ctx, "data.GetRoomServers")
+	defer span.Finish()
+
+	var servers []string
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(roomsServersBucket).Get([]byte(roomID))
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, &servers)
+	})
+	return servers, err
+}
+
+func removeRoomsServers(tx *bbolt.Tx, roomIDs []string) error {
+	bucket := tx.Bucket(roomsServersBucket)
+	for _, roomID := range roomIDs {
+		if err := bucket.Delete([]byte(roomID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
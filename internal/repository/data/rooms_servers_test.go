@@ -0,0 +1,46 @@
+package data
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func newTestData(t *testing.T) *Data {
+	t.Helper()
+	d, err := New(filepath.Join(t.TempDir(), "mrs.db"), 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestAddRoomsServers_Merge(t *testing.T) {
+	ctx := context.Background()
+	d := newTestData(t)
+	if err := d.AddRoomsServers(ctx, map[string][]string{
+		"!a:example.com": {"example.com", "b.com"},
+		"!b:example.com": {"example.com"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddRoomsServers(ctx, map[string][]string{"!a:example.com": {"a.com", "b.com"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]string{
+		"!a:example.com": {"a.com", "b.com", "example.com"},
+		"!b:example.com": {"example.com"},
+	}
+	for roomID, servers := range expected {
+		stored, err := d.GetRoomServers(ctx, roomID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(stored, servers) {
+			t.Errorf("servers of %s: got %v, want %v", roomID, stored, servers)
+		}
+	}
+}
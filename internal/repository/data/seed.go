@@ -0,0 +1,46 @@
+package data
+
+import (
+	"context"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/etkecc/mrs/internal/utils"
+)
+
+// GetSeedServers returns the seed servers list set at runtime
+func (d *Data) GetSeedServers(ctx context.Context) ([]string, error) {
+	span := utils.StartSpan(ctx, "data.GetSeedServers")
+	defer span.Finish()
+
+	list := []string{}
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(seedServersBucket).ForEach(func(k, _ []byte) error {
+			list = append(list, string(k))
+			return nil
+		})
+	})
+	return list, err
+}
+
+// SetSeedServers replaces the seed servers list set at runtime
+func (d *Data) SetSeedServers(ctx context.Context, servers []string) error {
+	span := utils.StartSpan(ctx, "data.SetSeedServers")
+	defer span.Finish()
+
+	return d.db.Batch(func(tx *bbolt.Tx) error {
+		if err := tx.DeleteBucket(seedServersBucket); err != nil {
+			return err
+		}
+		b, err := tx.CreateBucket(seedServersBucket)
+		if err != nil {
+			return err
+		}
+		for _, server := range servers {
+			if err := b.Put([]byte(server), []byte(`true`)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
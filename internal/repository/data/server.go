@@ -17,7 +17,7 @@ func (d *Data) AddServer(ctx context.Context, server *model.MatrixServer) error
 	defer span.Finish()
 	log := zerolog.Ctx(ctx)
 
-	return d.db.Batch(func(tx *bbolt.Tx) error {
+	err := d.db.Batch(func(tx *bbolt.Tx) error {
 		serverb, merr := json.Marshal(server)
 		if merr != nil {
 			log.Error().Err(merr).Str("server", server.Name).Msg("cannot marshal server")
@@ -25,6 +25,10 @@ func (d *Data) AddServer(ctx context.Context, server *model.MatrixServer) error
 		}
 		return tx.Bucket(serversInfoBucket).Put([]byte(server.Name), serverb)
 	})
+	if err == nil && d.servers != nil {
+		d.servers.set(server)
+	}
+	return err
 }
 
 // BatchServers adds a batch of servers at once
@@ -32,6 +36,9 @@ func (d *Data) BatchServers(ctx context.Context, servers []string) error {
 	span := utils.StartSpan(ctx, "data.BatchServers")
 	defer span.Finish()
 	log := zerolog.Ctx(ctx)
+	if d.servers != nil {
+		defer d.servers.invalidate()
+	}
 
 	return d.db.Batch(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket(serversInfoBucket)
@@ -55,6 +62,9 @@ func (d *Data) BatchServers(ctx context.Context, servers []string) error {
 func (d *Data) HasServer(ctx context.Context, name string) bool {
 	span := utils.StartSpan(ctx, "data.HasServer")
 	defer span.Finish()
+	if d.servers != nil {
+		return d.servers.get(span.Context(), d.db, name) != nil
+	}
 
 	var has bool
 	d.db.View(func(tx *bbolt.Tx) error { //nolint:errcheck // that's ok
@@ -69,6 +79,9 @@ func (d *Data) HasServer(ctx context.Context, name string) bool {
 func (d *Data) GetServerInfo(ctx context.Context, name string) (*model.MatrixServer, error) {
 	span := utils.StartSpan(ctx, "data.GetServerInfo")
 	defer span.Finish()
+	if d.servers != nil {
+		return d.servers.get(span.Context(), d.db, name), nil
+	}
 
 	var server *model.MatrixServer
 	err := d.db.View(func(tx *bbolt.Tx) error {
@@ -92,6 +105,9 @@ func (d *Data) RemoveServer(ctx context.Context, name string) error {
 	defer span.Finish()
 
 	nameb := []byte(name)
+	if d.servers != nil {
+		defer d.servers.delete(name)
+	}
 	return d.db.Batch(func(tx *bbolt.Tx) error {
 		err := tx.Bucket(serversBucket).Delete(nameb)
 		if err != nil {
@@ -101,6 +117,82 @@ func (d *Data) RemoveServer(ctx context.Context, name string) error {
 	})
 }
 
+// PurgeServer removes the server and all its rooms from all buckets, returns removed room IDs
+func (d *Data) PurgeServer(ctx context.Context, name string) (found bool, roomIDs []string, err error) {
+	span := utils.StartSpan(ctx, "data.PurgeServer")
+	defer span.Finish()
+
+	nameb := []byte(name)
+	if d.servers != nil {
+		defer d.servers.delete(name)
+	}
+	err = d.db.Update(func(tx *bbolt.Tx) error {
+		found = tx.Bucket(serversInfoBucket).Get(nameb) != nil || tx.Bucket(serversBucket).Get(nameb) != nil
+
+		rooms := tx.Bucket(roomsBucket)
+		if ferr := rooms.ForEach(func(k, v []byte) error {
+			var room *model.MatrixRoom
+			if uerr := json.Unmarshal(v, &room); uerr != nil {
+				return uerr
+			}
+			if room.Server == name {
+				roomIDs = append(roomIDs, string(k))
+			}
+			return nil
+		}); ferr != nil {
+			return ferr
+		}
+		for _, id := range roomIDs {
+			if derr := rooms.Delete([]byte(id)); derr != nil {
+				return derr
+			}
+		}
+		if derr := removeRoomsHashes(tx, roomIDs); derr != nil {
+			return derr
+		}
+		if derr := removeRoomsServers(tx, roomIDs); derr != nil {
+			return derr
+		}
+		if derr := removeSpacesChildren(tx, roomIDs); derr != nil {
+			return derr
+		}
+		if derr := removeRoomsTags(tx, roomIDs); derr != nil {
+			return derr
+		}
+
+		// the biggest rooms list may have gaps until the next indexing, that's ok
+		biggest := tx.Bucket(biggestRoomsBucket)
+		toRemove := [][]byte{}
+		if ferr := biggest.ForEach(func(k, v []byte) error {
+			var room *model.MatrixRoom
+			if uerr := json.Unmarshal(v, &room); uerr == nil && room.Server == name {
+				toRemove = append(toRemove, k)
+			}
+			return nil
+		}); ferr != nil {
+			return ferr
+		}
+		for _, k := range toRemove {
+			if derr := biggest.Delete(k); derr != nil {
+				return derr
+			}
+		}
+
+		if tx.Bucket(serversRoomsBucket).Bucket(nameb) != nil {
+			if derr := tx.Bucket(serversRoomsBucket).DeleteBucket(nameb); derr != nil {
+				return derr
+			}
+		}
+		for _, bucket := range [][]byte{serversRoomsCountBucket, serversBucket, serversInfoBucket} {
+			if derr := tx.Bucket(bucket).Delete(nameb); derr != nil {
+				return derr
+			}
+		}
+		return nil
+	})
+	return found, roomIDs, err
+}
+
 // RemoveServers from db
 func (d *Data) RemoveServers(ctx context.Context, keys []string) {
 	if len(keys) == 0 {
@@ -109,6 +201,9 @@ func (d *Data) RemoveServers(ctx context.Context, keys []string) {
 
 	span := utils.StartSpan(ctx, "data.RemoveServers")
 	defer span.Finish()
+	if d.servers != nil {
+		defer d.servers.delete(keys...)
+	}
 
 	d.db.Update(func(tx *bbolt.Tx) error { //nolint:errcheck // that's ok
 		sbucket := tx.Bucket(serversBucket)
@@ -156,6 +251,9 @@ func (d *Data) MarkServersOffline(ctx context.Context, keys []string) {
 
 	span := utils.StartSpan(ctx, "data.MarkServersOffline")
 	defer span.Finish()
+	if d.servers != nil {
+		defer d.servers.invalidate()
+	}
 
 	d.db.Batch(func(tx *bbolt.Tx) error { //nolint:errcheck // that's ok
 		bucket := tx.Bucket(serversInfoBucket)
@@ -169,6 +267,9 @@ func (d *Data) MarkServersOffline(ctx context.Context, keys []string) {
 func (d *Data) FilterServers(ctx context.Context, filter func(server *model.MatrixServer) bool) map[string]*model.MatrixServer {
 	span := utils.StartSpan(ctx, "data.FilterServers")
 	defer span.Finish()
+	if d.servers != nil {
+		return d.servers.filter(span.Context(), d.db, filter)
+	}
 	log := zerolog.Ctx(ctx)
 
 	servers := make(map[string]*model.MatrixServer)
@@ -194,3 +295,10 @@ func (d *Data) FilterServers(ctx context.Context, filter func(server *model.Matr
 
 	return servers
 }
+
+// RefreshServers drops in-memory snapshot of the servers info (if enabled), so it will be loaded from db on the next read
+func (d *Data) RefreshServers() {
+	if d.servers != nil {
+		d.servers.invalidate()
+	}
+}
@@ -0,0 +1,113 @@
+package data
+
+import (
+	"context"
+	"sync"
+
+	"github.com/goccy/go-json"
+	"github.com/rs/zerolog"
+	"go.etcd.io/bbolt"
+
+	"github.com/etkecc/mrs/internal/model"
+)
+
+// serversCache is an in-memory snapshot of the servers_info bucket,
+// it is loaded on the first read after invalidation and updated on writes
+type serversCache struct {
+	mu      sync.RWMutex
+	servers map[string]*model.MatrixServer // nil = not loaded
+}
+
+// get returns copy of the cached server, loading the snapshot if needed
+func (c *serversCache) get(ctx context.Context, db *bbolt.DB, name string) *model.MatrixServer {
+	c.load(ctx, db)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	server, ok := c.servers[name]
+	if !ok {
+		return nil
+	}
+	cp := *server
+	return &cp
+}
+
+// filter returns copies of the cached servers matching the filter, loading the snapshot if needed
+func (c *serversCache) filter(ctx context.Context, db *bbolt.DB, filter func(server *model.MatrixServer) bool) map[string]*model.MatrixServer {
+	c.load(ctx, db)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	servers := make(map[string]*model.MatrixServer)
+	for name, server := range c.servers {
+		cp := *server
+		if filter(&cp) {
+			servers[name] = &cp
+		}
+	}
+	return servers
+}
+
+// set updates the cached server, if the snapshot is loaded
+func (c *serversCache) set(server *model.MatrixServer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.servers == nil {
+		return
+	}
+	cp := *server
+	c.servers[server.Name] = &cp
+}
+
+// delete removes servers from the snapshot, if it is loaded
+func (c *serversCache) delete(names ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, name := range names {
+		delete(c.servers, name)
+	}
+}
+
+// invalidate the snapshot, it will be loaded on the next read
+func (c *serversCache) invalidate() {
+	c.mu.Lock()
+	c.servers = nil
+	c.mu.Unlock()
+}
+
+// load the snapshot from the servers_info bucket, if it is not loaded yet
+func (c *serversCache) load(ctx context.Context, db *bbolt.DB) {
+	c.mu.RLock()
+	loaded := c.servers != nil
+	c.mu.RUnlock()
+	if loaded {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.servers != nil {
+		return
+	}
+
+	log := zerolog.Ctx(ctx)
+	servers := make(map[string]*model.MatrixServer)
+	err := db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(serversInfoBucket).ForEach(func(k, v []byte) error {
+			var server *model.MatrixServer
+			if err := json.Unmarshal(v, &server); err != nil || server == nil {
+				log.Error().Err(err).Str("server", string(k)).Msg("cannot unmarshal server")
+				return nil
+			}
+			servers[string(k)] = server
+			return nil
+		})
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("cannot load servers")
+		return
+	}
+	c.servers = servers
+}
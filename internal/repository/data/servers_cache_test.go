@@ -0,0 +1,47 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// BenchmarkGetServerInfo measures the per-server lookups of the servers discovery under concurrent workers
+func BenchmarkGetServerInfo(b *testing.B) {
+	const servers = 10000
+	names := make([]string, 0, servers)
+	for n := range servers {
+		names = append(names, fmt.Sprintf("server%d.example.com", n))
+	}
+
+	for _, cacheServers := range []bool{false, true} {
+		b.Run(fmt.Sprintf("cache=%t", cacheServers), func(b *testing.B) {
+			ctx := context.Background()
+			d, err := New(filepath.Join(b.TempDir(), "mrs.db"), 0, 0, cacheServers)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Cleanup(func() { d.Close() })
+			if err := d.BatchServers(ctx, names); err != nil {
+				b.Fatal(err)
+			}
+			d.HasServer(ctx, names[0]) // load the snapshot, if enabled
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				var n int
+				for pb.Next() {
+					name := names[n%servers]
+					if !d.HasServer(ctx, name) {
+						b.Errorf("server %s is not found", name)
+					}
+					if _, err := d.GetServerInfo(ctx, name); err != nil {
+						b.Error(err)
+					}
+					n++
+				}
+			})
+		})
+	}
+}
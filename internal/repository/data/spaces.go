@@ -0,0 +1,59 @@
+package data
+
+import (
+	"context"
+
+	"github.com/goccy/go-json"
+	"go.etcd.io/bbolt"
+
+	"github.com/etkecc/mrs/internal/utils"
+)
+
+// AddSpacesChildren stores children of the spaces, replacing previously stored children of the given spaces only
+func (d *Data) AddSpacesChildren(ctx context.Context, data map[string][]string) error {
+	if len(data) == 0 {
+		return nil
+	}
+	span := utils.StartSpan(ctx, "data.AddSpacesChildren")
+	defer span.Finish()
+
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(spacesChildrenBucket)
+		for spaceID, children := range data {
+			childrenb, err := json.Marshal(children)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(spaceID), childrenb); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetSpaceChildren returns children room IDs of the space
+func (d *Data) GetSpaceChildren(ctx context.Context, spaceID string) ([]string, error) {
+	span := utils.StartSpan(ctx, "data.GetSpaceChildren")
+	defer span.Finish()
+
+	var children []string
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(spacesChildrenBucket).Get([]byte(spaceID))
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, &children)
+	})
+	return children, err
+}
+
+func removeSpacesChildren(tx *bbolt.Tx, spaceIDs []string) error {
+	bucket := tx.Bucket(spacesChildrenBucket)
+	for _, spaceID := range spaceIDs {
+		if err := bucket.Delete([]byte(spaceID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
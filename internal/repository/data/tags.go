@@ -0,0 +1,73 @@
+package data
+
+import (
+	"context"
+
+	"github.com/goccy/go-json"
+	"go.etcd.io/bbolt"
+
+	"github.com/etkecc/mrs/internal/utils"
+)
+
+// SetRoomTags stores tags of the room, empty tags remove the stored ones
+func (d *Data) SetRoomTags(ctx context.Context, roomID string, tags []string) error {
+	span := utils.StartSpan(ctx, "data.SetRoomTags")
+	defer span.Finish()
+
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(roomsTagsBucket)
+		if len(tags) == 0 {
+			return bucket.Delete([]byte(roomID))
+		}
+		tagsb, err := json.Marshal(tags)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(roomID), tagsb)
+	})
+}
+
+// GetRoomsTags returns room ID => tags of all tagged rooms
+func (d *Data) GetRoomsTags(ctx context.Context) (map[string][]string, error) {
+	span := utils.StartSpan(ctx, "data.GetRoomsTags")
+	defer span.Finish()
+
+	tags := map[string][]string{}
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(roomsTagsBucket).ForEach(func(k, v []byte) error {
+			var roomTags []string
+			if err := json.Unmarshal(v, &roomTags); err != nil {
+				return err
+			}
+			tags[string(k)] = roomTags
+			return nil
+		})
+	})
+	return tags, err
+}
+
+// GetRoomTags returns tags of the room
+func (d *Data) GetRoomTags(ctx context.Context, roomID string) ([]string, error) {
+	span := utils.StartSpan(ctx, "data.GetRoomTags")
+	defer span.Finish()
+
+	var tags []string
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(roomsTagsBucket).Get([]byte(roomID))
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, &tags)
+	})
+	return tags, err
+}
+
+func removeRoomsTags(tx *bbolt.Tx, roomIDs []string) error {
+	bucket := tx.Bucket(roomsTagsBucket)
+	for _, roomID := range roomIDs {
+		if err := bucket.Delete([]byte(roomID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,27 +2,42 @@ package search
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"sync"
 
 	"github.com/blevesearch/bleve/v2"
 	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
 	regexp_char_filter "github.com/blevesearch/bleve/v2/analysis/char/regexp"
 	"github.com/blevesearch/bleve/v2/analysis/lang/en"
 	"github.com/blevesearch/bleve/v2/analysis/tokenizer/letter"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/single"
 	"github.com/blevesearch/bleve/v2/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/v2/index/scorch"
+	"github.com/blevesearch/bleve/v2/index/scorch/mergeplan"
 	"github.com/blevesearch/bleve/v2/mapping"
 	"github.com/pemistahl/lingua-go"
 	"github.com/rs/zerolog"
 
+	"github.com/etkecc/mrs/internal/model"
 	"github.com/etkecc/mrs/internal/repository/search/multilang"
 	"github.com/etkecc/mrs/internal/utils"
 )
 
-const backupSuffix = ".bak"
+const (
+	backupSuffix  = ".bak"
+	stagingSuffix = ".new"
+)
 
 type Index struct {
-	index bleve.Index
-	path  string
+	mu      sync.RWMutex // guards index and staging for the whole operation using them, Swap holds it exclusively
+	index   bleve.Index
+	staging bleve.Index // fresh index being populated, replaces the live index on Swap
+	path    string
 }
 
 var (
@@ -50,6 +65,15 @@ var (
 		"token_filters": []any{
 			`to_lower`,
 			en.StopName,
+			multilang.FoldName,
+		},
+	}
+	// analyzerExact keeps the whole value as a single lowercased token, used for exact matches
+	analyzerExact = map[string]any{
+		"type":      custom.Name,
+		"tokenizer": single.Name,
+		"token_filters": []any{
+			`to_lower`,
 		},
 	}
 )
@@ -74,8 +98,27 @@ func getIndexMapping(ctx context.Context) mapping.IndexMapping {
 		log.Error().Err(err).Msg("cannot create matrix_alias analyzer")
 	}
 
+	err = m.AddCustomAnalyzer("exact", analyzerExact)
+	if err != nil {
+		log.Error().Err(err).Msg("cannot create exact analyzer")
+	}
+
+	// rooms with detected language use language-specific analyzer,
+	// rooms without it (and unsupported languages) use multilang analyzer with per-field detection
+	room := newRoomMapping(multilang.Name)
+	m.AddDocumentMapping("room", room)
+	m.DefaultMapping = room
+	for lang, analyzer := range multilang.Analyzers {
+		m.AddDocumentMapping("room_"+lang, newRoomMapping(analyzer))
+	}
+
+	return m
+}
+
+// newRoomMapping creates room document mapping, using textAnalyzer for name and topic fields
+func newRoomMapping(textAnalyzer string) *mapping.DocumentMapping {
 	textFM := bleve.NewTextFieldMapping()
-	textFM.Analyzer = multilang.Name
+	textFM.Analyzer = textAnalyzer
 
 	// noindexFM is used for values that just need to be stored, but not analyzed or searched
 	noindexFM := bleve.NewKeywordFieldMapping()
@@ -84,6 +127,12 @@ func getIndexMapping(ctx context.Context) mapping.IndexMapping {
 	noindexFM.IncludeInAll = false
 	noindexFM.IncludeTermVectors = false
 
+	// noindexBoolFM is the same as noindexFM, but for boolean values (keyword mapping ignores them)
+	noindexBoolFM := bleve.NewBooleanFieldMapping()
+	noindexBoolFM.Store = true
+	noindexBoolFM.Index = false
+	noindexBoolFM.IncludeInAll = false
+
 	numericFM := bleve.NewNumericFieldMapping()
 
 	matrixIDFM := bleve.NewTextFieldMapping()
@@ -92,29 +141,69 @@ func getIndexMapping(ctx context.Context) mapping.IndexMapping {
 	matrixAliasFM := bleve.NewTextFieldMapping()
 	matrixAliasFM.Analyzer = "matrix_alias"
 
+	// exactFM is un-analyzed (except lowercasing) copy of the field, indexed as name.keyword
+	exactFM := bleve.NewTextFieldMapping()
+	exactFM.Name = "name.keyword"
+	exactFM.Analyzer = "exact"
+	exactFM.Store = false
+	exactFM.IncludeInAll = false
+	exactFM.IncludeTermVectors = false
+
+	// tagsFM is un-analyzed (except lowercasing), so tag:value filter matches whole tags only
+	tagsFM := bleve.NewTextFieldMapping()
+	tagsFM.Analyzer = "exact"
+	tagsFM.IncludeInAll = false
+	tagsFM.IncludeTermVectors = false
+
+	// unicodeFMs are language-neutral copies of the text fields, indexed as name.unicode and topic.unicode,
+	// to keep emoji and words in scripts the language-specific analyzers don't handle well
+	unicodeFMs := make(map[string]*mapping.FieldMapping, 2)
+	for _, field := range []string{"name", "topic"} {
+		unicodeFM := bleve.NewTextFieldMapping()
+		unicodeFM.Name = field + ".unicode"
+		unicodeFM.Analyzer = multilang.UnicodeName
+		unicodeFM.Store = false
+		unicodeFM.IncludeInAll = false
+		unicodeFM.IncludeTermVectors = false
+		unicodeFMs[field] = unicodeFM
+	}
+
 	r := bleve.NewDocumentMapping()
 	r.AddFieldMappingsAt("id", matrixIDFM)
 	r.AddFieldMappingsAt("type", noindexFM)
 	r.AddFieldMappingsAt("alias", matrixAliasFM)
-	r.AddFieldMappingsAt("name", textFM)
-	r.AddFieldMappingsAt("topic", textFM)
+	r.AddFieldMappingsAt("name", textFM, exactFM, unicodeFMs["name"])
+	r.AddFieldMappingsAt("topic", textFM, unicodeFMs["topic"])
 	r.AddFieldMappingsAt("avatar", noindexFM)
 	r.AddFieldMappingsAt("avatar_url", noindexFM)
-	r.AddFieldMappingsAt("server", bleve.NewKeywordFieldMapping())
+	r.AddFieldMappingsAt("server", bleve.NewKeywordFieldMapping()) // keyword (un-analyzed), so sort=server is alphabetical
 	r.AddFieldMappingsAt("members", numericFM)
 	r.AddFieldMappingsAt("language", bleve.NewKeywordFieldMapping())
 	r.AddFieldMappingsAt("room_type", noindexFM)
 	r.AddFieldMappingsAt("join_rule", noindexFM)
-	r.AddFieldMappingsAt("guest_can_join", noindexFM)
-	r.AddFieldMappingsAt("world_readable", noindexFM)
-	m.AddDocumentMapping("room", r)
+	r.AddFieldMappingsAt("guest_can_join", noindexBoolFM)
+	r.AddFieldMappingsAt("world_readable", noindexBoolFM)
+	r.AddFieldMappingsAt("tags", tagsFM)
+	r.AddFieldMappingsAt("last_active", bleve.NewDateTimeFieldMapping())
+	r.AddFieldMappingsAt("first_seen", bleve.NewDateTimeFieldMapping())
 
-	return m
+	return r
+}
+
+// registerOnce guards registration of the custom analyzers, bleve's registry panics on duplicates
+var registerOnce sync.Once
+
+// Register registers custom analyzers required to open the index, foldAccents enables diacritics folding (see multilang.Register).
+// Subsequent calls are ignored. NewIndex calls it as well
+func Register(detector lingua.LanguageDetector, defaultLang string, foldAccents bool) {
+	registerOnce.Do(func() {
+		multilang.Register(detector, defaultLang, foldAccents)
+	})
 }
 
-// NewIndex creates or opens an index
-func NewIndex(path string, detector lingua.LanguageDetector, defaultLang string) (*Index, error) {
-	multilang.Register(detector, defaultLang)
+// NewIndex creates or opens an index, foldAccents enables diacritics folding (see multilang.Register)
+func NewIndex(path string, detector lingua.LanguageDetector, defaultLang string, foldAccents bool) (*Index, error) {
+	Register(detector, defaultLang, foldAccents)
 	i := &Index{
 		path: path,
 	}
@@ -123,7 +212,7 @@ func NewIndex(path string, detector lingua.LanguageDetector, defaultLang string)
 	return i, err
 }
 
-// load index from path
+// load index from path, must be called with the lock held (or before the index is shared)
 func (i *Index) load(ctx context.Context) error {
 	var index bleve.Index
 	var err error
@@ -146,8 +235,25 @@ func (i *Index) loadFS(ctx context.Context) (bleve.Index, error) {
 	return index, nil
 }
 
-// Swap index
-func (i *Index) Swap(ctx context.Context) error {
+// Stage creates fresh empty staging index, all writes go into it until Swap
+func (i *Index) Stage(ctx context.Context) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.discardStaging(ctx)
+	staging, err := bleve.New(i.path+stagingSuffix, getIndexMapping(ctx))
+	if err != nil {
+		return err
+	}
+	i.staging = staging
+	return nil
+}
+
+// Swap replaces the live index with the staging one, but only if the staging index
+// contains at least minDocs documents and at least minRatio of the live index documents.
+// Otherwise the staging index is discarded and the live index is kept.
+// If the index files cannot be moved, the swap is aborted: the live index is restored and reopened, and the error is returned
+func (i *Index) Swap(ctx context.Context, minDocs int, minRatio float64) (err error) {
 	defer func() {
 		// bleve's scorch has data race that may cause panic
 		if r := recover(); r != nil {
@@ -155,29 +261,186 @@ func (i *Index) Swap(ctx context.Context) error {
 			log.Error().Interface("recover", r).Msg("panic in index swap")
 		}
 	}()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.staging == nil {
+		return fmt.Errorf("staging index does not exist")
+	}
 
+	log := zerolog.Ctx(ctx)
+	live := docCount(i.index)
+	stagedUint, err := i.staging.DocCount()
+	if err != nil {
+		i.discardStaging(ctx)
+		return err
+	}
+	staged := int(stagedUint) //nolint:gosec // that's ok
+	if reason := swapRejectReason(live, staged, minDocs, minRatio); reason != "" {
+		log.Warn().Int("live", live).Int("staged", staged).Str("reason", reason).Msg("index swap aborted, keeping the live index")
+		i.discardStaging(ctx)
+		return fmt.Errorf("index swap aborted: %s", reason)
+	}
+	log.Info().Int("live", live).Int("staged", staged).Msg("swapping index")
+
+	if err := i.staging.Close(); err != nil {
+		i.discardStaging(ctx)
+		return err
+	}
+	i.staging = nil
 	if err := i.index.Close(); err != nil {
+		i.discardStaging(ctx)
 		return err
 	}
 
-	log := zerolog.Ctx(ctx)
 	if err := os.RemoveAll(i.path + backupSuffix); err != nil {
 		log.Warn().Err(err).Msg("cannot remove index backup")
 	}
-
 	if err := os.Rename(i.path, i.path+backupSuffix); err != nil {
-		log.Error().Err(err).Msg("cannot move index")
+		log.Error().Err(err).Msg("cannot move index, swap aborted")
+		i.discardStaging(ctx)
+		return errors.Join(fmt.Errorf("cannot move index: %w", err), i.load(ctx))
+	}
+	if err := os.Rename(i.path+stagingSuffix, i.path); err != nil {
+		log.Error().Err(err).Msg("cannot move staging index, swap aborted")
+		i.discardStaging(ctx)
+		return errors.Join(fmt.Errorf("cannot move staging index: %w", err), os.Rename(i.path+backupSuffix, i.path), i.load(ctx))
 	}
 	return i.load(ctx)
 }
 
+// swapRejectReason returns reason to reject index swap, or empty string if swap is allowed
+func swapRejectReason(live, staged, minDocs int, minRatio float64) string {
+	if staged == 0 && live > 0 {
+		return "staging index is empty"
+	}
+	if staged < minDocs {
+		return fmt.Sprintf("staging index has less than %d documents", minDocs)
+	}
+	if live > 0 && float64(staged) < float64(live)*minRatio {
+		return fmt.Sprintf("staging index has less than %.2f of the live index documents", minRatio)
+	}
+	return ""
+}
+
+// discardStaging closes and removes staging index, if any, must be called with the lock held
+func (i *Index) discardStaging(ctx context.Context) {
+	log := zerolog.Ctx(ctx)
+	if i.staging != nil {
+		if err := i.staging.Close(); err != nil {
+			log.Warn().Err(err).Msg("cannot close staging index")
+		}
+		i.staging = nil
+	}
+	if err := os.RemoveAll(i.path + stagingSuffix); err != nil {
+		log.Warn().Err(err).Msg("cannot remove staging index")
+	}
+}
+
+// writable returns index that should receive writes: staging one if exists, otherwise live.
+// Must be called with the lock held for the whole operation, so the index is not swapped (and closed) while in use
+func (i *Index) writable() bleve.Index {
+	if i.staging != nil {
+		return i.staging
+	}
+	return i.index
+}
+
+// indexes returns the live index and the staging one, if exists.
+// Must be called with the lock held for the whole operation, so the indexes are not swapped (and closed) while in use
+func (i *Index) indexes() []bleve.Index {
+	if i.staging != nil {
+		return []bleve.Index{i.index, i.staging}
+	}
+	return []bleve.Index{i.index}
+}
+
 // Len returns size of the index (number of docs)
 func (i *Index) Len() int {
-	vUint, _ := i.index.DocCount() //nolint:errcheck // that's ok
-	return int(vUint)              //nolint:gosec // that's ok
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return docCount(i.index)
+}
+
+// docCount returns number of docs in the index
+func docCount(index bleve.Index) int {
+	vUint, _ := index.DocCount() //nolint:errcheck // that's ok
+	return int(vUint)            //nolint:gosec // that's ok
+}
+
+// Info returns index doc count, size on disk and bleve's internal stats
+func (i *Index) Info() (*model.IndexInfo, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	count, err := i.index.DocCount()
+	if err != nil {
+		return nil, err
+	}
+
+	var size int64
+	err = filepath.WalkDir(i.path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.IndexInfo{
+		DocCount:   count,
+		SizeOnDisk: size,
+		Stats:      i.index.StatsMap(),
+	}, nil
+}
+
+// Mapping returns JSON-encoded mapping of the live index: document types, fields, analyzers, and custom analysis components
+func (i *Index) Mapping() ([]byte, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return json.Marshal(i.index.Mapping())
+}
+
+// Optimize force merges segments of the live index into a single one, returns segments count before and after
+func (i *Index) Optimize(ctx context.Context) (before, after uint64, err error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	advanced, err := i.index.Advanced()
+	if err != nil {
+		return 0, 0, err
+	}
+	sc, ok := advanced.(*scorch.Scorch)
+	if !ok {
+		return 0, 0, fmt.Errorf("index does not support force merge")
+	}
+
+	before = segmentsCount(sc)
+	mo := mergeplan.SingleSegmentMergePlanOptions
+	if err := sc.ForceMerge(ctx, &mo); err != nil {
+		return before, before, err
+	}
+	return before, segmentsCount(sc), nil
+}
+
+// segmentsCount returns number of the in-memory and persisted segments of the index
+func segmentsCount(sc *scorch.Scorch) uint64 {
+	stats := sc.StatsMap()
+	memory, _ := stats["num_root_memorysegments"].(uint64) //nolint:errcheck // that's ok
+	file, _ := stats["num_root_filesegments"].(uint64)     //nolint:errcheck // that's ok
+	return memory + file
 }
 
 // Close index
 func (i *Index) Close() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
 	return i.index.Close()
 }
@@ -0,0 +1,115 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/pemistahl/lingua-go"
+
+	"github.com/etkecc/mrs/internal/model"
+)
+
+// newTestIndex creates an index in the test's temp dir, with diacritics folding enabled
+func newTestIndex(t *testing.T) *Index {
+	t.Helper()
+	detector := lingua.NewLanguageDetectorBuilder().FromLanguages(lingua.English, lingua.German).Build()
+	i, err := NewIndex(filepath.Join(t.TempDir(), "index"), detector, "en", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { i.Close() })
+	return i
+}
+
+// indexRooms adds entries into the writable index
+func indexRooms(t *testing.T, i *Index, entries ...*model.Entry) {
+	t.Helper()
+	batch := i.NewBatch()
+	for _, entry := range entries {
+		if entry.Type == "" {
+			entry.Type = "room"
+		}
+		if err := batch.Index(entry.ID, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := i.IndexBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSwap(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIndex(t)
+	indexRooms(t, i, &model.Entry{ID: "!old:example.com", Name: "old"})
+
+	if err := i.Stage(ctx); err != nil {
+		t.Fatal(err)
+	}
+	indexRooms(t, i, &model.Entry{ID: "!a:example.com", Name: "a"}, &model.Entry{ID: "!b:example.com", Name: "b"})
+	if i.Len() != 1 {
+		t.Fatalf("staged rooms are visible in the live index: %d docs", i.Len())
+	}
+
+	// readers of the live index run concurrently with the swap, and never use the closed index
+	query := model.NewFieldQuery(model.QueryTerm, "id", "!old:example.com", 0)
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					i.Len()
+					if _, err := i.Has("!a:example.com"); err != nil {
+						t.Error(err)
+						return
+					}
+					if _, _, err := i.Search(ctx, query, 1, 0, []string{"_id"}, nil); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}()
+	}
+	err := i.Swap(ctx, 1, 0.5)
+	close(done)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if i.Len() != 2 {
+		t.Errorf("live index after swap: got %d docs, want 2", i.Len())
+	}
+	if ok, err := i.Has("!old:example.com"); err != nil || ok {
+		t.Errorf("room of the old index is present after swap: %v %v", ok, err)
+	}
+	if err := i.Swap(ctx, 1, 0.5); err == nil {
+		t.Error("swap without staging index succeeded")
+	}
+}
+
+func TestSwap_Rejected(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIndex(t)
+	indexRooms(t, i, &model.Entry{ID: "!a:example.com", Name: "a"}, &model.Entry{ID: "!b:example.com", Name: "b"})
+
+	if err := i.Stage(ctx); err != nil {
+		t.Fatal(err)
+	}
+	indexRooms(t, i, &model.Entry{ID: "!c:example.com", Name: "c"})
+	if err := i.Swap(ctx, 1, 0.9); err == nil {
+		t.Fatal("swap of too small staging index succeeded")
+	}
+	if i.Len() != 2 {
+		t.Errorf("live index after rejected swap: got %d docs, want 2", i.Len())
+	}
+}
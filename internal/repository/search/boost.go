@@ -0,0 +1,73 @@
+package search
+
+import (
+	"context"
+	"math"
+
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/numeric"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+	index "github.com/blevesearch/bleve_index_api"
+)
+
+// numericBoostQuery is a function query: matches of the wrapped query are re-scored by the numeric field value,
+// see model.QueryNumericBoost for the formula
+type numericBoostQuery struct {
+	query      query.Query
+	field      string
+	weight     float64
+	saturation float64
+}
+
+// Searcher wraps the searcher of the query, reading the field values from the doc values (same as sorting does)
+func (q *numericBoostQuery) Searcher(ctx context.Context, i index.IndexReader, m mapping.IndexMapping, options search.SearcherOptions) (search.Searcher, error) {
+	searcher, err := q.query.Searcher(ctx, i, m, options)
+	if err != nil {
+		return nil, err
+	}
+	dvReader, err := i.DocValueReader([]string{q.field})
+	if err != nil {
+		searcher.Close()
+		return nil, err
+	}
+	return &numericBoostSearcher{Searcher: searcher, dvReader: dvReader, query: q}, nil
+}
+
+type numericBoostSearcher struct {
+	search.Searcher
+	dvReader index.DocValueReader
+	query    *numericBoostQuery
+}
+
+func (s *numericBoostSearcher) Next(ctx *search.SearchContext) (*search.DocumentMatch, error) {
+	return s.boost(s.Searcher.Next(ctx))
+}
+
+func (s *numericBoostSearcher) Advance(ctx *search.SearchContext, id index.IndexInternalID) (*search.DocumentMatch, error) {
+	return s.boost(s.Searcher.Advance(ctx, id))
+}
+
+// boost multiplies the match score by 1 + weight × min(1, log10(1 + value) / log10(1 + saturation))
+func (s *numericBoostSearcher) boost(match *search.DocumentMatch, err error) (*search.DocumentMatch, error) {
+	if err != nil || match == nil {
+		return match, err
+	}
+	var value float64
+	if err := s.dvReader.VisitDocValues(match.IndexInternalID, func(_ string, term []byte) {
+		prefixCoded := numeric.PrefixCoded(term)
+		if shift, err := prefixCoded.Shift(); err != nil || shift != 0 { // lower precision terms of the same value
+			return
+		}
+		if i64, err := prefixCoded.Int64(); err == nil {
+			value = numeric.Int64ToFloat64(i64)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	if value > 0 {
+		normalized := math.Min(1, math.Log10(1+value)/math.Log10(1+s.query.saturation))
+		match.Score *= 1 + s.query.weight*normalized
+	}
+	return match, nil
+}
@@ -8,20 +8,63 @@ import (
 
 // Index new data
 func (i *Index) Index(roomID string, data *model.Entry) error {
-	return i.index.Index(roomID, data)
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.writable().Index(roomID, data)
 }
 
-// Delete room from index
+// Delete room from index (both live and staging)
 func (i *Index) Delete(roomID string) error {
-	return i.index.Delete(roomID)
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	for _, idx := range i.indexes() {
+		if err := idx.Delete(roomID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteBatch removes rooms from index (both live and staging) using a single batch per index
+func (i *Index) DeleteBatch(roomIDs []string) error {
+	if len(roomIDs) == 0 {
+		return nil
+	}
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	for _, idx := range i.indexes() {
+		batch := idx.NewBatch()
+		for _, roomID := range roomIDs {
+			batch.Delete(roomID)
+		}
+		if err := idx.Batch(batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Has checks if room is present in the live index
+func (i *Index) Has(roomID string) (bool, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	doc, err := i.index.Document(roomID)
+	if err != nil {
+		return false, err
+	}
+	return doc != nil, nil
 }
 
 // IndexBatch of entries
 func (i *Index) IndexBatch(batch *bleve.Batch) error {
-	return i.index.Batch(batch)
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.writable().Batch(batch)
 }
 
 // NewBatch creates new batch
 func (i *Index) NewBatch() *bleve.Batch {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
 	return i.index.NewBatch()
 }
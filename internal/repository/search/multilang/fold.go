@@ -0,0 +1,79 @@
+package multilang
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2/analysis"
+	"golang.org/x/text/unicode/norm"
+)
+
+// FoldName is the name of the token filter that folds diacritics, e.g. "café" -> "cafe"
+const FoldName = Name + "_fold"
+
+// foldScripts are scripts whose letters are folded, combining marks of other scripts (e.g. Devanagari vowel signs)
+// are meaningful and kept as is
+var foldScripts = []*unicode.RangeTable{unicode.Latin, unicode.Greek, unicode.Cyrillic}
+
+// foldLetters are (lowercase) letters that don't have canonical decomposition, but are commonly typed without diacritics
+var foldLetters = map[rune]string{
+	'æ': "ae",
+	'đ': "d",
+	'ħ': "h",
+	'ı': "i",
+	'ł': "l",
+	'ø': "o",
+	'œ': "oe",
+	'ß': "ss",
+	'þ': "th",
+}
+
+// FoldFilter removes diacritics from the (lowercased) tokens, if enabled, so "cafe" and "café" are the same term
+type FoldFilter struct {
+	enabled bool
+}
+
+// Filter folds diacritics of the tokens
+func (f *FoldFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	if !f.enabled {
+		return input
+	}
+	for _, token := range input {
+		token.Term = Fold(token.Term)
+	}
+	return input
+}
+
+// Fold removes combining marks of the Latin, Greek, and Cyrillic letters and replaces the letters from foldLetters
+func Fold(term []byte) []byte {
+	if isASCII(term) {
+		return term
+	}
+
+	decomposed := norm.NFD.Bytes(term)
+	folded := make([]byte, 0, len(decomposed))
+	var base rune
+	for len(decomposed) > 0 {
+		r, size := utf8.DecodeRune(decomposed)
+		decomposed = decomposed[size:]
+		if unicode.Is(unicode.Mn, r) && unicode.IsOneOf(foldScripts, base) {
+			continue
+		}
+		base = r
+		if replacement, ok := foldLetters[r]; ok {
+			folded = append(folded, replacement...)
+			continue
+		}
+		folded = utf8.AppendRune(folded, r)
+	}
+	return norm.NFC.Bytes(folded)
+}
+
+func isASCII(term []byte) bool {
+	for _, b := range term {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package multilang
+
+import (
+	"testing"
+
+	"github.com/blevesearch/bleve/v2/analysis"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		term     string
+		expected string
+	}{
+		{"cafe", "cafe"},
+		{"café", "cafe"},
+		{"crème", "creme"},
+		{"straße", "strasse"},
+		{"smørrebrød", "smorrebrod"},
+		{"łódź", "lodz"},
+		{"καλημέρα", "καλημερα"},
+		{"ёлка", "елка"},
+		{"हिंदी", "हिंदी"}, // combining marks of Devanagari are kept
+		{"☕", "☕"},
+	}
+	for _, test := range tests {
+		t.Run(test.term, func(t *testing.T) {
+			if folded := string(Fold([]byte(test.term))); folded != test.expected {
+				t.Errorf("got %q, want %q", folded, test.expected)
+			}
+		})
+	}
+}
+
+func TestFoldFilter_Disabled(t *testing.T) {
+	stream := (&FoldFilter{}).Filter(analysis.TokenStream{{Term: []byte("café")}})
+	if term := string(stream[0].Term); term != "café" {
+		t.Errorf("got %q, want %q", term, "café")
+	}
+}
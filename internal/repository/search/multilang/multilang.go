@@ -2,6 +2,7 @@ package multilang
 
 import (
 	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
 	"github.com/blevesearch/bleve/v2/registry"
 	"github.com/pemistahl/lingua-go"
 	"github.com/rs/zerolog"
@@ -17,8 +18,36 @@ const (
 	LangDivider = byte('_')
 )
 
-// Register multilang analyzer
-func Register(detector lingua.LanguageDetector, defaultLang string) {
+// Analyzers maps ISO 639-1 language codes to the language-specific analyzers
+var Analyzers = map[string]string{
+	"ar": "ar",
+	"da": "da",
+	"de": "de",
+	"en": "en",
+	"es": "es",
+	"fa": "fa",
+	"fi": "fi",
+	"fr": "fr",
+	"hi": "hi",
+	"hr": "hr",
+	"hu": "hu",
+	"it": "it",
+	"nl": "nl",
+	"no": "no",
+	"pl": "pl",
+	"pt": "pt",
+	"ro": "ro",
+	"ru": "ru",
+	"sv": "sv",
+	"tr": "tr",
+	"zh": "cjk",
+	"ja": "cjk",
+	"ko": "cjk",
+}
+
+// Register multilang analyzer, foldAccents enables diacritics folding of the language-neutral (unicode) analyzer
+// and the FoldName token filter
+func Register(detector lingua.LanguageDetector, defaultLang string, foldAccents bool) {
 	log := zerolog.Ctx(utils.NewContext())
 	defer func() {
 		if err := recover(); err != nil {
@@ -53,6 +82,35 @@ func Register(detector lingua.LanguageDetector, defaultLang string) {
 			Tokenizer:   tokenizer,
 		}
 
+		return analyzer, nil
+	})
+	registry.RegisterTokenFilter(FoldName, func(_ map[string]any, _ *registry.Cache) (analysis.TokenFilter, error) {
+		return &FoldFilter{enabled: foldAccents}, nil
+	})
+	registry.RegisterTokenizer(UnicodeName, func(_ map[string]any, _ *registry.Cache) (analysis.Tokenizer, error) {
+		return &UnicodeTokenizer{}, nil
+	})
+	registry.RegisterAnalyzer(UnicodeName, func(_ map[string]any, cache *registry.Cache) (analysis.Analyzer, error) {
+		tokenizer, err := cache.TokenizerNamed(UnicodeName)
+		if err != nil {
+			log.Error().Err(err).Str("analyzer", UnicodeName).Msg("cannot find unicode tokenizer")
+			return nil, err
+		}
+		toLower, err := cache.TokenFilterNamed(lowercase.Name)
+		if err != nil {
+			log.Error().Err(err).Str("analyzer", UnicodeName).Msg("cannot find lowercase token filter")
+			return nil, err
+		}
+		fold, err := cache.TokenFilterNamed(FoldName)
+		if err != nil {
+			log.Error().Err(err).Str("analyzer", UnicodeName).Msg("cannot find fold token filter")
+			return nil, err
+		}
+		analyzer := &analysis.DefaultAnalyzer{
+			Tokenizer:    tokenizer,
+			TokenFilters: []analysis.TokenFilter{toLower, fold},
+		}
+
 		return analyzer, nil
 	})
 }
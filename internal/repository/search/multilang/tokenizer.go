@@ -53,8 +53,8 @@ func (d *Tokenizer) Tokenize(input []byte) analysis.TokenStream {
 	}
 	lang := string(input[langpos+1:])
 	input = input[:langpos]
-	if lang == "zh" || lang == "ja" || lang == "ko" {
-		lang = "cjk"
+	if analyzerName, ok := Analyzers[lang]; ok {
+		lang = analyzerName
 	}
 	analyzer, err := d.cache.AnalyzerNamed(lang)
 	if err != nil {
@@ -0,0 +1,69 @@
+package multilang
+
+import (
+	"bytes"
+	"unicode"
+
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/blevesearch/segment"
+)
+
+// UnicodeName is the name of the language-neutral tokenizer and analyzer, that keep emoji and other symbols
+const UnicodeName = Name + "_unicode"
+
+// variationSelectors (text and emoji presentation) are removed from the symbol tokens,
+// so "☕" with and without VS16 is the same term
+var variationSelectors = []byte("\uFE0E\uFE0F")
+
+// UnicodeTokenizer splits input into words using unicode text segmentation, the same way as bleve's unicode tokenizer does,
+// but keeps emoji and other symbols as separate tokens instead of dropping them
+type UnicodeTokenizer struct{}
+
+// Tokenize converts input bytes into token stream
+func (t *UnicodeTokenizer) Tokenize(input []byte) analysis.TokenStream {
+	stream := analysis.TokenStream{}
+	segmenter := segment.NewWordSegmenterDirect(input)
+	start := 0
+	pos := 1
+	for segmenter.Segment() {
+		term := segmenter.Bytes()
+		end := start + len(term)
+		tokenType := analysis.AlphaNumeric
+		switch segmenter.Type() {
+		case segment.Ideo, segment.Kana:
+			tokenType = analysis.Ideographic
+		case segment.Number:
+			tokenType = analysis.Numeric
+		case segment.None:
+			term = symbolTerm(term)
+		}
+		if len(term) > 0 {
+			stream = append(stream, &analysis.Token{
+				Term:     term,
+				Start:    start,
+				End:      end,
+				Position: pos,
+				Type:     tokenType,
+			})
+			pos++
+		}
+		start = end
+	}
+	return stream
+}
+
+// symbolTerm returns the segment without variation selectors if it contains a symbol (e.g., emoji),
+// or nil for whitespace, punctuation and other separators
+func symbolTerm(input []byte) []byte {
+	if !bytes.ContainsFunc(input, func(r rune) bool { return unicode.Is(unicode.So, r) }) {
+		return nil
+	}
+	term := make([]byte, 0, len(input))
+	for _, r := range string(input) {
+		if bytes.ContainsRune(variationSelectors, r) {
+			continue
+		}
+		term = append(term, string(r)...)
+	}
+	return term
+}
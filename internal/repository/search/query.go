@@ -0,0 +1,86 @@
+package search
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+
+	"github.com/etkecc/mrs/internal/model"
+)
+
+// fieldQuery is a bleve query targeting a single field
+type fieldQuery interface {
+	query.Query
+	SetField(string)
+	SetBoost(float64)
+}
+
+// toBleveQuery translates backend-neutral query spec into the bleve query
+func toBleveQuery(spec *model.Query) (query.Query, error) {
+	if spec == nil {
+		return nil, fmt.Errorf("empty query")
+	}
+
+	switch spec.Kind {
+	case model.QueryMatch, model.QueryPhrase, model.QueryFuzzy, model.QueryTerm:
+		return toBleveFieldQuery(spec), nil
+	case model.QueryNumericRange:
+		inclusive := true
+		rangeQ := bleve.NewNumericRangeInclusiveQuery(spec.Min, spec.Max, &inclusive, &inclusive)
+		rangeQ.SetField(spec.Field)
+		return rangeQ, nil
+	case model.QueryDateRange:
+		inclusive, exclusive := true, false
+		rangeQ := bleve.NewDateRangeInclusiveQuery(spec.After, spec.Before, &inclusive, &exclusive)
+		rangeQ.SetField(spec.Field)
+		return rangeQ, nil
+	case model.QueryAnd, model.QueryOr:
+		queries := make([]query.Query, 0, len(spec.Queries))
+		for _, subspec := range spec.Queries {
+			subquery, err := toBleveQuery(subspec)
+			if err != nil {
+				return nil, err
+			}
+			queries = append(queries, subquery)
+		}
+		if spec.Kind == model.QueryAnd {
+			return bleve.NewConjunctionQuery(queries...), nil
+		}
+		return bleve.NewDisjunctionQuery(queries...), nil
+	case model.QueryNumericBoost:
+		if len(spec.Queries) != 1 || spec.Max == nil || *spec.Max <= 0 {
+			return nil, fmt.Errorf("numeric boost query requires exactly one query and positive max")
+		}
+		subquery, err := toBleveQuery(spec.Queries[0])
+		if err != nil {
+			return nil, err
+		}
+		return &numericBoostQuery{query: subquery, field: spec.Field, weight: spec.Boost, saturation: *spec.Max}, nil
+	default:
+		return nil, fmt.Errorf("unsupported query kind %q", spec.Kind)
+	}
+}
+
+// toBleveFieldQuery translates match, phrase, fuzzy, or term query spec into the bleve query
+func toBleveFieldQuery(spec *model.Query) query.Query {
+	var fieldQ fieldQuery
+	switch spec.Kind {
+	case model.QueryPhrase:
+		phraseQuery := bleve.NewMatchPhraseQuery(spec.Value)
+		phraseQuery.Analyzer = spec.Analyzer
+		fieldQ = phraseQuery
+	case model.QueryFuzzy:
+		fieldQ = bleve.NewFuzzyQuery(spec.Value)
+	case model.QueryTerm:
+		fieldQ = bleve.NewTermQuery(spec.Value)
+	default:
+		matchQuery := bleve.NewMatchQuery(spec.Value)
+		matchQuery.Analyzer = spec.Analyzer
+		fieldQ = matchQuery
+	}
+	fieldQ.SetField(spec.Field)
+	fieldQ.SetBoost(spec.Boost)
+
+	return fieldQ
+}
@@ -2,28 +2,49 @@ package search
 
 import (
 	"context"
+	"sort"
+	"time"
 
 	"github.com/blevesearch/bleve/v2"
 	"github.com/blevesearch/bleve/v2/search"
-	"github.com/blevesearch/bleve/v2/search/query"
+	index "github.com/blevesearch/bleve_index_api"
 
 	"github.com/etkecc/mrs/internal/model"
 	"github.com/etkecc/mrs/internal/utils"
 )
 
 // Search something!
-func (i *Index) Search(ctx context.Context, searchQuery query.Query, limit, offset int, sortBy []string) (results []*model.Entry, total int, err error) {
+// If searchAfter (sort values of the last hit of the previous page) is provided, offset is ignored
+func (i *Index) Search(ctx context.Context, searchQuery *model.Query, limit, offset int, sortBy, searchAfter []string) (results []*model.Entry, total int, err error) {
 	span := utils.StartSpan(ctx, "search.Search")
 	defer span.Finish()
 
-	req := bleve.NewSearchRequestOptions(searchQuery, limit, offset, false)
+	if len(searchAfter) > 0 {
+		offset = 0
+	}
+	bleveQuery, err := toBleveQuery(searchQuery)
+	if err != nil {
+		return nil, 0, err
+	}
+	req := bleve.NewSearchRequestOptions(bleveQuery, limit, offset, false)
 	req.Fields = []string{"*"}
 	req.SortBy(sortBy)
+	if len(searchAfter) > 0 {
+		req.SetSearchAfter(searchAfter)
+	}
 
+	span.SetData("limit", limit)
+	span.SetData("offset", offset)
+	span.SetData("sort", sortBy)
+	i.mu.RLock()
 	resp, err := i.index.Search(req)
+	i.mu.RUnlock()
 	if err != nil {
 		return nil, 0, err
 	}
+	span.SetData("hits", len(resp.Hits))
+	span.SetData("total", resp.Total)
+	span.SetData("took", resp.Took.String())
 	if resp.Total == 0 {
 		return nil, 0, nil
 	}
@@ -31,6 +52,101 @@ func (i *Index) Search(ctx context.Context, searchQuery query.Query, limit, offs
 	return parseSearchResults(resp.Hits), int(resp.Total), nil //nolint:gosec // that's ok
 }
 
+// Suggest returns indexed terms of the fields that are close (by edit distance) to the given term,
+// sorted by distance and term frequency. If the term itself is indexed in any of the fields, nothing is suggested
+func (i *Index) Suggest(ctx context.Context, term string, fields []string, limit int) ([]string, error) {
+	span := utils.StartSpan(ctx, "search.Suggest")
+	defer span.Finish()
+
+	// the lock is held until the reader is closed, so the index is not swapped (and closed) while in use
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	advanced, err := i.index.Advanced()
+	if err != nil {
+		return nil, err
+	}
+	reader, err := advanced.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	fuzzyReader, ok := reader.(fuzzyDictReader)
+	if !ok {
+		return nil, nil
+	}
+
+	candidates := map[string]*index.DictEntry{}
+	for _, field := range fields {
+		dict, err := fuzzyReader.FieldDictFuzzy(field, term, 2, "")
+		if err != nil {
+			return nil, err
+		}
+		for entry, err := dict.Next(); entry != nil && err == nil; entry, err = dict.Next() {
+			if entry.Term == term {
+				dict.Close()
+				return nil, nil
+			}
+			if existing, ok := candidates[entry.Term]; ok {
+				existing.Count += entry.Count
+				continue
+			}
+			candidates[entry.Term] = &index.DictEntry{Term: entry.Term, Count: entry.Count, EditDistance: editDistance(term, entry.Term)}
+		}
+		dict.Close()
+	}
+
+	entries := make([]*index.DictEntry, 0, len(candidates))
+	for _, entry := range candidates {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		if entries[a].EditDistance != entries[b].EditDistance {
+			return entries[a].EditDistance < entries[b].EditDistance
+		}
+		if entries[a].Count != entries[b].Count {
+			return entries[a].Count > entries[b].Count
+		}
+		return entries[a].Term < entries[b].Term
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	terms := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		terms = append(terms, entry.Term)
+	}
+	return terms, nil
+}
+
+// fuzzyDictReader is the fuzzy dictionary part of the index.IndexReaderFuzzy, implemented by the scorch index reader
+// (that doesn't implement the full interface)
+type fuzzyDictReader interface {
+	FieldDictFuzzy(field, term string, fuzziness int, prefix string) (index.FieldDict, error)
+}
+
+// editDistance returns Levenshtein distance between the terms (in runes), capped at 255
+func editDistance(a, b string) uint8 {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		curr[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return uint8(min(prev[len(br)], 255)) //nolint:gosec // capped
+}
+
 func parseSearchResults(result []*search.DocumentMatch) []*model.Entry {
 	entries := make([]*model.Entry, 0, len(result))
 	for _, hit := range result {
@@ -49,12 +165,43 @@ func parseSearchResults(result []*search.DocumentMatch) []*model.Entry {
 			JoinRule:      parseHitField[string](hit, "join_rule"),
 			GuestJoinable: parseHitField[bool](hit, "guest_can_join"),
 			WorldReadable: parseHitField[bool](hit, "world_readable"),
+			Tags:          parseHitStrings(hit, "tags"),
+			LastActive:    parseHitTime(hit, "last_active"),
+			FirstSeen:     parseHitTime(hit, "first_seen"),
+			Sort:          hit.Sort,
 		})
 	}
 
 	return entries
 }
 
+// parseHitStrings parses stored multi-value string field, bleve returns single value as is, and multiple values as a slice
+func parseHitStrings(hit *search.DocumentMatch, field string) []string {
+	switch v := hit.Fields[field].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if value, ok := item.(string); ok {
+				values = append(values, value)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// parseHitTime parses stored datetime field (RFC3339 string)
+func parseHitTime(hit *search.DocumentMatch, field string) time.Time {
+	t, err := time.Parse(time.RFC3339, parseHitField[string](hit, field))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
 func parseHitField[T any](hit *search.DocumentMatch, field string) T {
 	var zero T
 	v, ok := hit.Fields[field].(T)
@@ -0,0 +1,188 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/etkecc/mrs/internal/model"
+	"github.com/etkecc/mrs/internal/repository/search/multilang"
+)
+
+func TestSuggest(t *testing.T) {
+	i := newTestIndex(t)
+	indexRooms(t, i,
+		&model.Entry{ID: "!a:example.com", Alias: "#matrix:example.com"},
+		&model.Entry{ID: "!b:example.com", Alias: "#matrix-dev:example.com"},
+		&model.Entry{ID: "!c:example.com", Alias: "#metrics:example.com"},
+	)
+
+	tests := []struct {
+		term     string
+		expected string
+	}{
+		{"matrx", "matrix"},
+		{"matrix", ""}, // exact hit, no suggestion
+		{"metrics", ""},
+		{"metrix", "matrix"}, // same distance, more frequent
+		{"ffffffff", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.term, func(t *testing.T) {
+			terms, err := i.Suggest(context.Background(), test.term, []string{"alias"}, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var suggested string
+			if len(terms) > 0 {
+				suggested = terms[0]
+			}
+			if suggested != test.expected {
+				t.Errorf("got %q, want %q", suggested, test.expected)
+			}
+		})
+	}
+}
+
+func TestSearch_PaginationTies(t *testing.T) {
+	i := newTestIndex(t)
+	entries := make([]*model.Entry, 0, 25)
+	for n := range 25 {
+		entries = append(entries, &model.Entry{ID: fmt.Sprintf("!%02d:example.com", 24-n), Name: "general", Members: 10})
+	}
+	indexRooms(t, i, entries...)
+	query := model.NewFieldQuery(model.QueryMatch, "name", "general", 0)
+	query.Analyzer = multilang.Name
+	sortBy := []string{"-members", "_id"} // all rooms have the same score and members count
+
+	for _, cursor := range []bool{false, true} {
+		seen := map[string]bool{}
+		var offset int
+		var searchAfter []string
+		for page := 0; page < 10; page++ {
+			results, _, err := i.Search(context.Background(), query, 4, offset, sortBy, searchAfter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) == 0 {
+				break
+			}
+			for _, result := range results {
+				if seen[result.ID] {
+					t.Errorf("cursor=%t: %s is returned again on page %d", cursor, result.ID, page)
+				}
+				seen[result.ID] = true
+			}
+			offset += len(results)
+			if cursor {
+				searchAfter = results[len(results)-1].Sort
+			}
+		}
+		if len(seen) != len(entries) {
+			t.Errorf("cursor=%t: got %d rooms across pages, want %d", cursor, len(seen), len(entries))
+		}
+	}
+}
+
+func TestSearch_SortByServer(t *testing.T) {
+	i := newTestIndex(t)
+	indexRooms(t, i,
+		&model.Entry{ID: "!b:matrix.org", Name: "general", Server: "matrix.org"},
+		&model.Entry{ID: "!a:matrix.org", Name: "general", Server: "matrix.org"},
+		&model.Entry{ID: "!a:b-server.com", Name: "general", Server: "b-server.com"},
+		&model.Entry{ID: "!a:a.server.com", Name: "general", Server: "a.server.com"},
+		&model.Entry{ID: "!a:z-a.example.org", Name: "general", Server: "z-a.example.org"}, // would be sorted as "a.example.org" if analyzed
+	)
+	query := model.NewFieldQuery(model.QueryMatch, "name", "general", 0)
+	query.Analyzer = multilang.Name
+
+	expected := []string{"!a:a.server.com", "!a:b-server.com", "!a:matrix.org", "!b:matrix.org", "!a:z-a.example.org"}
+	for range 3 {
+		results, _, err := i.Search(context.Background(), query, 10, 0, []string{"server", "_id"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, 0, len(results))
+		for _, result := range results {
+			ids = append(ids, result.ID)
+		}
+		if !slices.Equal(ids, expected) {
+			t.Fatalf("got %v, want %v", ids, expected)
+		}
+	}
+}
+
+func TestSearch_Unicode(t *testing.T) {
+	i := newTestIndex(t)
+	indexRooms(t, i,
+		&model.Entry{ID: "!coffee:example.com", Name: "☕️ Coffee lovers"},
+		&model.Entry{ID: "!ru:example.com", Name: "Любители кофе"},
+		&model.Entry{ID: "!tea:example.com", Name: "🍵 Tea lovers", Topic: "no coffee here"},
+	)
+	tests := []struct {
+		term     string
+		expected []string
+	}{
+		{"☕", []string{"!coffee:example.com"}},  // without variation selector
+		{"☕️", []string{"!coffee:example.com"}}, // with variation selector
+		{"🍵", []string{"!tea:example.com"}},
+		{"кофе", []string{"!ru:example.com"}},
+		{"ЛЮБИТЕЛИ", []string{"!ru:example.com"}},
+	}
+	for _, test := range tests {
+		t.Run(test.term, func(t *testing.T) {
+			query := model.NewFieldQuery(model.QueryMatch, "name.unicode", test.term, 0)
+			query.Analyzer = multilang.UnicodeName
+			results, _, err := i.Search(context.Background(), query, 10, 0, []string{"_id"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, 0, len(results))
+			for _, result := range results {
+				ids = append(ids, result.ID)
+			}
+			if !slices.Equal(ids, test.expected) {
+				t.Errorf("got %v, want %v", ids, test.expected)
+			}
+		})
+	}
+}
+
+func TestSearch_Accents(t *testing.T) {
+	i := newTestIndex(t)
+	indexRooms(t, i,
+		&model.Entry{ID: "!cafe:example.com", Name: "Café Central", Alias: "#café:example.com"},
+		&model.Entry{ID: "!de:example.com", Name: "Straßenbahn Fans", Language: "de"},
+		&model.Entry{ID: "!other:example.com", Name: "Coffee corner", Topic: "Crème brûlée", Alias: "#coffee:example.com"},
+	)
+	tests := []struct {
+		field    string
+		analyzer string
+		term     string
+		expected []string
+	}{
+		{"name.unicode", multilang.UnicodeName, "cafe", []string{"!cafe:example.com"}},
+		{"name.unicode", multilang.UnicodeName, "CAFÉ", []string{"!cafe:example.com"}},
+		{"name.unicode", multilang.UnicodeName, "strassenbahn", []string{"!de:example.com"}},
+		{"alias", "", "cafe", []string{"!cafe:example.com"}},
+		{"topic.unicode", multilang.UnicodeName, "creme", []string{"!other:example.com"}},
+	}
+	for _, test := range tests {
+		t.Run(test.field+"/"+test.term, func(t *testing.T) {
+			query := model.NewFieldQuery(model.QueryMatch, test.field, test.term, 0)
+			query.Analyzer = test.analyzer
+			results, _, err := i.Search(context.Background(), query, 10, 0, []string{"_id"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, 0, len(results))
+			for _, result := range results {
+				ids = append(ids, result.ID)
+			}
+			if !slices.Equal(ids, test.expected) {
+				t.Errorf("got %v, want %v", ids, test.expected)
+			}
+		})
+	}
+}
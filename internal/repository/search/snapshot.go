@@ -0,0 +1,195 @@
+package search
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/rs/zerolog"
+)
+
+const (
+	snapshotSuffix       = ".snapshot"
+	importSuffix         = ".import"
+	loadedSnapshotSuffix = ".loaded"
+)
+
+// Snapshot writes gzipped tarball of the live index into w.
+// The index is copied from a single point-in-time snapshot of scorch first (writes are not blocked,
+// but the copy contains only batches persisted before the snapshot, and never a partially applied batch),
+// and only then the copy is streamed, so slow readers don't hold the index.
+// Each snapshot is copied into its own temporary dir, so concurrent snapshots don't clash
+func (i *Index) Snapshot(ctx context.Context, w io.Writer) error {
+	dir, err := os.MkdirTemp(filepath.Dir(i.path), filepath.Base(i.path)+snapshotSuffix+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Msg("cannot remove index snapshot copy")
+		}
+	}()
+	if err := i.copyTo(dir); err != nil {
+		return err
+	}
+
+	return writeTarball(ctx, dir, w)
+}
+
+// copyTo copies the live index into the dir, holding the lock, so the index is not swapped (and closed) during the copy
+func (i *Index) copyTo(dir string) error {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	copyable, ok := i.index.(bleve.IndexCopyable)
+	if !ok {
+		return fmt.Errorf("index does not support snapshots")
+	}
+	return copyable.CopyTo(bleve.FileSystemDirectory(dir))
+}
+
+// LoadSnapshot replaces the index at path with the index from the snapshot tarball (created by Index.Snapshot),
+// the previous index is kept as backup. Must be called after Register and before NewIndex.
+// Loaded snapshot file is renamed (with .loaded suffix), so it will not be loaded again on the next start
+func LoadSnapshot(ctx context.Context, path, snapshotPath string) error {
+	log := zerolog.Ctx(ctx)
+	f, err := os.Open(snapshotPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dir := path + importSuffix
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := readTarball(f, dir); err != nil {
+		os.RemoveAll(dir) //nolint:errcheck // already failed
+		return err
+	}
+	// verify that the snapshot is a valid index before replacing the live one
+	index, err := bleve.Open(dir)
+	if err != nil {
+		os.RemoveAll(dir) //nolint:errcheck // already failed
+		return fmt.Errorf("invalid snapshot: %w", err)
+	}
+	count, _ := index.DocCount() //nolint:errcheck // that's ok
+	if err := index.Close(); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(path + backupSuffix); err != nil {
+		log.Warn().Err(err).Msg("cannot remove index backup")
+	}
+	if err := os.Rename(path, path+backupSuffix); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(dir, path); err != nil {
+		return err
+	}
+	if err := os.Rename(snapshotPath, snapshotPath+loadedSnapshotSuffix); err != nil {
+		log.Warn().Err(err).Msg("cannot rename loaded index snapshot")
+	}
+	log.Info().Uint64("docs", count).Str("snapshot", snapshotPath).Msg("index snapshot has been loaded")
+	return nil
+}
+
+// writeTarball writes gzipped tarball of the dir contents into w, paths are relative to the dir
+func writeTarball(ctx context.Context, dir string, w io.Writer) error {
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gzw.Close()
+}
+
+// readTarball extracts regular files of the gzipped tarball into the dir
+func readTarball(r io.Reader, dir string) error {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(path, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid snapshot entry %q", header.Name)
+		}
+		if err := extractFile(tr, path); err != nil {
+			return err
+		}
+	}
+}
+
+// extractFile writes contents of the current tarball entry into the path
+func extractFile(r io.Reader, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil { //nolint:gosec // snapshots are created and loaded by the instance operator
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,66 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/etkecc/mrs/internal/model"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIndex(t)
+	indexRooms(t, i, &model.Entry{ID: "!a:example.com", Name: "a"}, &model.Entry{ID: "!b:example.com", Name: "b"})
+
+	// concurrent snapshots use their own copies
+	var wg sync.WaitGroup
+	snapshots := make([]bytes.Buffer, 3)
+	errs := make([]error, len(snapshots))
+	for n := range snapshots {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			errs[n] = i.Snapshot(ctx, &snapshots[n])
+		}(n)
+	}
+	wg.Wait()
+	for n, err := range errs {
+		if err != nil {
+			t.Fatalf("snapshot %d: %v", n, err)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(i.path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), snapshotSuffix) {
+			t.Errorf("snapshot copy %s is not removed", entry.Name())
+		}
+	}
+
+	for n := range snapshots {
+		path := filepath.Join(t.TempDir(), "index")
+		snapshotPath := filepath.Join(t.TempDir(), "snapshot.tar.gz")
+		if err := os.WriteFile(snapshotPath, snapshots[n].Bytes(), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := LoadSnapshot(ctx, path, snapshotPath); err != nil {
+			t.Fatalf("load snapshot %d: %v", n, err)
+		}
+		loaded, err := NewIndex(path, nil, "en", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.Len() != 2 {
+			t.Errorf("snapshot %d: got %d docs, want 2", n, loaded.Len())
+		}
+		loaded.Close()
+	}
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/etkecc/go-kit/workpool"
+	"github.com/rs/zerolog"
+
+	"github.com/etkecc/mrs/internal/utils"
+)
+
+// maxAvatarSize is the max size of the avatar thumbnail, bigger ones are neither served nor cached
+const maxAvatarSize = 5 * 1024 * 1024
+
+var errAvatarUnavailable = errors.New("avatar is not available")
+
+// avatarPlaceholder is served in the local-only mode, when the avatar is not cached
+var avatarPlaceholder = newAvatarPlaceholder()
+
+type avatarsMediaService interface {
+	GetClientMediaThumbnail(ctx context.Context, serverName, mediaID string, params url.Values) (io.Reader, string)
+	GetMediaThumbnail(ctx context.Context, serverName, mediaID string, params url.Values) (io.Reader, string)
+}
+
+// Avatars service serves room avatars from the media servers, or from the local cache (if configured)
+type Avatars struct {
+	cfg         ConfigService
+	media       avatarsMediaService
+	prefetching atomic.Bool
+}
+
+// NewAvatars creates new avatars service
+func NewAvatars(cfg ConfigService, media avatarsMediaService) *Avatars {
+	return &Avatars{
+		cfg:   cfg,
+		media: media,
+	}
+}
+
+// Get returns avatar thumbnail and its content type, empty content type means avatar is not available.
+// Cached avatars are served for requests with the default thumbnail params (the cache contains default thumbnails only).
+// In the local-only mode, avatars are served from the cache regardless of the params, missing ones are replaced with the placeholder,
+// and no requests to the media servers are made
+func (a *Avatars) Get(ctx context.Context, serverName, mediaID string, params url.Values) (content io.Reader, contentType string) {
+	localOnly := a.cfg.Get().Matrix.Media.LocalOnly
+	if localOnly || len(params) == 0 {
+		if cached, cachedType := a.getCached(serverName, mediaID); cachedType != "" {
+			return bytes.NewReader(cached), cachedType
+		}
+	}
+	if localOnly {
+		return bytes.NewReader(avatarPlaceholder), "image/png"
+	}
+
+	return a.getRemote(ctx, serverName, mediaID, params)
+}
+
+// Prefetch downloads missing avatars (mxc:// URIs) into the local cache, does nothing if the cache is not configured
+// or another prefetch is in progress
+func (a *Avatars) Prefetch(ctx context.Context, avatars []string) {
+	if a.cfg.Get().Path.Avatars == "" || len(avatars) == 0 {
+		return
+	}
+	if !a.prefetching.CompareAndSwap(false, true) {
+		return
+	}
+	defer a.prefetching.Store(false)
+
+	log := zerolog.Ctx(ctx)
+	started := time.Now()
+	log.Info().Int("avatars", len(avatars)).Msg("prefetching avatars...")
+	var fetched, failed atomic.Int64
+	wp := workpool.New(a.cfg.Get().Workers.Parsing)
+	for _, mxc := range utils.Uniq(avatars) {
+		serverName, mediaID, ok := utils.ParseMXC(mxc)
+		if !ok {
+			continue
+		}
+		path := a.getPath(serverName, mediaID)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		wp.Do(func() {
+			if err := a.fetch(ctx, serverName, mediaID, path); err != nil {
+				log.Debug().Err(err).Str("avatar", mxc).Msg("cannot prefetch avatar")
+				failed.Add(1)
+				return
+			}
+			fetched.Add(1)
+		})
+	}
+	wp.Run()
+	log.Info().Int64("fetched", fetched.Load()).Int64("failed", failed.Load()).Str("took", time.Since(started).String()).Msg("avatars have been prefetched")
+}
+
+// getRemote requests unauthenticated media thumbnail first (CS API, faster),
+// and falls back to authenticated media thumbnail (S2S API, slower)
+func (a *Avatars) getRemote(ctx context.Context, serverName, mediaID string, params url.Values) (content io.Reader, contentType string) {
+	content, contentType = a.media.GetClientMediaThumbnail(ctx, serverName, mediaID, params)
+	if contentType != "" {
+		return content, contentType
+	}
+	return a.media.GetMediaThumbnail(ctx, serverName, mediaID, params)
+}
+
+// getCached returns cached avatar and its (sniffed) content type, empty content type means avatar is not cached
+func (a *Avatars) getCached(serverName, mediaID string) (content []byte, contentType string) {
+	if a.cfg.Get().Path.Avatars == "" {
+		return nil, ""
+	}
+	if _, _, ok := utils.ParseMXC("mxc://" + serverName + "/" + mediaID); !ok {
+		return nil, ""
+	}
+	content, err := os.ReadFile(a.getPath(serverName, mediaID))
+	if err != nil || len(content) == 0 {
+		return nil, ""
+	}
+	return content, http.DetectContentType(content)
+}
+
+// fetch downloads default avatar thumbnail into the path, atomically
+func (a *Avatars) fetch(ctx context.Context, serverName, mediaID, path string) error {
+	content, contentType := a.getRemote(ctx, serverName, mediaID, nil)
+	if contentType == "" {
+		return errAvatarUnavailable
+	}
+	if closer, ok := content.(io.Closer); ok {
+		defer closer.Close()
+	}
+	body, err := io.ReadAll(io.LimitReader(content, maxAvatarSize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) == 0 || len(body) > maxAvatarSize || !strings.HasPrefix(http.DetectContentType(body), "image/") {
+		return errAvatarUnavailable
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, body, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// getPath returns path of the cached avatar, server name and media ID must be valid (see utils.ParseMXC)
+func (a *Avatars) getPath(serverName, mediaID string) string {
+	return filepath.Join(a.cfg.Get().Path.Avatars, serverName, mediaID)
+}
+
+// newAvatarPlaceholder generates plain gray PNG of the default thumbnail size
+func newAvatarPlaceholder() []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{R: 0xe0, G: 0xe0, B: 0xe0, A: 0xff}}, image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	png.Encode(&buf, img) //nolint:errcheck // in-memory buffer never fails
+	return buf.Bytes()
+}
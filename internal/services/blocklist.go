@@ -1,41 +1,133 @@
 package services
 
 import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
 	"strings"
 	"sync"
 
+	"github.com/rs/zerolog"
 	"golang.org/x/exp/slices"
 
+	"github.com/etkecc/mrs/internal/model"
 	"github.com/etkecc/mrs/internal/utils"
 )
 
+// maxRemoteBlocklistSize is the max size of the remote blocklist response, in bytes
+const maxRemoteBlocklistSize = 10 << 20
+
 // Blocklist service
 type Blocklist struct {
-	mu      *sync.Mutex
-	cfg     ConfigService
-	dynamic map[string]struct{}
+	mu       *sync.RWMutex
+	cfg      ConfigService
+	data     BlocklistRepository
+	index    blocklistIndex
+	onRemove func()              // called after rooms are removed, see SetOnRemove
+	dynamic  map[string]struct{} // servers added at runtime by Add, not persisted, cleared by Reset
+	servers  map[string]struct{} // servers blocked by Block, persisted
+	rooms    map[string]struct{} // rooms blocked by Block, persisted
+	remote   map[string]struct{} // servers from the blocklist.url, last successfully fetched version
+}
+
+// BlocklistRepository stores the dynamic part of the blocklist
+type BlocklistRepository interface {
+	GetBlocklist(ctx context.Context, kind string) ([]string, error)
+	AddToBlocklist(ctx context.Context, kind string, entries []string) error
+	RemoveFromBlocklist(ctx context.Context, kind string, entries []string) error
+	EachRoom(ctx context.Context, handler func(roomID string, data *model.MatrixRoom) bool)
+	RemoveRooms(ctx context.Context, keys []string)
+	RemoveRoomsHashes(ctx context.Context, roomIDs []string) error
+}
+
+type blocklistIndex interface {
+	DeleteBatch(roomIDs []string) error
 }
 
 // NewBlocklist creates new blocklist service
-func NewBlocklist(cfg ConfigService) *Blocklist {
-	return &Blocklist{
-		mu:      &sync.Mutex{},
+func NewBlocklist(cfg ConfigService, data BlocklistRepository, index blocklistIndex) *Blocklist {
+	b := &Blocklist{
+		mu:      &sync.RWMutex{},
 		cfg:     cfg,
+		data:    data,
+		index:   index,
 		dynamic: map[string]struct{}{},
+		servers: map[string]struct{}{},
+		rooms:   map[string]struct{}{},
+		remote:  map[string]struct{}{},
+	}
+	b.load(utils.NewContext())
+	// the last fetched version is already loaded, so only servers added to the remote blocklist since then are purged
+	go b.Refresh(utils.NewContext())
+
+	return b
+}
+
+// SetOnRemove sets the func called after blocked rooms are removed from the search index, e.g. to purge cached search results
+func (b *Blocklist) SetOnRemove(onRemove func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onRemove = onRemove
+}
+
+// load persisted blocklist
+func (b *Blocklist) load(ctx context.Context) {
+	log := zerolog.Ctx(ctx)
+	servers, err := b.data.GetBlocklist(ctx, model.BlocklistServers)
+	if err != nil {
+		log.Error().Err(err).Msg("cannot load blocked servers")
+	}
+	rooms, err := b.data.GetBlocklist(ctx, model.BlocklistRooms)
+	if err != nil {
+		log.Error().Err(err).Msg("cannot load blocked rooms")
+	}
+	var remote []string
+	if b.cfg.Get().Blocklist.URL != "" {
+		remote, err = b.data.GetBlocklist(ctx, model.BlocklistRemote)
+		if err != nil {
+			log.Error().Err(err).Msg("cannot load the last fetched remote blocklist")
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, server := range servers {
+		b.servers[server] = struct{}{}
+	}
+	for _, room := range rooms {
+		b.rooms[room] = struct{}{}
+	}
+	for _, server := range remote {
+		b.remote[server] = struct{}{}
 	}
 }
 
 // Len of the blocklist
 func (b *Blocklist) Len() int {
-	return len(b.cfg.Get().Blocklist.Servers) + len(b.dynamic)
+	return len(b.Slice())
 }
 
-// Slice returns slice of the static+dynamic blocklist
+// Slice returns slice of the static+dynamic+persisted+remote blocklist
 func (b *Blocklist) Slice() []string {
-	return utils.Uniq(append(utils.MapKeys(b.dynamic), b.cfg.Get().Blocklist.Servers...))
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	slice := append(utils.MapKeys(b.dynamic), utils.MapKeys(b.servers)...)
+	slice = append(slice, utils.MapKeys(b.remote)...)
+	return utils.Uniq(append(slice, b.cfg.Get().Blocklist.Servers...))
 }
 
-// Reset dynamic part of the blocklist
+// List returns persisted blocklist entries of the given kind
+func (b *Blocklist) List(kind string) []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return utils.MapKeys(b.target(kind))
+}
+
+// Reset dynamic (added at runtime by Add) part of the blocklist, persisted entries are kept
 func (b *Blocklist) Reset() {
 	b.mu.Lock()
 	b.dynamic = map[string]struct{}{}
@@ -50,8 +142,137 @@ func (b *Blocklist) Add(server string) {
 	b.dynamic[server] = struct{}{}
 }
 
-// ByID checks if server of matrixID is present in the blocklist
+// Refresh fetches the remote blocklist (blocklist.url) and replaces the previous (persisted) version of it,
+// rooms of the newly blocked servers are removed from the catalog and the search index.
+// If the fetch fails, the last fetched version is kept
+func (b *Blocklist) Refresh(ctx context.Context) {
+	remoteURL := b.cfg.Get().Blocklist.URL
+	if remoteURL == "" {
+		return
+	}
+	span := utils.StartSpan(ctx, "blocklist.Refresh")
+	defer span.Finish()
+	log := zerolog.Ctx(span.Context())
+
+	servers, err := b.fetch(span.Context(), remoteURL)
+	if err != nil {
+		log.Error().Err(err).Str("url", remoteURL).Msg("cannot fetch remote blocklist, keeping the last fetched version")
+		return
+	}
+
+	b.mu.Lock()
+	remote := make(map[string]struct{}, len(servers))
+	added := []string{}
+	for _, server := range servers {
+		remote[server] = struct{}{}
+		if _, ok := b.remote[server]; !ok {
+			added = append(added, server)
+		}
+	}
+	removed := []string{}
+	for server := range b.remote {
+		if _, ok := remote[server]; !ok {
+			removed = append(removed, server)
+		}
+	}
+	b.remote = remote
+	b.mu.Unlock()
+
+	if err := b.data.AddToBlocklist(span.Context(), model.BlocklistRemote, added); err != nil {
+		log.Error().Err(err).Msg("cannot store the remote blocklist")
+	}
+	if err := b.data.RemoveFromBlocklist(span.Context(), model.BlocklistRemote, removed); err != nil {
+		log.Error().Err(err).Msg("cannot store the remote blocklist")
+	}
+	log.Info().Int("servers", len(servers)).Int("added", len(added)).Int("removed", len(removed)).Msg("remote blocklist refreshed")
+	if len(added) > 0 {
+		b.purge(span.Context(), model.BlocklistServers, added)
+	}
+}
+
+// fetch downloads and parses the remote blocklist
+func (b *Blocklist) fetch(ctx context.Context, remoteURL string) ([]string, error) {
+	resp, err := utils.Get(ctx, remoteURL, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote blocklist returned HTTP %d", resp.StatusCode)
+	}
+
+	entries := []string{}
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, maxRemoteBlocklistSize))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return b.sanitize(model.BlocklistServers, entries), nil
+}
+
+// Block persists entries of the given kind in the blocklist
+// and removes affected rooms from the catalog and the search index in the background
+func (b *Blocklist) Block(ctx context.Context, kind string, entries []string) error {
+	span := utils.StartSpan(ctx, "blocklist.Block")
+	defer span.Finish()
+
+	entries = b.sanitize(kind, entries)
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := b.data.AddToBlocklist(span.Context(), kind, entries); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	target := b.target(kind)
+	for _, entry := range entries {
+		target[entry] = struct{}{}
+	}
+	b.mu.Unlock()
+
+	go b.purge(context.WithoutCancel(span.Context()), kind, entries)
+	return nil
+}
+
+// Unblock removes entries of the given kind from the blocklist.
+// Affected rooms will be available again after the next parsing and indexing
+func (b *Blocklist) Unblock(ctx context.Context, kind string, entries []string) error {
+	span := utils.StartSpan(ctx, "blocklist.Unblock")
+	defer span.Finish()
+
+	entries = b.sanitize(kind, entries)
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := b.data.RemoveFromBlocklist(span.Context(), kind, entries); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	target := b.target(kind)
+	for _, entry := range entries {
+		delete(target, entry)
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+// ByID checks if matrixID itself or its server is present in the blocklist
 func (b *Blocklist) ByID(matrixID string) bool {
+	b.mu.RLock()
+	_, ok := b.rooms[matrixID]
+	b.mu.RUnlock()
+	if ok {
+		return true
+	}
+
 	idx := strings.LastIndex(matrixID, ":")
 	if idx == -1 {
 		return false
@@ -66,11 +287,102 @@ func (b *Blocklist) ByID(matrixID string) bool {
 
 // ByServer checks if server is present in the blocklist
 func (b *Blocklist) ByServer(server string) bool {
+	server = utils.NormalizeServer(server)
 	if slices.Contains(b.cfg.Get().Blocklist.Servers, server) {
 		return true
 	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
 	if _, ok := b.dynamic[server]; ok {
 		return true
 	}
+	if _, ok := b.servers[server]; ok {
+		return true
+	}
+	if _, ok := b.remote[server]; ok {
+		return true
+	}
 	return false
 }
+
+// target returns in-memory set of the persisted entries of the given kind, must be called under lock
+func (b *Blocklist) target(kind string) map[string]struct{} {
+	if kind == model.BlocklistRooms {
+		return b.rooms
+	}
+	return b.servers
+}
+
+// sanitize trims (and normalizes server names) entries and removes empty ones and duplicates
+func (b *Blocklist) sanitize(kind string, entries []string) []string {
+	sanitized := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if kind == model.BlocklistServers {
+			entry = utils.NormalizeServer(entry)
+		}
+		if entry == "" {
+			continue
+		}
+		sanitized = append(sanitized, entry)
+	}
+	return utils.Uniq(sanitized)
+}
+
+// purge removes rooms matching the blocked entries from the catalog and the search index
+func (b *Blocklist) purge(ctx context.Context, kind string, entries []string) {
+	span := utils.StartSpan(ctx, "blocklist.purge")
+	defer span.Finish()
+	log := zerolog.Ctx(span.Context())
+
+	// matching rooms are removed in batches during the iteration, to keep memory bounded
+	var removed int
+	toRemove := make([]string, 0, removeRoomsBatch)
+	b.data.EachRoom(span.Context(), func(id string, room *model.MatrixRoom) bool {
+		var match bool
+		if kind == model.BlocklistRooms {
+			match = slices.Contains(entries, room.ID) || slices.Contains(entries, room.Alias)
+		} else { // rooms published by the server, and rooms of the server published elsewhere, same as ByID
+			match = slices.Contains(entries, room.Server) ||
+				slices.Contains(entries, utils.NormalizeServer(utils.ServerFrom(room.ID))) ||
+				slices.Contains(entries, utils.NormalizeServer(utils.ServerFrom(room.Alias)))
+		}
+		if !match {
+			return false
+		}
+		toRemove = append(toRemove, id)
+		if len(toRemove) >= removeRoomsBatch {
+			b.remove(span.Context(), toRemove)
+			removed += len(toRemove)
+			toRemove = toRemove[:0]
+		}
+		return false
+	})
+	b.remove(span.Context(), toRemove)
+	removed += len(toRemove)
+	if removed > 0 {
+		log.Info().Str("kind", kind).Int("rooms", removed).Msg("blocked rooms have been removed")
+	}
+}
+
+// remove removes the rooms from the catalog and the search index
+func (b *Blocklist) remove(ctx context.Context, toRemove []string) {
+	if len(toRemove) == 0 {
+		return
+	}
+	log := zerolog.Ctx(ctx)
+	b.data.RemoveRooms(ctx, toRemove)
+	if err := b.index.DeleteBatch(toRemove); err != nil {
+		log.Warn().Err(err).Msg("cannot remove blocked rooms from the index")
+	}
+	b.mu.RLock()
+	onRemove := b.onRemove
+	b.mu.RUnlock()
+	if onRemove != nil {
+		onRemove()
+	}
+	if err := b.data.RemoveRoomsHashes(ctx, toRemove); err != nil {
+		log.Warn().Err(err).Msg("cannot remove hashes of blocked rooms")
+	}
+}
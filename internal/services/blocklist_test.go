@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/etkecc/mrs/internal/model"
+)
+
+// fakeBlocklistData is an in-memory blocklist repository with a rooms catalog
+type fakeBlocklistData struct {
+	mu      sync.Mutex
+	lists   map[string]map[string]struct{}
+	rooms   map[string]*model.MatrixRoom
+	removed []string
+}
+
+func newFakeBlocklistData(rooms ...*model.MatrixRoom) *fakeBlocklistData {
+	d := &fakeBlocklistData{lists: map[string]map[string]struct{}{}, rooms: map[string]*model.MatrixRoom{}}
+	for _, room := range rooms {
+		d.rooms[room.ID] = room
+	}
+	return d
+}
+
+func (d *fakeBlocklistData) GetBlocklist(_ context.Context, kind string) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list := []string{}
+	for entry := range d.lists[kind] {
+		list = append(list, entry)
+	}
+	return list, nil
+}
+
+func (d *fakeBlocklistData) AddToBlocklist(_ context.Context, kind string, entries []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lists[kind] == nil {
+		d.lists[kind] = map[string]struct{}{}
+	}
+	for _, entry := range entries {
+		d.lists[kind][entry] = struct{}{}
+	}
+	return nil
+}
+
+func (d *fakeBlocklistData) RemoveFromBlocklist(_ context.Context, kind string, entries []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, entry := range entries {
+		delete(d.lists[kind], entry)
+	}
+	return nil
+}
+
+func (d *fakeBlocklistData) EachRoom(_ context.Context, handler func(string, *model.MatrixRoom) bool) {
+	d.mu.Lock()
+	rooms := make([]*model.MatrixRoom, 0, len(d.rooms))
+	for _, room := range d.rooms {
+		rooms = append(rooms, room)
+	}
+	d.mu.Unlock()
+	for _, room := range rooms {
+		if handler(room.ID, room) {
+			return
+		}
+	}
+}
+
+func (d *fakeBlocklistData) RemoveRooms(_ context.Context, keys []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, key := range keys {
+		delete(d.rooms, key)
+		d.removed = append(d.removed, key)
+	}
+}
+
+func (d *fakeBlocklistData) RemoveRoomsHashes(context.Context, []string) error { return nil }
+
+func (d *fakeBlocklistData) removedRooms() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string{}, d.removed...)
+}
+
+type nopBlocklistIndex struct{}
+
+func (nopBlocklistIndex) DeleteBatch([]string) error { return nil }
+
+func newTestBlocklist(data BlocklistRepository, blocklistCfg *model.ConfigBlocklist) *Blocklist {
+	if blocklistCfg == nil {
+		blocklistCfg = &model.ConfigBlocklist{}
+	}
+	return NewBlocklist(&fakeConfig{cfg: &model.Config{Blocklist: blocklistCfg}}, data, nopBlocklistIndex{})
+}
+
+// waitFor polls the condition until it's true or the timeout is reached
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition has not been met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBlocklist_BlockSurvivesReset(t *testing.T) {
+	data := newFakeBlocklistData(
+		&model.MatrixRoom{ID: "!a:bad.com", Server: "bad.com"},
+		&model.MatrixRoom{ID: "!b:good.com", Server: "good.com"},
+		&model.MatrixRoom{ID: "!c:Bad.com", Server: "good.com"},                       // room of the blocked server, published elsewhere
+		&model.MatrixRoom{ID: "!d:good.com", Alias: "#d:bad.com", Server: "good.com"}, // alias of the blocked server
+	)
+	b := newTestBlocklist(data, nil)
+	var purges atomic.Int32
+	b.SetOnRemove(func() { purges.Add(1) })
+	b.Add("unreachable.com")
+	if err := b.Block(context.Background(), model.BlocklistServers, []string{"bad.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	b.Reset()
+	if !b.ByServer("bad.com") {
+		t.Error("persisted blocked server has been reset")
+	}
+	if b.ByServer("unreachable.com") {
+		t.Error("dynamic server has not been reset")
+	}
+	if list := b.List(model.BlocklistServers); len(list) != 1 || list[0] != "bad.com" {
+		t.Errorf("persisted servers: got %v", list)
+	}
+
+	// rooms are purged in the background
+	waitFor(t, func() bool { return len(data.removedRooms()) > 0 })
+	removed := data.removedRooms()
+	slices.Sort(removed)
+	if expected := []string{"!a:bad.com", "!c:Bad.com", "!d:good.com"}; !slices.Equal(removed, expected) {
+		t.Errorf("removed rooms: got %v, want %v", removed, expected)
+	}
+	waitFor(t, func() bool { return purges.Load() == 1 })
+
+	// persisted servers are loaded on start
+	if restarted := newTestBlocklist(data, nil); !restarted.ByServer("bad.com") {
+		t.Error("persisted blocked server is not loaded")
+	}
+}
+
+func TestBlocklist_RemotePersisted(t *testing.T) {
+	var mu sync.Mutex
+	list := "# comment\nbad.com\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write([]byte(list)) //nolint:errcheck // test server
+	}))
+	defer srv.Close()
+
+	data := newFakeBlocklistData(&model.MatrixRoom{ID: "!a:bad.com", Server: "bad.com"})
+	cfg := &model.ConfigBlocklist{URL: srv.URL}
+	b := newTestBlocklist(data, cfg)
+	waitFor(t, func() bool { return b.ByServer("bad.com") })
+	waitFor(t, func() bool { return len(data.removedRooms()) == 1 })
+
+	// after restart, the persisted remote list is used right away, and its servers are not purged again
+	data.mu.Lock()
+	data.removed = nil
+	data.rooms["!b:bad.com"] = &model.MatrixRoom{ID: "!b:bad.com", Server: "bad.com"}
+	data.mu.Unlock()
+	restarted := newTestBlocklist(data, cfg)
+	if !restarted.ByServer("bad.com") {
+		t.Error("persisted remote blocklist is not loaded on start")
+	}
+	restarted.Refresh(context.Background())
+	if removed := data.removedRooms(); len(removed) != 0 {
+		t.Errorf("rooms of the already blocked servers were purged again: %v", removed)
+	}
+
+	// servers removed from the remote list are removed from the persisted one
+	mu.Lock()
+	list = "other.com\n"
+	mu.Unlock()
+	restarted.Refresh(context.Background())
+	if stored, _ := data.GetBlocklist(context.Background(), model.BlocklistRemote); len(stored) != 1 || stored[0] != "other.com" { //nolint:errcheck // fake
+		t.Errorf("persisted remote blocklist: got %v", stored)
+	}
+	if restarted.ByServer("bad.com") {
+		t.Error("server removed from the remote blocklist is still blocked")
+	}
+}
@@ -1,12 +1,19 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/goccy/go-json"
 	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
 
 	"github.com/etkecc/mrs/internal/model"
+	"github.com/etkecc/mrs/internal/utils"
 )
 
 // MaxCacheAge to be used on immutable resources
@@ -22,6 +29,9 @@ var noncacheablePaths = map[string]struct{}{
 	"/_matrix/federation/v1/query/directory": {},
 }
 
+// privatePrefixes are prefixes of the authenticated (admin and moderation) endpoints, their responses must not be stored by CDNs
+var privatePrefixes = []string{"/-/", "/mod/"}
+
 // Cache service
 type Cache struct {
 	cfg   ConfigService
@@ -47,6 +57,19 @@ func (cache *Cache) getLastModified() string {
 	return cache.stats.Get().Indexing.FinishedAt.Format(http.TimeFormat)
 }
 
+// getCacheControl returns Cache-Control header value of the mutable resources
+func (cache *Cache) getCacheControl(maxAge int) string {
+	cfg := cache.cfg.Get().Cache
+	value := "max-age=" + strconv.Itoa(maxAge) + ", public"
+	if cfg.StaleWhileRevalidate > 0 {
+		value += ", stale-while-revalidate=" + strconv.Itoa(cfg.StaleWhileRevalidate)
+	}
+	if cfg.StaleIfError > 0 {
+		value += ", stale-if-error=" + strconv.Itoa(cfg.StaleIfError)
+	}
+	return value
+}
+
 // Middleware returns cache middleware
 func (cache *Cache) Middleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -55,6 +78,12 @@ func (cache *Cache) Middleware() echo.MiddlewareFunc {
 				return next(c)
 			}
 
+			if isPrivatePath(c.Request().URL.Path) {
+				cache.clearHeaders(c)
+				c.Response().Header().Set("Cache-Control", "no-store")
+				return next(c)
+			}
+
 			_, noncacheable := noncacheablePaths[c.Request().URL.Path]
 			if noncacheable {
 				cache.clearHeaders(c)
@@ -68,8 +97,7 @@ func (cache *Cache) Middleware() echo.MiddlewareFunc {
 				return c.NoContent(http.StatusNotModified)
 			}
 
-			maxAge := strconv.Itoa(cache.cfg.Get().Cache.MaxAge)
-			c.Response().Header().Set("Cache-Control", "max-age="+maxAge+", public")
+			c.Response().Header().Set("Cache-Control", cache.getCacheControl(cache.cfg.Get().Cache.MaxAge))
 			c.Response().Header().Set("CDN-Tag", "mutable")
 			c.Response().Header().Set("Last-Modified", lastModified)
 			return next(c)
@@ -77,6 +105,16 @@ func (cache *Cache) Middleware() echo.MiddlewareFunc {
 	}
 }
 
+// isPrivatePath returns true if the path belongs to the authenticated endpoints, see privatePrefixes
+func isPrivatePath(path string) bool {
+	for _, prefix := range privatePrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // MiddlewareSearch returns cache middleware for search endpoints
 func (cache *Cache) MiddlewareSearch() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -97,8 +135,7 @@ func (cache *Cache) MiddlewareSearch() echo.MiddlewareFunc {
 				return c.NoContent(http.StatusNotModified)
 			}
 
-			maxAge := strconv.Itoa(cache.cfg.Get().Cache.MaxAgeSearch)
-			c.Response().Header().Set("Cache-Control", "max-age="+maxAge+", public")
+			c.Response().Header().Set("Cache-Control", cache.getCacheControl(cache.cfg.Get().Cache.MaxAgeSearch))
 			c.Response().Header().Set("CDN-Tag", "mutable")
 			c.Response().Header().Set("Last-Modified", lastModified)
 			return next(c)
@@ -125,3 +162,55 @@ func (cache *Cache) MiddlewareImmutable() echo.MiddlewareFunc {
 		}
 	}
 }
+
+// Purge cache of the configured CDN backends, returns backend => status map
+func (cache *Cache) Purge(ctx context.Context) map[string]string {
+	span := utils.StartSpan(ctx, "cache.Purge")
+	defer span.Finish()
+
+	result := map[string]string{}
+	cfg := cache.cfg.Get().Cache
+	if cfg.Bunny.URL != "" && cfg.Bunny.Key != "" {
+		result["bunny"] = cache.purge(span.Context(), "bunny", cfg.Bunny.URL, map[string]string{"AccessKey": cfg.Bunny.Key}, map[string]string{"CacheTag": "mutable"})
+	}
+	if cfg.Cloudflare.Zone != "" && cfg.Cloudflare.Token != "" {
+		purgeURL := "https://api.cloudflare.com/client/v4/zones/" + cfg.Cloudflare.Zone + "/purge_cache"
+		result["cloudflare"] = cache.purge(span.Context(), "cloudflare", purgeURL, map[string]string{"Authorization": "Bearer " + cfg.Cloudflare.Token}, map[string]bool{"purge_everything": true})
+	}
+
+	return result
+}
+
+// purge sends purge request to the CDN backend, returns status
+func (cache *Cache) purge(ctx context.Context, backend, purgeURL string, headers map[string]string, payload any) string {
+	log := zerolog.Ctx(ctx).With().Str("backend", backend).Logger()
+	payloadb, err := json.Marshal(payload)
+	if err != nil {
+		log.Error().Err(err).Msg("cannot marshal cache purge payload")
+		return err.Error()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, purgeURL, bytes.NewReader(payloadb))
+	if err != nil {
+		log.Error().Err(err).Msg("cannot create cache purge request")
+		return err.Error()
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := utils.Do(req)
+	if err != nil {
+		log.Error().Err(err).Msg("cannot purge cache")
+		return err.Error()
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(resp.Body) //nolint:errcheck // intended
+		log.Error().Int("status_code", resp.StatusCode).Str("body", string(body)).Msg("cannot purge cache")
+		return resp.Status
+	}
+
+	log.Info().Msg("cache has been purged")
+	return resp.Status
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/etkecc/mrs/internal/model"
+)
+
+func TestCache_Middleware(t *testing.T) {
+	indexedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	stats := &fakeStats{stats: &model.IndexStats{Indexing: model.IndexStatsTime{FinishedAt: indexedAt}}}
+	cache := NewCache(&fakeConfig{cfg: &model.Config{Cache: &model.ConfigCache{MaxAge: 60}}}, stats)
+
+	tests := []struct {
+		path         string
+		status       int
+		cacheControl string
+	}{
+		{"/stats", http.StatusNotModified, ""},
+		{"/-/status", http.StatusOK, "no-store"},
+		{"/-/index/snapshot", http.StatusOK, "no-store"},
+		{"/mod/list", http.StatusOK, "no-store"},
+		{"/_health", http.StatusOK, "no-cache"},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.path, http.NoBody)
+			req.Header.Set("If-Modified-Since", indexedAt.Format(http.TimeFormat))
+			rec := httptest.NewRecorder()
+			handler := cache.Middleware()(func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+			if err := handler(echo.New().NewContext(req, rec)); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != test.status {
+				t.Errorf("status: got %d, want %d", rec.Code, test.status)
+			}
+			if cacheControl := rec.Header().Get("Cache-Control"); cacheControl != test.cacheControl {
+				t.Errorf("Cache-Control: got %q, want %q", cacheControl, test.cacheControl)
+			}
+			if test.cacheControl == "no-store" && rec.Header().Get("CDN-Tag") != "" {
+				t.Error("private response has CDN-Tag")
+			}
+		})
+	}
+}
@@ -26,21 +26,24 @@ type ConfigService interface {
 	Get() *model.Config
 }
 
-// NewConfig creates new config service and loads the config
+// NewConfig creates new config service and loads the config,
+// returns error if the config cannot be read or is invalid
 func NewConfig(path string) (*Config, error) {
 	ctx := utils.NewContext()
 	c := &Config{
 		mu:   &sync.Mutex{},
 		path: path,
 	}
-	c.Read(ctx)
+	if err := c.Read(ctx); err != nil {
+		return nil, err
+	}
 
 	var err error
 	c.fsw, err = fswatcher.New([]string{path}, 0)
 	if err != nil {
 		return nil, err
 	}
-	go c.fsw.Start(func(_ fsnotify.Event) { c.Read(ctx) })
+	go c.fsw.Start(func(_ fsnotify.Event) { c.Read(ctx) }) //nolint:errcheck // logged, the previous config is kept
 
 	return c, nil
 }
@@ -50,8 +53,8 @@ func (c *Config) Get() *model.Config {
 	return c.cfg
 }
 
-// Read config
-func (c *Config) Read(ctx context.Context) {
+// Read config, invalid config is rejected and the previous one is kept
+func (c *Config) Read(ctx context.Context) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	log := zerolog.Ctx(ctx)
@@ -60,16 +63,22 @@ func (c *Config) Read(ctx context.Context) {
 	configb, err := os.ReadFile(c.path)
 	if err != nil {
 		log.Error().Err(err).Msg("cannot read config")
-		return
+		return err
 	}
 	var config *model.Config
 	err = yaml.Unmarshal(configb, &config)
 	if err != nil {
 		log.Error().Err(err).Msg("cannot unmarshal config")
-		return
+		return err
+	}
+	if err := config.Validate(); err != nil {
+		log.Error().Err(err).Msg("invalid config")
+		return err
 	}
+	config.InitOptional()
 
 	c.cfg = config
+	return nil
 }
 
 // Write config
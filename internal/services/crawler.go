@@ -2,31 +2,130 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/etkecc/go-kit/workpool"
 	"github.com/etkecc/go-msc1929"
 	"github.com/pemistahl/lingua-go"
 	"github.com/rs/zerolog"
+	"golang.org/x/exp/slices"
 
+	"github.com/etkecc/mrs/internal/metrics"
 	"github.com/etkecc/mrs/internal/model"
 	"github.com/etkecc/mrs/internal/utils"
 )
 
+// removeRoomsBatch is the max number of room IDs accumulated during iteration over rooms before they are removed
+const removeRoomsBatch = 1000
+
+const (
+	// maxRoomTags is the max number of tags per room
+	maxRoomTags = 20
+	// maxRoomTagLength is the max length of a single tag, in characters
+	maxRoomTagLength = 64
+)
+
 type Crawler struct {
+	mu          *sync.RWMutex
+	seeds       []string
 	v           ValidatorService
 	cfg         ConfigService
 	parsing     bool
 	discovering bool
 	eachrooming bool
+	pause       pauseGate
 	fed         FederationService
 	block       BlocklistService
 	data        DataRepository
 	detector    lingua.LanguageDetector
 }
 
+// roomsServers collects servers advertising the rooms in their room directories during parsing, safe for concurrent use
+type roomsServers struct {
+	mu    sync.Mutex
+	rooms map[string][]string
+}
+
+func (rs *roomsServers) add(roomID, server string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.rooms[roomID] = append(rs.rooms[roomID], server)
+}
+
+// sorted returns collected room ID => unique sorted servers, must be called after parsing is finished
+func (rs *roomsServers) sorted() map[string][]string {
+	for roomID, servers := range rs.rooms {
+		servers = utils.Uniq(servers)
+		sort.Strings(servers)
+		rs.rooms[roomID] = servers
+	}
+	return rs.rooms
+}
+
+// pauseGate blocks dispatch of new work while paused, safe for concurrent use
+type pauseGate struct {
+	mu       sync.Mutex
+	pausedAt time.Time
+	resumed  chan struct{} // closed on resume, nil if not paused
+}
+
+// pause returns false if already paused
+func (g *pauseGate) pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed != nil {
+		return false
+	}
+	g.pausedAt = time.Now().UTC()
+	g.resumed = make(chan struct{})
+	return true
+}
+
+// resume returns false if not paused
+func (g *pauseGate) resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed == nil {
+		return false
+	}
+	close(g.resumed)
+	g.resumed = nil
+	g.pausedAt = time.Time{}
+	return true
+}
+
+// wait blocks until resumed (or the context is done), returns immediately if not paused
+func (g *pauseGate) wait(ctx context.Context) {
+	g.mu.Lock()
+	resumed := g.resumed
+	g.mu.Unlock()
+	if resumed == nil {
+		return
+	}
+	select {
+	case <-resumed:
+	case <-ctx.Done():
+	}
+}
+
+func (g *pauseGate) state() *model.PauseState {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	state := &model.PauseState{Paused: g.resumed != nil}
+	if state.Paused {
+		pausedAt := g.pausedAt
+		state.PausedAt = &pausedAt
+	}
+	return state
+}
+
 type BlocklistService interface {
 	Add(server string)
 	ByID(matrixID string) bool
@@ -49,41 +148,60 @@ type DataRepository interface {
 	MarkServersOffline(context.Context, []string)
 	RemoveServer(context.Context, string) error
 	RemoveServers(context.Context, []string)
+	PurgeServer(context.Context, string) (bool, []string, error)
+	RefreshServers()
+	GetSeedServers(context.Context) ([]string, error)
+	SetSeedServers(context.Context, []string) error
 	AddRoomBatch(context.Context, *model.MatrixRoom)
-	FlushRoomBatch(context.Context)
+	FlushRoomBatch(context.Context) error
+	AddRoomsServers(context.Context, map[string][]string) error
+	GetRoomServers(context.Context, string) ([]string, error)
+	AddSpacesChildren(context.Context, map[string][]string) error
+	GetSpaceChildren(context.Context, string) ([]string, error)
+	SetRoomTags(context.Context, string, []string) error
+	GetRoomTags(context.Context, string) ([]string, error)
+	GetRoomsTags(context.Context) (map[string][]string, error)
 	GetRoom(context.Context, string) (*model.MatrixRoom, error)
 	EachRoom(context.Context, func(string, *model.MatrixRoom) bool)
+	GetRoomsHashes(context.Context) (map[string]uint64, error)
+	SetRoomsHashes(context.Context, map[string]uint64) error
 	SetBiggestRooms(context.Context, []string) error
 	SetServersRoomsCount(ctx context.Context, data map[string]int) error
 	SaveServersRooms(ctx context.Context, data map[string][]string) error
+	RebuildServersRooms(ctx context.Context) (*model.CatalogRebuild, error)
 	GetServersRoomsCount(ctx context.Context) map[string]int
 	GetBannedRooms(context.Context, ...string) ([]string, error)
 	RemoveRooms(context.Context, []string)
 	BanRoom(context.Context, string) error
 	UnbanRoom(context.Context, string) error
-	GetReportedRooms(context.Context, ...string) (map[string]string, error)
-	ReportRoom(context.Context, string, string) error
+	GetReportedRooms(context.Context, ...string) (map[string]*model.RoomReport, error)
+	ReportRoom(context.Context, string, *model.RoomReport) error
 	UnreportRoom(context.Context, string) error
 	IsReported(context.Context, string) bool
 }
 
 type ValidatorService interface {
 	Domain(server string) bool
-	IsOnline(ctx context.Context, server string) (string, bool)
-	IsIndexable(ctx context.Context, server string) bool
+	IsOnline(ctx context.Context, server string) (string, error)
+	IsIndexable(ctx context.Context, server string) error
+	IsRoomBlocked(server string, room *model.MatrixRoom) bool
 	IsRoomAllowed(ctx context.Context, server string, room *model.MatrixRoom) bool
 }
 
 type FederationService interface {
 	QueryPublicRooms(ctx context.Context, serverName, limit, since string) (*model.RoomDirectoryResponse, error)
+	StreamPublicRooms(ctx context.Context, serverName, limit, since string, handler func(*model.RoomDirectoryRoom)) (*model.RoomDirectoryResponse, error)
+	QueryHierarchy(ctx context.Context, serverName, roomID string) (*model.RoomHierarchyResponse, error)
 	QueryServerName(ctx context.Context, serverName string) (string, error)
 	QueryVersion(ctx context.Context, serverName string) (string, string, error)
 	QueryCSURL(ctx context.Context, serverName string) string
+	QueryOptOut(ctx context.Context, serverName string) bool
 }
 
 // NewCrawler service
 func NewCrawler(cfg ConfigService, fedSvc FederationService, v ValidatorService, block BlocklistService, data DataRepository, detector lingua.LanguageDetector) *Crawler {
-	return &Crawler{
+	m := &Crawler{
+		mu:       &sync.RWMutex{},
 		v:        v,
 		cfg:      cfg,
 		fed:      fedSvc,
@@ -91,20 +209,104 @@ func NewCrawler(cfg ConfigService, fedSvc FederationService, v ValidatorService,
 		data:     data,
 		detector: detector,
 	}
+	m.loadSeedServers(utils.NewContext())
+
+	return m
+}
+
+// loadSeedServers loads seed servers list persisted at runtime
+func (m *Crawler) loadSeedServers(ctx context.Context) {
+	seeds, err := m.data.GetSeedServers(ctx)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("cannot load seed servers")
+		return
+	}
+	m.mu.Lock()
+	m.seeds = seeds
+	m.mu.Unlock()
 }
 
-// DiscoverServers across federation and remove invalid ones
-func (m *Crawler) DiscoverServers(ctx context.Context, workers int, overrideList ...*utils.List[string, string]) {
+// SeedServers returns the bootstrap list of servers: the one set at runtime, or the config one
+func (m *Crawler) SeedServers() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.seeds) > 0 {
+		return m.seeds
+	}
+	return m.cfg.Get().Servers
+}
+
+// SetSeedServers validates, dedupes and persists the bootstrap list of servers, intended for HTTP API.
+// The list is used on the next servers discovery, empty list resets it to the config one.
+// If any of the server names is invalid, nothing is changed and invalid entries are returned
+func (m *Crawler) SetSeedServers(ctx context.Context, servers []string) (seeds, invalid []string, err error) {
+	span := utils.StartSpan(ctx, "crawler.SetSeedServers")
+	defer span.Finish()
+
+	list := utils.NewList[string, string]()
+	for _, server := range servers {
+		server = utils.NormalizeServer(strings.TrimSpace(server))
+		if !utils.IsValidServerName(server) {
+			invalid = append(invalid, server)
+			continue
+		}
+		list.Add(server)
+	}
+	if len(invalid) > 0 {
+		return nil, invalid, nil
+	}
+
+	slice := list.Slice()
+	sort.Strings(slice)
+	if err := m.data.SetSeedServers(span.Context(), slice); err != nil {
+		return nil, nil, err
+	}
+	m.mu.Lock()
+	m.seeds = slice
+	m.mu.Unlock()
+
+	zerolog.Ctx(span.Context()).Info().Int("servers", len(slice)).Msg("seed servers updated")
+	return m.SeedServers(), nil, nil
+}
+
+// Pause dispatch of new servers by discovery and parsing, in-flight servers are processed as usual.
+// Running jobs continue from where they paused on Resume, intended for HTTP API
+func (m *Crawler) Pause(ctx context.Context) *model.PauseState {
+	if m.pause.pause() {
+		zerolog.Ctx(ctx).Info().Msg("data pipeline has been paused")
+	}
+	return m.pause.state()
+}
+
+// Resume dispatch of new servers by discovery and parsing, intended for HTTP API
+func (m *Crawler) Resume(ctx context.Context) *model.PauseState {
+	if m.pause.resume() {
+		zerolog.Ctx(ctx).Info().Msg("data pipeline has been resumed")
+	}
+	return m.pause.state()
+}
+
+// PauseState returns pause state of the data pipeline, intended for HTTP API
+func (m *Crawler) PauseState() *model.PauseState {
+	return m.pause.state()
+}
+
+// DiscoverServers across federation and remove invalid ones,
+// returns summary of the run, or nil if discovery is already in progress
+func (m *Crawler) DiscoverServers(ctx context.Context, workers int, overrideList ...*utils.List[string, string]) *model.RunSummary {
 	log := zerolog.Ctx(ctx)
 	if m.discovering {
 		log.Info().Msg("servers discovery already in progress, ignoring request")
-		return
+		return nil
 	}
 	span := utils.StartSpan(ctx, "crawler.DiscoverServers")
 	defer span.Finish()
 
 	m.discovering = true
 	defer func() { m.discovering = false }()
+	summary := model.NewRunSummary(model.RunDiscovery)
+	m.data.RefreshServers()
 
 	var servers *utils.List[string, string]
 	if len(overrideList) > 0 {
@@ -113,16 +315,21 @@ func (m *Crawler) DiscoverServers(ctx context.Context, workers int, overrideList
 		servers = m.loadServers(span.Context())
 	}
 
-	offline := m.discoverServers(span.Context(), servers, workers)
+	offline, result := m.discoverServers(span.Context(), servers, workers)
+	summary.Servers = result
 
 	log.Info().Int("offline", offline.Len()).Msg("marking offline servers")
 	m.data.MarkServersOffline(span.Context(), offline.Slice())
+	return summary.Finish()
 }
 
 // AddServers by name in bulk, intended for HTTP API
 func (m *Crawler) AddServers(ctx context.Context, names []string, workers int) {
 	span := utils.StartSpan(ctx, "crawler.AddServers")
-	servers := utils.NewListFromSlice(names)
+	servers := utils.NewList[string, string]()
+	for _, name := range names {
+		servers.Add(utils.NormalizeServer(name))
+	}
 	// exclude already known servers first
 	for _, server := range servers.Slice() {
 		if m.data.HasServer(span.Context(), server) {
@@ -137,6 +344,7 @@ func (m *Crawler) AddServers(ctx context.Context, names []string, workers int) {
 // returns http status code to send to the reporter
 func (m *Crawler) AddServer(ctx context.Context, name string) int {
 	span := utils.StartSpan(ctx, "crawler.AddServer")
+	name = utils.NormalizeServer(name)
 	if m.data.HasServer(span.Context(), name) {
 		return http.StatusAlreadyReported
 	}
@@ -149,12 +357,52 @@ func (m *Crawler) AddServer(ctx context.Context, name string) int {
 	return http.StatusCreated
 }
 
-// ParseRooms across all discovered servers
-func (m *Crawler) ParseRooms(ctx context.Context, workers int) {
+// dispatchOrder sorts the items (server names, room IDs) in place and returns them,
+// deterministic dispatch order, to make runs (and their logs) reproducible
+func dispatchOrder(items []string) []string {
+	sort.Strings(items)
+	return items
+}
+
+// orderServers sorts servers in the dispatch order of the parsing run: priority servers first (in the configured order),
+// followed by the rest ordered by the configured strategy, ties are kept in dispatchOrder
+func (m *Crawler) orderServers(ctx context.Context, servers []string) []string {
+	cfg := m.cfg.Get().Parsing
+	servers = dispatchOrder(servers)
+	if cfg.Order == model.ParsingOrderRooms {
+		roomsCount := m.data.GetServersRoomsCount(ctx)
+		sort.SliceStable(servers, func(i, j int) bool {
+			return roomsCount[servers[i]] > roomsCount[servers[j]]
+		})
+	}
+	if len(cfg.Priority) == 0 {
+		return servers
+	}
+
+	priority := make(map[string]int, len(cfg.Priority))
+	for idx, name := range cfg.Priority {
+		if _, ok := priority[name]; !ok {
+			priority[name] = idx
+		}
+	}
+	sort.SliceStable(servers, func(i, j int) bool {
+		pi, iok := priority[servers[i]]
+		pj, jok := priority[servers[j]]
+		if iok && jok {
+			return pi < pj
+		}
+		return iok && !jok
+	})
+	return servers
+}
+
+// ParseRooms across all discovered servers,
+// returns summary of the run, or nil if parsing is already in progress
+func (m *Crawler) ParseRooms(ctx context.Context, workers int) *model.RunSummary {
 	log := zerolog.Ctx(ctx)
 	if m.parsing {
 		log.Info().Msg("room parsing already in progress, ignoring request")
-		return
+		return nil
 	}
 
 	span := utils.StartSpan(ctx, "crawler.ParseRooms")
@@ -162,11 +410,13 @@ func (m *Crawler) ParseRooms(ctx context.Context, workers int) {
 
 	m.parsing = true
 	defer func() { m.parsing = false }()
+	summary := model.NewRunSummary(model.RunParsing)
+	m.data.RefreshServers()
 
 	servers := utils.NewList[string, string]()
 	servers.AddSlice(m.IndexableServers(span.Context()))
 	servers.RemoveSlice(m.block.Slice())
-	slice := servers.Slice()
+	slice := m.orderServers(span.Context(), servers.Slice())
 	total := len(slice)
 
 	if total < workers {
@@ -174,27 +424,67 @@ func (m *Crawler) ParseRooms(ctx context.Context, workers int) {
 	}
 	wp := workpool.New(workers)
 	discoveredServers := utils.NewList[string, string]()
+	completedServers := utils.NewList[string, string]()
+	advertised := &roomsServers{rooms: map[string][]string{}}
+	spaces := &roomsServers{rooms: map[string][]string{}}
+	rejected := &atomic.Int64{}
+	byLanguage := &atomic.Int64{}
+	started := time.Now().UTC()
 	log.Info().Int("servers", total).Int("workers", workers).Msg("parsing rooms")
 	for _, srvName := range slice {
 		name := srvName
 		wp.Do(func() {
-			serversFromRooms := m.getPublicRooms(span.Context(), name)
+			m.pause.wait(span.Context())
+			serversFromRooms, invalid, otherLanguage, complete := m.getPublicRooms(span.Context(), name, advertised, spaces)
 			discoveredServers.AddSlice(serversFromRooms.Slice())
+			rejected.Add(int64(invalid))
+			byLanguage.Add(int64(otherLanguage))
+			if complete {
+				completedServers.Add(name)
+			}
 		})
 	}
 
 	wp.Run()
-	m.data.FlushRoomBatch(span.Context())
+	if err := m.data.FlushRoomBatch(span.Context()); err != nil {
+		// rooms of the completed servers that were not stored would be considered delisted and removed
+		log.Error().Err(err).Msg("cannot store parsed rooms, delisted rooms will not be removed")
+		summary.AddError(err)
+		completedServers = utils.NewList[string, string]()
+	}
+	if err := m.data.AddRoomsServers(span.Context(), advertised.sorted()); err != nil {
+		log.Error().Err(err).Msg("cannot store servers advertising the rooms")
+		summary.AddError(err)
+	}
+	var spacesCount int
+	if len(spaces.rooms) > 0 {
+		spacesCount = m.parseSpaces(span.Context(), spaces.sorted(), workers)
+	}
 	discoveredServers.RemoveSlice(servers.Slice())
 	log.
 		Info().
 		Int("of", servers.Len()).
 		Int("discovered_servers", discoveredServers.Len()).
+		Int64("rejected_rooms", rejected.Load()).
+		Int64("dropped_by_language", byLanguage.Load()).
 		Msg("parsing rooms has been finished")
 
+	summary.Servers = &model.RunServers{
+		Total:      total,
+		Completed:  completedServers.Len(),
+		Discovered: discoveredServers.Len(),
+	}
+	summary.Rooms = &model.RunRooms{
+		Parsed:            len(advertised.rooms),
+		Rejected:          int(rejected.Load()),
+		DroppedByLanguage: int(byLanguage.Load()),
+		Spaces:            spacesCount,
+	}
+
 	m.DiscoverServers(span.Context(), m.cfg.Get().Workers.Discovery, discoveredServers)
 
-	m.afterRoomParsing(span.Context())
+	summary.Rooms.Removed = m.afterRoomParsing(span.Context(), started, completedServers)
+	return summary.Finish()
 }
 
 // EachRoom allows to work with each known room
@@ -207,10 +497,15 @@ func (m *Crawler) EachRoom(ctx context.Context, handler func(roomID string, data
 	m.eachrooming = true
 	defer func() { m.eachrooming = false }()
 
-	toRemove := []string{}
+	// not allowed rooms are removed in batches during the iteration, to keep memory bounded
+	toRemove := make([]string, 0, removeRoomsBatch)
 	m.data.EachRoom(ctx, func(id string, room *model.MatrixRoom) bool {
 		if !m.v.IsRoomAllowed(ctx, room.Server, room) {
 			toRemove = append(toRemove, id)
+			if len(toRemove) >= removeRoomsBatch {
+				m.data.RemoveRooms(ctx, toRemove)
+				toRemove = toRemove[:0]
+			}
 			return false
 		}
 
@@ -219,24 +514,248 @@ func (m *Crawler) EachRoom(ctx context.Context, handler func(roomID string, data
 	m.data.RemoveRooms(ctx, toRemove)
 }
 
-// OnlineServers returns all known online servers
-func (m *Crawler) OnlineServers(ctx context.Context) []string {
-	return utils.MapKeys(m.data.FilterServers(ctx, func(server *model.MatrixServer) bool {
-		return server.Online
-	}))
+// PurgeServer removes the server and all its rooms from the catalog, returns removed room IDs
+func (m *Crawler) PurgeServer(ctx context.Context, name string) (found bool, roomIDs []string, err error) {
+	span := utils.StartSpan(ctx, "crawler.PurgeServer")
+	defer span.Finish()
+
+	return m.data.PurgeServer(span.Context(), utils.NormalizeServer(name))
 }
 
-// IndexableServers returns all known indexable servers
+// InspectRoom returns stored room with its moderation and blocklist status
+func (m *Crawler) InspectRoom(ctx context.Context, roomID string) (*model.RoomInspection, error) {
+	span := utils.StartSpan(ctx, "crawler.InspectRoom")
+	defer span.Finish()
+
+	room, err := m.data.GetRoom(span.Context(), roomID)
+	if err != nil {
+		return nil, err
+	}
+	server := utils.ServerFrom(roomID)
+	banned, err := m.data.GetBannedRooms(span.Context(), server)
+	if err != nil {
+		return nil, err
+	}
+	reported, err := m.data.GetReportedRooms(span.Context(), server)
+	if err != nil {
+		return nil, err
+	}
+
+	servers, err := m.data.GetRoomServers(span.Context(), roomID)
+	if err != nil {
+		return nil, err
+	}
+	children, err := m.data.GetSpaceChildren(span.Context(), roomID)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := m.data.GetRoomTags(span.Context(), roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	inspection := &model.RoomInspection{
+		Room:     room,
+		Servers:  servers,
+		Children: children,
+		Blocked:  m.block.ByID(roomID),
+		Banned:   slices.Contains(banned, roomID),
+	}
+	if report, ok := reported[roomID]; ok {
+		inspection.Reported = true
+		inspection.ReportReason = report.Reason
+		inspection.ReportedBy = report.ReportedBy
+		inspection.ReportedAt = report.ReportedAt
+	}
+	if room != nil {
+		inspection.Entry = room.Entry()
+		inspection.Entry.Tags = tags
+		inspection.Blocked = inspection.Entry.IsBlocked(m.block)
+	}
+	return inspection, nil
+}
+
+// ListServers returns a page (sorted by name) of the known servers matching the filter
+func (m *Crawler) ListServers(ctx context.Context, filter model.ServersFilter, limit, offset int) *model.ServersList {
+	span := utils.StartSpan(ctx, "crawler.ListServers")
+	defer span.Finish()
+
+	servers := m.data.FilterServers(span.Context(), filter.Match)
+	names := utils.MapKeys(servers)
+	sort.Strings(names)
+
+	list := &model.ServersList{
+		Total:   len(names),
+		Servers: []*model.MatrixServer{},
+	}
+	if offset >= len(names) {
+		return list
+	}
+	names = names[offset:]
+	if len(names) > limit {
+		names = names[:limit]
+	}
+	for _, name := range names {
+		list.Servers = append(list.Servers, servers[name])
+	}
+	return list
+}
+
+// ServerInfo returns known server by name, or nil if it's unknown
+func (m *Crawler) ServerInfo(ctx context.Context, name string) (*model.MatrixServer, error) {
+	return m.data.GetServerInfo(ctx, utils.NormalizeServer(name))
+}
+
+// ServersReport returns servers that need attention
+func (m *Crawler) ServersReport(ctx context.Context) *model.ServersReport {
+	span := utils.StartSpan(ctx, "crawler.ServersReport")
+	defer span.Finish()
+
+	report := &model.ServersReport{
+		Offline:      []string{},
+		NotIndexable: []string{},
+		NoRooms:      []string{},
+	}
+	roomsCount := m.data.GetServersRoomsCount(span.Context())
+	servers := m.data.FilterServers(span.Context(), func(_ *model.MatrixServer) bool {
+		return true
+	})
+	for name, server := range servers {
+		switch {
+		case !server.Online:
+			report.Offline = append(report.Offline, name)
+		case !server.Indexable:
+			report.NotIndexable = append(report.NotIndexable, name)
+		case roomsCount[name] == 0:
+			report.NoRooms = append(report.NoRooms, name)
+		}
+	}
+	sort.Strings(report.Offline)
+	sort.Strings(report.NotIndexable)
+	sort.Strings(report.NoRooms)
+
+	return report
+}
+
+// IndexableServers returns all known indexable servers, except quarantined ones (if quarantine is enabled)
 func (m *Crawler) IndexableServers(ctx context.Context) []string {
+	quarantine := m.cfg.Get().Parsing.Quarantine.Enabled
 	return utils.MapKeys(m.data.FilterServers(ctx, func(server *model.MatrixServer) bool {
-		return server.Online && server.Indexable
+		return server.Online && server.Indexable && !server.Excluded && !(quarantine && server.Quarantined)
 	}))
 }
 
+// ApproveServer releases the server from quarantine, intended for HTTP API.
+// Its rooms will be parsed on the next parsing run
+func (m *Crawler) ApproveServer(ctx context.Context, name string) (*model.MatrixServer, error) {
+	span := utils.StartSpan(ctx, "crawler.ApproveServer")
+	defer span.Finish()
+
+	server, err := m.data.GetServerInfo(span.Context(), utils.NormalizeServer(name))
+	if err != nil || server == nil {
+		return nil, err
+	}
+
+	server.Quarantined = false
+	if server.Status == model.ServerStatusQuarantined {
+		server.Status = model.ServerStatusIndexable
+	}
+	if err := m.data.AddServer(span.Context(), server); err != nil {
+		return nil, err
+	}
+	zerolog.Ctx(span.Context()).Info().Str("server", server.Name).Msg("server approved")
+	return server, nil
+}
+
+// SetIndexable toggles indexing of the server by the instance operator, intended for HTTP API.
+// The server stays discovered, but its rooms are not parsed while it is excluded
+func (m *Crawler) SetIndexable(ctx context.Context, name string, indexable bool) (*model.MatrixServer, error) {
+	span := utils.StartSpan(ctx, "crawler.SetIndexable")
+	defer span.Finish()
+
+	server, err := m.data.GetServerInfo(span.Context(), utils.NormalizeServer(name))
+	if err != nil || server == nil {
+		return nil, err
+	}
+
+	server.Excluded = !indexable
+	server.Indexable = indexable && server.Online && m.v.IsIndexable(span.Context(), server.Name) == nil
+	if err := m.data.AddServer(span.Context(), server); err != nil {
+		return nil, err
+	}
+	zerolog.Ctx(span.Context()).Info().Str("server", server.Name).Bool("indexable", indexable).Msg("server indexing toggled")
+	return server, nil
+}
+
 func (m *Crawler) GetServersRoomsCount(ctx context.Context) map[string]int {
 	return m.data.GetServersRoomsCount(ctx)
 }
 
+// RebuildCatalog rebuilds servers rooms catalog (rooms grouped by servers and their counts) from the stored rooms,
+// to repair it after interrupted runs without crawling the federation
+func (m *Crawler) RebuildCatalog(ctx context.Context) (*model.CatalogRebuild, error) {
+	return m.data.RebuildServersRooms(ctx)
+}
+
+// SetRoomTags validates, normalizes (lowercase, deduped, sorted), and stores tags of the room, intended for HTTP API.
+// Tags are merged into the search index on the next ingest, empty list removes the tags.
+// If any of the tags is invalid, nothing is changed and invalid entries are returned.
+// Nil tags mean the room is not in the catalog
+func (m *Crawler) SetRoomTags(ctx context.Context, roomID string, tags []string) (stored, invalid []string, err error) {
+	span := utils.StartSpan(ctx, "crawler.SetRoomTags")
+	defer span.Finish()
+
+	room, err := m.data.GetRoom(span.Context(), roomID)
+	if err != nil || room == nil {
+		return nil, nil, err
+	}
+
+	list := utils.NewList[string, string]()
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		// tags are searched with the tag:value filter, so they can't contain the filter separators
+		if tag == "" || utf8.RuneCountInString(tag) > maxRoomTagLength || strings.ContainsAny(tag, ` :"`+"\t\n") {
+			invalid = append(invalid, tag)
+			continue
+		}
+		list.Add(tag)
+	}
+	if len(invalid) > 0 {
+		return nil, invalid, nil
+	}
+	if list.Len() > maxRoomTags {
+		return nil, []string{fmt.Sprintf("more than %d tags", maxRoomTags)}, nil
+	}
+
+	stored = list.Slice()
+	sort.Strings(stored)
+	if err := m.data.SetRoomTags(span.Context(), roomID, stored); err != nil {
+		return nil, nil, err
+	}
+	zerolog.Ctx(span.Context()).Info().Str("room", roomID).Strs("tags", stored).Msg("room tags updated")
+	return stored, nil, nil
+}
+
+// GetRoomsTags returns room ID => tags of all tagged rooms
+func (m *Crawler) GetRoomsTags(ctx context.Context) (map[string][]string, error) {
+	return m.data.GetRoomsTags(ctx)
+}
+
+// GetRoom returns the stored room
+func (m *Crawler) GetRoom(ctx context.Context, roomID string) (*model.MatrixRoom, error) {
+	return m.data.GetRoom(ctx, roomID)
+}
+
+// GetRoomsHashes returns room ID => content hash of the indexed rooms
+func (m *Crawler) GetRoomsHashes(ctx context.Context) (map[string]uint64, error) {
+	return m.data.GetRoomsHashes(ctx)
+}
+
+// SetRoomsHashes replaces content hashes of the indexed rooms
+func (m *Crawler) SetRoomsHashes(ctx context.Context, hashes map[string]uint64) error {
+	return m.data.SetRoomsHashes(ctx, hashes)
+}
+
 func (m *Crawler) loadServers(ctx context.Context) *utils.List[string, string] {
 	span := utils.StartSpan(ctx, "crawler.loadServers")
 	defer span.Finish()
@@ -244,11 +763,15 @@ func (m *Crawler) loadServers(ctx context.Context) *utils.List[string, string] {
 	log := zerolog.Ctx(span.Context())
 	log.Info().Msg("loading servers")
 	servers := utils.NewList[string, string]()
-	servers.AddSlice(m.cfg.Get().Servers)
-	log.Info().Int("servers", servers.Len()).Msg("loaded servers from config")
-	servers.AddSlice(utils.MapKeys(m.data.FilterServers(span.Context(), func(_ *model.MatrixServer) bool {
+	for _, name := range m.SeedServers() {
+		servers.Add(utils.NormalizeServer(name))
+	}
+	log.Info().Int("servers", servers.Len()).Msg("loaded seed servers")
+	for name := range m.data.FilterServers(span.Context(), func(_ *model.MatrixServer) bool {
 		return true
-	})))
+	}) {
+		servers.Add(utils.NormalizeServer(name))
+	}
 	log.Info().Int("servers", servers.Len()).Msg("loaded servers from config and db")
 
 	return servers
@@ -259,8 +782,25 @@ func (m *Crawler) discoverServer(ctx context.Context, name string) *model.Matrix
 	span := utils.StartSpan(ctx, "crawler.discoverServer")
 	defer span.Finish()
 
-	name, ok := m.v.IsOnline(span.Context(), name)
-	if name == "" {
+	name = utils.NormalizeServer(name)
+	if m.cfg.Get().IsSelf(name) {
+		zerolog.Ctx(span.Context()).Debug().Str("server", name).Msg("server is MRS itself, skipping")
+		return nil
+	}
+	if tld := utils.ServerTLD(name); !m.cfg.Get().Blocklist.TLDs.IsAllowed(tld) {
+		metrics.IncServersRejectedTLD(tld)
+		zerolog.Ctx(span.Context()).Debug().Str("server", name).Str("tld", tld).Msg("server TLD is not allowed, skipping")
+		return nil
+	}
+
+	resolved, onlineErr := m.v.IsOnline(span.Context(), name)
+	if resolved == "" {
+		m.markUnreachable(span.Context(), name, onlineErr)
+		return nil
+	}
+	name = utils.NormalizeServer(resolved)
+	if m.cfg.Get().IsSelf(name) { // delegated to MRS itself
+		zerolog.Ctx(span.Context()).Debug().Str("server", name).Msg("server is MRS itself, skipping")
 		return nil
 	}
 
@@ -268,12 +808,41 @@ func (m *Crawler) discoverServer(ctx context.Context, name string) *model.Matrix
 		Name:     name,
 		URL:      m.fed.QueryCSURL(span.Context(), name),
 		Contacts: m.getServerContacts(span.Context(), name),
-		Online:   ok,
+		Online:   onlineErr == nil,
 		OnlineAt: time.Now().UTC(),
 	}
 
-	if m.v.IsIndexable(span.Context(), name) {
-		server.Indexable = true
+	quarantine := m.cfg.Get().Parsing.Quarantine
+	if previous, err := m.data.GetServerInfo(span.Context(), name); err == nil && previous != nil {
+		server.Excluded = previous.Excluded
+		server.Quarantined = previous.Quarantined
+		server.DiscoveredAt = previous.DiscoveredAt
+	} else {
+		server.Quarantined = quarantine.Enabled
+		server.DiscoveredAt = server.OnlineAt
+	}
+	if server.Quarantined && quarantine.IsExpired(server.DiscoveredAt) {
+		server.Quarantined = false
+		zerolog.Ctx(span.Context()).Info().Str("server", name).Msg("server quarantine is over, approved automatically")
+	}
+
+	switch {
+	case onlineErr != nil:
+		server.Status = model.ServerStatusNotFederatable
+		server.LastError = onlineErr.Error()
+	case server.Excluded:
+		server.Status = model.ServerStatusExcluded
+	default:
+		if err := m.v.IsIndexable(span.Context(), name); err != nil {
+			server.Status = model.ServerStatusNotIndexable
+			server.LastError = err.Error()
+		} else {
+			server.Status = model.ServerStatusIndexable
+			server.Indexable = true
+		}
+		if server.Indexable && server.Quarantined && quarantine.Enabled {
+			server.Status = model.ServerStatusQuarantined
+		}
 	}
 
 	if err := m.data.AddServer(span.Context(), server); err != nil {
@@ -283,8 +852,28 @@ func (m *Crawler) discoverServer(ctx context.Context, name string) *model.Matrix
 	return server
 }
 
-// discoverServers parses servers information and returns lists of OFFLINE servers
-func (m *Crawler) discoverServers(ctx context.Context, servers *utils.List[string, string], workers int) (offline *utils.List[string, string]) {
+// markUnreachable records the reason of the failed discovery for the already known server,
+// unknown servers are not stored at all
+func (m *Crawler) markUnreachable(ctx context.Context, name string, reason error) {
+	server, err := m.data.GetServerInfo(ctx, name)
+	if err != nil || server == nil {
+		return
+	}
+
+	server.Online = false
+	server.Indexable = false
+	server.Status = model.ServerStatusUnreachable
+	server.LastError = ""
+	if reason != nil {
+		server.LastError = reason.Error()
+	}
+	if err := m.data.AddServer(ctx, server); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Str("server", name).Msg("cannot store server")
+	}
+}
+
+// discoverServers parses servers information and returns lists of OFFLINE servers and counts of the processed servers
+func (m *Crawler) discoverServers(ctx context.Context, servers *utils.List[string, string], workers int) (offline *utils.List[string, string], result *model.RunServers) {
 	wp := workpool.New(workers)
 	log := zerolog.Ctx(ctx)
 	online := utils.NewList[string, string]()
@@ -292,9 +881,10 @@ func (m *Crawler) discoverServers(ctx context.Context, servers *utils.List[strin
 	indexable := utils.NewList[string, string]() // just for stats
 	log.Info().Int("servers", servers.Len()).Int("workers", workers).Msg("validating servers")
 
-	for _, server := range servers.Slice() {
+	for _, server := range dispatchOrder(servers.Slice()) {
 		srvName := server
 		wp.Do(func() {
+			m.pause.wait(ctx)
 			server := m.discoverServer(ctx, srvName)
 			if server == nil {
 				return
@@ -317,10 +907,20 @@ func (m *Crawler) discoverServers(ctx context.Context, servers *utils.List[strin
 		Int("indexable", indexable.Len()).
 		Int("of", servers.Len()).
 		Msg("servers discovery finished")
-	return offline
+	return offline, &model.RunServers{
+		Total:     servers.Len(),
+		Online:    online.Len(),
+		Offline:   offline.Len(),
+		Indexable: indexable.Len(),
+	}
 }
 
-func (m *Crawler) afterRoomParsing(ctx context.Context) {
+// afterRoomParsing calculates rooms stats and removes stale rooms.
+// A room is considered stale if it was parsed more than a week ago,
+// or if room directories of all servers that published it were fully parsed during the current run, but the room wasn't listed there anymore
+// (e.g., the room was upgraded and the old one has been removed from the directory, or it was unpublished), see isDelisted.
+// Returns count of the removed rooms
+func (m *Crawler) afterRoomParsing(ctx context.Context, parsingStarted time.Time, completedServers *utils.List[string, string]) int {
 	type roomCount struct {
 		id      string
 		members int
@@ -335,10 +935,30 @@ func (m *Crawler) afterRoomParsing(ctx context.Context) {
 	log.Info().Msg("after room parsing......")
 	started := time.Now().UTC()
 	counts := []roomCount{}
-	toRemove := []string{}
+	// stale and delisted rooms are removed in batches during the iteration, to keep memory bounded
+	var removed int
+	toRemove := make([]string, 0, removeRoomsBatch)
+	remove := func(id string) {
+		toRemove = append(toRemove, id)
+		if len(toRemove) >= removeRoomsBatch {
+			m.data.RemoveRooms(span.Context(), toRemove)
+			removed += len(toRemove)
+			toRemove = toRemove[:0]
+		}
+	}
+	completed := make(map[string]struct{}, completedServers.Len())
+	for _, server := range completedServers.Slice() {
+		completed[server] = struct{}{}
+	}
+	var delisted int
 	m.data.EachRoom(span.Context(), func(id string, data *model.MatrixRoom) bool {
 		if started.Sub(data.ParsedAt) >= 24*7*time.Hour { // parsed more than a week ago
-			toRemove = append(toRemove, id)
+			remove(id)
+			return false
+		}
+		if data.ParsedAt.Before(parsingStarted) && m.isDelisted(span.Context(), id, completed) {
+			remove(id)
+			delisted++
 			return false
 		}
 
@@ -374,10 +994,35 @@ func (m *Crawler) afterRoomParsing(ctx context.Context) {
 	// 	log.Error().Err(err).Msg("cannot save servers rooms")
 	// }
 
-	if len(toRemove) > 0 {
-		log.Info().Int("rooms", len(toRemove)).Msg("removing rooms last updated more than a week ago...")
-		m.data.RemoveRooms(span.Context(), toRemove)
+	m.data.RemoveRooms(span.Context(), toRemove)
+	removed += len(toRemove)
+	if removed > 0 {
+		log.Info().Int("rooms", removed).Int("delisted", delisted).Msg("rooms last updated more than a week ago or delisted from their servers have been removed")
 	}
+	return removed
+}
+
+// isDelisted checks if the room, not listed during the current parsing run, was delisted by the servers that published it:
+// room directories of all of them were fully parsed. The room's own server (from the room ID) doesn't matter,
+// because rooms are often published by other servers. Rooms with unknown publishing servers are never considered delisted
+func (m *Crawler) isDelisted(ctx context.Context, roomID string, completed map[string]struct{}) bool {
+	if len(completed) == 0 {
+		return false
+	}
+	servers, err := m.data.GetRoomServers(ctx, roomID)
+	if err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Str("id", roomID).Msg("cannot get servers publishing the room")
+		return false
+	}
+	if len(servers) == 0 {
+		return false
+	}
+	for _, server := range servers {
+		if _, ok := completed[server]; !ok {
+			return false
+		}
+	}
+	return true
 }
 
 // getServerContacts as per MSC1929
@@ -414,53 +1059,202 @@ func (m *Crawler) getServerContacts(ctx context.Context, name string) model.Matr
 }
 
 // getPublicRooms reads public rooms of the given server from the matrix client-server api
-// and sends them into channel
-func (m *Crawler) getPublicRooms(ctx context.Context, name string) *utils.List[string, string] {
+// and sends them into channel, returns discovered servers, count of rooms rejected due to invalid format,
+// count of rooms dropped due to not allowed language and whether the whole room directory has been parsed
+func (m *Crawler) getPublicRooms(ctx context.Context, name string, advertised, spaces *roomsServers) (servers *utils.List[string, string], rejected, byLanguage int, complete bool) {
 	var since string
-	var added int
+	var added, dropped int
 	limit := "10000"
-	servers := utils.NewList[string, string]()
+	parsingCfg := m.cfg.Get().Parsing
+	minMembers := parsingCfg.MinMembers
+	maxRooms := parsingCfg.MaxRoomsPerServer
+	servers = utils.NewList[string, string]()
 	span := utils.StartSpan(ctx, "crawler.getPublicRooms")
 	defer span.Finish()
 	log := zerolog.Ctx(span.Context())
+	if m.cfg.Get().IsSelf(name) {
+		log.Warn().Str("server", name).Msg("server is MRS itself, skipping")
+		return servers, 0, 0, false
+	}
 
+	var attempt int
 	for {
+		var received int
 		start := time.Now()
-		resp, err := m.fed.QueryPublicRooms(span.Context(), name, limit, since)
-		if err != nil {
-			log.Warn().Err(err).Str("server", name).Msg("cannot query public rooms")
-			return servers
-		}
-		if len(resp.Chunk) == 0 {
-			log.Info().Str("server", name).Msg("no public rooms available")
-			return servers
-		}
-
-		added += len(resp.Chunk)
-		for _, rdRoom := range resp.Chunk {
+		// rooms are processed while the response is being read, to keep memory usage low on huge responses
+		resp, err := m.fed.StreamPublicRooms(span.Context(), name, limit, since, func(rdRoom *model.RoomDirectoryRoom) {
+			received++
+			if maxRooms > 0 && added >= maxRooms {
+				return
+			}
 			room := rdRoom.Convert()
+			if m.v.IsRoomBlocked(name, room) { // counted here only, rooms blocked later are removed by EachRoom without counting
+				metrics.RoomsBlocked.Inc()
+				return
+			}
 			if !m.v.IsRoomAllowed(span.Context(), name, room) {
-				added--
-				continue
+				return
+			}
+			if room.Members < minMembers {
+				dropped++
+				return
 			}
 
-			room.Parse(m.detector, m.cfg.Get().Public.API)
+			room.Parse(m.detector, m.cfg.Get().Public.API, m.cfg.Get().Parsing.Topic)
+			if !parsingCfg.IsLanguageAllowed(room.Language) {
+				byLanguage++
+				return
+			}
+			if err := room.Validate(); err != nil {
+				log.Debug().Err(err).Str("server", name).Str("id", room.ID).Msg("room rejected")
+				rejected++
+				return
+			}
 			servers.AddSlice(room.Servers(m.cfg.Get().Matrix.ServerName))
+			previous, err := m.data.GetRoom(span.Context(), room.ID)
+			if err != nil {
+				log.Debug().Err(err).Str("server", name).Str("id", room.ID).Msg("cannot get previous version of the room")
+			}
+			room.SetLastActive(previous)
+			room.SetFirstSeen(previous)
 
 			m.data.AddRoomBatch(span.Context(), room)
+			advertised.add(room.ID, name)
+			if parsingCfg.Spaces.Enabled && room.RoomType == model.RoomTypeSpace {
+				spaces.add(room.ID, name)
+			}
+			added++
+		})
+		if err != nil {
+			// whole server retry: only if nothing has been received yet, so the counters and batches are not affected
+			if since == "" && received == 0 && attempt < parsingCfg.Retries {
+				attempt++
+				delay := parsingCfg.GetRetryDelay()
+				log.Warn().Err(err).Str("server", name).Int("attempt", attempt).Int("of", parsingCfg.Retries).Str("delay", delay.String()).Msg("cannot query public rooms, retrying the server")
+				select {
+				case <-span.Context().Done():
+					return servers, rejected, byLanguage, false
+				case <-time.After(delay):
+				}
+				continue
+			}
+			log.Warn().Err(err).Str("server", name).Int("attempts", attempt+1).Msg("cannot query public rooms")
+			return servers, rejected, byLanguage, false
 		}
+		if received == 0 {
+			log.Info().Str("server", name).Msg("no public rooms available")
+			return servers, rejected, byLanguage, since != ""
+		}
+
 		log.
 			Info().
 			Str("server", name).
 			Int("added", added).
+			Int("rejected", rejected).
+			Int("dropped", dropped).
+			Int("dropped_by_language", byLanguage).
 			Int("of", resp.Total).
 			Str("took", time.Since(start).String()).
 			Msg("added rooms")
 
 		if resp.NextBatch == "" {
-			return servers
+			return servers, rejected, byLanguage, true
+		}
+		// the rest of the rooms is treated as delisted, so the server can't grow over the limit between runs
+		if maxRooms > 0 && added >= maxRooms {
+			log.Warn().Str("server", name).Int("max_rooms_per_server", maxRooms).Msg("server reached max rooms limit, stop parsing it")
+			return servers, rejected, byLanguage, true
 		}
 
 		since = resp.NextBatch
 	}
 }
+
+// parseSpaces fetches hierarchy of the spaces (space ID => servers advertising it) and stores their children,
+// returns number of the spaces with stored children
+func (m *Crawler) parseSpaces(ctx context.Context, spaces map[string][]string, workers int) int {
+	span := utils.StartSpan(ctx, "crawler.parseSpaces")
+	defer span.Finish()
+	log := zerolog.Ctx(span.Context())
+
+	ids := make([]string, 0, len(spaces))
+	for spaceID := range spaces {
+		ids = append(ids, spaceID)
+	}
+	ids = dispatchOrder(ids)
+
+	log.Info().Int("spaces", len(ids)).Msg("parsing spaces hierarchy")
+	var mu sync.Mutex
+	children := map[string][]string{}
+	wp := workpool.New(min(workers, len(ids)))
+	for _, id := range ids {
+		spaceID := id
+		wp.Do(func() {
+			m.pause.wait(span.Context())
+			spaceChildren := m.getSpaceChildren(span.Context(), spaces[spaceID][0], spaceID, spaces)
+			mu.Lock()
+			for childSpaceID, childIDs := range spaceChildren {
+				children[childSpaceID] = childIDs
+			}
+			mu.Unlock()
+		})
+	}
+	wp.Run()
+
+	if err := m.data.AddSpacesChildren(span.Context(), children); err != nil {
+		log.Error().Err(err).Msg("cannot store spaces children")
+		return 0
+	}
+	log.Info().Int("spaces", len(children)).Int("of", len(ids)).Msg("spaces hierarchy has been parsed")
+	return len(children)
+}
+
+// getSpaceChildren returns space ID => children room IDs of the space and its nested spaces,
+// nested spaces advertised in the room directories (listed) are not traversed, because they are parsed on their own
+func (m *Crawler) getSpaceChildren(ctx context.Context, serverName, spaceID string, listed map[string][]string) map[string][]string {
+	log := zerolog.Ctx(ctx)
+	spacesCfg := m.cfg.Get().Parsing.Spaces
+	maxDepth, maxChildren := spacesCfg.GetMaxDepth(), spacesCfg.GetMaxChildren()
+
+	type node struct {
+		id    string
+		depth int
+	}
+	result := map[string][]string{}
+	queue := []node{{id: spaceID}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if _, ok := result[current.id]; ok {
+			continue
+		}
+		hierarchy, err := m.fed.QueryHierarchy(ctx, serverName, current.id)
+		if err != nil {
+			log.Warn().Err(err).Str("server", serverName).Str("space", current.id).Msg("cannot query space hierarchy")
+			continue
+		}
+		nested := map[string]bool{}
+		for _, child := range hierarchy.Children {
+			if child != nil && child.RoomType == model.RoomTypeSpace {
+				nested[child.ID] = true
+			}
+		}
+
+		childIDs := []string{}
+		for _, childID := range hierarchy.Room.ChildrenIDs() {
+			if len(childIDs) >= maxChildren {
+				log.Warn().Str("server", serverName).Str("space", current.id).Int("max_children", maxChildren).Msg("space reached max children limit")
+				break
+			}
+			if m.block.ByID(childID) {
+				continue
+			}
+			childIDs = append(childIDs, childID)
+			if _, ok := listed[childID]; !ok && nested[childID] && current.depth < maxDepth {
+				queue = append(queue, node{id: childID, depth: current.depth + 1})
+			}
+		}
+		result[current.id] = childIDs
+	}
+	return result
+}
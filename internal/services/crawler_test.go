@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/etkecc/mrs/internal/model"
+	"github.com/etkecc/mrs/internal/utils"
+)
+
+// fakeRoomsCountData returns the servers rooms count, the rest of DataRepository is not implemented
+type fakeRoomsCountData struct {
+	DataRepository
+	count map[string]int
+}
+
+func (d *fakeRoomsCountData) GetServersRoomsCount(context.Context) map[string]int {
+	return d.count
+}
+
+func TestOrderServers(t *testing.T) {
+	data := &fakeRoomsCountData{count: map[string]int{"a.com": 1, "b.com": 30, "c.com": 20, "d.com": 20}}
+	tests := []struct {
+		name     string
+		order    string
+		priority []string
+		expected []string
+	}{
+		{"default", "", nil, []string{"a.com", "b.com", "c.com", "d.com", "e.com"}},
+		{"alphabetical", model.ParsingOrderAlphabetical, nil, []string{"a.com", "b.com", "c.com", "d.com", "e.com"}},
+		{"rooms", model.ParsingOrderRooms, nil, []string{"b.com", "c.com", "d.com", "a.com", "e.com"}},
+		{"priority", "", []string{"e.com", "c.com", "unknown.com", "e.com"}, []string{"e.com", "c.com", "a.com", "b.com", "d.com"}},
+		{"priority and rooms", model.ParsingOrderRooms, []string{"a.com"}, []string{"a.com", "b.com", "c.com", "d.com", "e.com"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &fakeConfig{cfg: &model.Config{Parsing: model.ConfigParsing{Order: test.order, Priority: test.priority}}}
+			m := &Crawler{cfg: cfg, data: data}
+			ordered := m.orderServers(context.Background(), []string{"d.com", "e.com", "b.com", "a.com", "c.com"})
+			if !slices.Equal(ordered, test.expected) {
+				t.Errorf("got %v, want %v", ordered, test.expected)
+			}
+		})
+	}
+}
+
+// fakeRoomServersData returns the servers publishing the rooms, the rest of DataRepository is not implemented
+type fakeRoomServersData struct {
+	DataRepository
+	servers map[string][]string
+}
+
+func (d *fakeRoomServersData) GetRoomServers(_ context.Context, roomID string) ([]string, error) {
+	return d.servers[roomID], nil
+}
+
+func TestIsDelisted(t *testing.T) {
+	data := &fakeRoomServersData{servers: map[string][]string{
+		"!a:example.com": {"other.com"},
+		"!b:example.com": {"example.com", "other.com"},
+		"!c:other.com":   {"example.com"},
+	}}
+	m := &Crawler{data: data}
+	completed := map[string]struct{}{"example.com": {}}
+	tests := []struct {
+		roomID   string
+		expected bool
+	}{
+		{"!a:example.com", false}, // room ID server completed, but the publishing one didn't
+		{"!b:example.com", false}, // one of the publishing servers didn't complete
+		{"!c:other.com", true},
+		{"!unknown:example.com", false},
+	}
+	for _, test := range tests {
+		if delisted := m.isDelisted(context.Background(), test.roomID, completed); delisted != test.expected {
+			t.Errorf("%s: got %t, want %t", test.roomID, delisted, test.expected)
+		}
+	}
+}
+
+// fakeStaleRoomsData stores the rooms and records the removal batches, the rest of DataRepository is not implemented
+type fakeStaleRoomsData struct {
+	DataRepository
+	rooms   []*model.MatrixRoom
+	removed [][]string
+}
+
+func (d *fakeStaleRoomsData) EachRoom(_ context.Context, handler func(string, *model.MatrixRoom) bool) {
+	for _, room := range d.rooms {
+		if handler(room.ID, room) {
+			return
+		}
+	}
+}
+
+func (d *fakeStaleRoomsData) RemoveRooms(_ context.Context, keys []string) {
+	d.removed = append(d.removed, slices.Clone(keys))
+}
+
+func (d *fakeStaleRoomsData) GetRoomServers(context.Context, string) ([]string, error) {
+	return nil, nil
+}
+
+func (d *fakeStaleRoomsData) SetBiggestRooms(context.Context, []string) error { return nil }
+
+func (d *fakeStaleRoomsData) SetServersRoomsCount(context.Context, map[string]int) error { return nil }
+
+func TestAfterRoomParsing_RemoveBatches(t *testing.T) {
+	stale := 2*removeRoomsBatch + 1
+	data := &fakeStaleRoomsData{}
+	for n := range stale {
+		data.rooms = append(data.rooms, &model.MatrixRoom{ID: fmt.Sprintf("!%d:example.com", n), ParsedAt: time.Now().UTC().Add(-8 * 24 * time.Hour)})
+	}
+	data.rooms = append(data.rooms, &model.MatrixRoom{ID: "!fresh:example.com", ParsedAt: time.Now().UTC()})
+	m := &Crawler{data: data}
+
+	if removed := m.afterRoomParsing(context.Background(), time.Now().UTC(), utils.NewList[string, string]()); removed != stale {
+		t.Errorf("removed rooms: got %d, want %d", removed, stale)
+	}
+	var total int
+	for i, batch := range data.removed {
+		if len(batch) > removeRoomsBatch {
+			t.Errorf("batch %d: got %d rooms, want at most %d", i, len(batch), removeRoomsBatch)
+		}
+		if slices.Contains(batch, "!fresh:example.com") {
+			t.Error("fresh room is removed")
+		}
+		total += len(batch)
+	}
+	if total != stale {
+		t.Errorf("removed rooms in batches: got %d, want %d", total, stale)
+	}
+}
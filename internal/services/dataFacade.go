@@ -2,27 +2,41 @@ package services
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
 
+	"github.com/etkecc/mrs/internal/metrics"
 	"github.com/etkecc/mrs/internal/model"
 	"github.com/etkecc/mrs/internal/utils"
 )
 
 type dataCrawlerService interface {
-	DiscoverServers(context.Context, int, ...*utils.List[string, string])
+	DiscoverServers(context.Context, int, ...*utils.List[string, string]) *model.RunSummary
 	AddServer(context.Context, string) int
 	AddServers(context.Context, []string, int)
-	ParseRooms(context.Context, int)
+	ParseRooms(context.Context, int) *model.RunSummary
 	EachRoom(context.Context, func(string, *model.MatrixRoom) bool)
+	GetRoom(context.Context, string) (*model.MatrixRoom, error)
 	GetServersRoomsCount(ctx context.Context) map[string]int
+	RebuildCatalog(ctx context.Context) (*model.CatalogRebuild, error)
+	InspectRoom(context.Context, string) (*model.RoomInspection, error)
+	PurgeServer(context.Context, string) (bool, []string, error)
+	GetRoomsHashes(context.Context) (map[string]uint64, error)
+	GetRoomsTags(context.Context) (map[string][]string, error)
+	SetRoomsHashes(context.Context, map[string]uint64) error
 }
 
 type dataIndexService interface {
 	EmptyIndex(ctx context.Context) error
-	RoomsBatch(ctx context.Context, roomID string, data *model.Entry) error
+	RoomsBatch(ctx context.Context, roomID string, data *model.Entry) (bool, error)
 	IndexBatch(ctx context.Context) error
+	SwapIndex(ctx context.Context) error
+	Has(roomID string) (bool, error)
+	DeleteBatch(roomIDs []string) error
+	Len() int
+	Optimize(ctx context.Context) error
 }
 
 type dataStatsService interface {
@@ -33,20 +47,78 @@ type dataStatsService interface {
 	CollectServers(context.Context, bool)
 }
 
+type dataCacheService interface {
+	Purge(context.Context) map[string]string
+}
+
+type dataSearchService interface {
+	Purge()
+}
+
+type dataAvatarsService interface {
+	Prefetch(context.Context, []string)
+}
+
 // DataFacade wraps all data-related services to provide reusable API across all components of the system
 type DataFacade struct {
+	cfg     ConfigService
 	crawler dataCrawlerService
 	index   dataIndexService
 	stats   dataStatsService
+	cache   dataCacheService
+	search  dataSearchService
+	avatars dataAvatarsService
+	mu      sync.Mutex
+	runs    map[string]*model.RunSummary // job => summary of the last (not skipped) run
 }
 
 // NewDataFacade creates new data facade service
 func NewDataFacade(
+	cfg ConfigService,
 	crawler dataCrawlerService,
 	index dataIndexService,
 	stats dataStatsService,
+	cache dataCacheService,
+	search dataSearchService,
+	avatars dataAvatarsService,
 ) *DataFacade {
-	return &DataFacade{crawler, index, stats}
+	return &DataFacade{
+		cfg:     cfg,
+		crawler: crawler,
+		index:   index,
+		stats:   stats,
+		cache:   cache,
+		search:  search,
+		avatars: avatars,
+		runs:    map[string]*model.RunSummary{},
+	}
+}
+
+// Runs returns summaries of the last runs of each data pipeline job, intended for HTTP API
+func (df *DataFacade) Runs() map[string]*model.RunSummary {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	runs := make(map[string]*model.RunSummary, len(df.runs))
+	for job, summary := range df.runs {
+		runs[job] = summary
+	}
+	return runs
+}
+
+// finishRun logs summary of the job run as structured event and keeps it as the last run of the job.
+// Nil summary means the job was already in progress and the run was skipped
+func (df *DataFacade) finishRun(ctx context.Context, job string, summary *model.RunSummary) *model.RunSummary {
+	if summary == nil {
+		summary = model.NewRunSummary(job).Finish()
+		summary.Skipped = true
+	} else {
+		df.mu.Lock()
+		df.runs[job] = summary
+		df.mu.Unlock()
+	}
+	zerolog.Ctx(ctx).Info().Str("job", job).Interface("summary", summary).Msg("run summary")
+	return summary
 }
 
 // AddServer by name, intended for HTTP API
@@ -63,68 +135,336 @@ func (df *DataFacade) AddServers(ctx context.Context, names []string, workers in
 	df.stats.CollectServers(ctx, true)
 }
 
-// DiscoverServers matrix servers
-func (df *DataFacade) DiscoverServers(ctx context.Context, workers int) {
+// DiscoverServers matrix servers, returns summary of the run
+func (df *DataFacade) DiscoverServers(ctx context.Context, workers int) *model.RunSummary {
 	log := zerolog.Ctx(ctx)
 	log.Info().Msg("discovering matrix servers...")
 
 	start := time.Now().UTC()
 	df.stats.SetStartedAt(ctx, "discovery", start)
-	df.crawler.DiscoverServers(ctx, workers)
+	summary := df.crawler.DiscoverServers(ctx, workers)
 	df.stats.SetFinishedAt(ctx, "discovery", time.Now().UTC())
 	log.Info().Str("took", time.Since(start).String()).Msg("servers discovery has been finished")
+	return df.finishRun(ctx, model.RunDiscovery, summary)
 }
 
-// ParseRooms from discovered servers
-func (df *DataFacade) ParseRooms(ctx context.Context, workers int) {
+// ParseRooms from discovered servers, returns summary of the run
+func (df *DataFacade) ParseRooms(ctx context.Context, workers int) *model.RunSummary {
 	log := zerolog.Ctx(ctx)
 	log.Info().Msg("parsing matrix rooms...")
 	start := time.Now().UTC()
 	df.stats.SetStartedAt(ctx, "parsing", start)
-	df.crawler.ParseRooms(ctx, workers)
+	summary := df.crawler.ParseRooms(ctx, workers)
 	df.stats.SetFinishedAt(ctx, "parsing", time.Now().UTC())
 	log.Info().Str("took", time.Since(start).String()).Msg("matrix rooms have been parsed")
+	return df.finishRun(ctx, model.RunParsing, summary)
 }
 
-// Ingest data into search index
-func (df *DataFacade) Ingest(ctx context.Context) {
+// Ingest data into search index, incrementally if search.incremental is enabled, returns summary of the run
+func (df *DataFacade) Ingest(ctx context.Context) *model.RunSummary {
+	return df.finishRun(ctx, model.RunIndexing, df.ingest(ctx, !df.cfg.Get().Search.Incremental))
+}
+
+// ingest rooms into the search index. If fresh is true, new index is built and swapped with the live one,
+// otherwise only rooms changed since the last ingest are re-indexed in the live index and gone rooms are removed from it
+func (df *DataFacade) ingest(ctx context.Context, fresh bool) *model.RunSummary {
 	log := zerolog.Ctx(ctx)
-	log.Info().Msg("creating fresh index...")
-	if err := df.index.EmptyIndex(ctx); err != nil {
-		log.Error().Err(err).Msg("cannot create empty index")
+	summary := model.NewRunSummary(model.RunIndexing)
+	summary.Rooms = &model.RunRooms{}
+	hashes, err := df.crawler.GetRoomsHashes(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("cannot get rooms hashes")
+		summary.AddError(err)
+	}
+	if !fresh && (len(hashes) == 0 || df.index.Len() == 0) {
+		log.Info().Msg("no rooms hashes or the live index is empty, incremental ingest is not possible")
+		fresh = true
+	}
+	if fresh {
+		log.Info().Msg("creating fresh index...")
+		if err := df.index.EmptyIndex(ctx); err != nil {
+			log.Error().Err(err).Msg("cannot create empty index")
+			summary.AddError(err)
+		}
+		hashes = map[string]uint64{}
 	}
 
-	log.Info().Msg("indexing matrix rooms...")
+	log.Info().Bool("fresh", fresh).Msg("indexing matrix rooms...")
 	start := time.Now().UTC()
 	df.stats.SetStartedAt(ctx, "indexing", start)
+	aliasOwners := map[string]*aliasOwner{}
+	tags, err := df.crawler.GetRoomsTags(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("cannot get rooms tags")
+		summary.AddError(err)
+	}
+	seen := make(map[string]uint64, len(hashes))
+	var collisions, unchanged, indexed uint64
+	// rooms of the current batch, if the batch fails their hashes are rolled back,
+	// so the rooms will be re-indexed on the next ingest
+	pending := []string{}
+	rollbackRoom := func(roomID string) {
+		if previous, ok := hashes[roomID]; ok {
+			seen[roomID] = previous
+		} else {
+			delete(seen, roomID)
+		}
+	}
+	rollback := func() {
+		for _, roomID := range pending {
+			rollbackRoom(roomID)
+		}
+		indexed -= uint64(len(pending))
+		pending = pending[:0]
+	}
+	// addToBatch adds the room to the current batch, unless it wasn't changed since the last ingest (and force is false)
+	addToBatch := func(roomID string, entry *model.Entry, force bool) {
+		hash := entry.Hash()
+		seen[roomID] = hash
+		if previous, ok := hashes[roomID]; ok && previous == hash && !force {
+			unchanged++
+			return
+		}
+		pending = append(pending, roomID)
+		indexed++
+		flushed, err := df.index.RoomsBatch(ctx, roomID, entry)
+		switch {
+		case err != nil && flushed:
+			log.Warn().Err(err).Int("rooms", len(pending)).Msg("cannot index rooms batch")
+			summary.AddError(err)
+			rollback()
+		case err != nil:
+			log.Warn().Err(err).Str("id", roomID).Msg("cannot add room to batch")
+			pending = pending[:len(pending)-1]
+			rollbackRoom(roomID)
+			indexed--
+		case flushed:
+			pending = pending[:0]
+		}
+	}
+	var avatars []string
+	prefetchAvatars := df.cfg.Get().Path.Avatars != ""
 	df.crawler.EachRoom(ctx, func(roomID string, room *model.MatrixRoom) bool {
-		if err := df.index.RoomsBatch(ctx, roomID, room.Entry()); err != nil {
-			log.Warn().Err(err).Str("id", room.ID).Msg("cannot add room to batch")
+		if prefetchAvatars && room.Avatar != "" {
+			avatars = append(avatars, room.Avatar)
+		}
+		entry := room.Entry()
+		entry.Tags = tags[roomID] // part of the hash, so changed tags re-index the room
+		owned, displaced := claimAlias(aliasOwners, roomID, room)
+		if !owned {
+			log.Debug().Str("id", roomID).Str("alias", entry.Alias).Str("owner", aliasOwners[entry.Alias].id).Msg("alias collision, alias removed")
+			entry.Alias = ""
+			collisions++
 		}
+		addToBatch(roomID, entry, false)
+		if displaced == "" {
+			return false
+		}
+
+		// the previous owner is re-indexed without the alias, it has been indexed (or kept unchanged) with it already
+		collisions++
+		displacedRoom, err := df.crawler.GetRoom(ctx, displaced)
+		if err != nil || displacedRoom == nil {
+			log.Warn().Err(err).Str("id", displaced).Str("alias", room.Alias).Msg("cannot get room to remove its alias")
+			return false
+		}
+		log.Debug().Str("id", displaced).Str("alias", room.Alias).Str("owner", roomID).Msg("alias collision, alias removed")
+		displacedEntry := displacedRoom.Entry()
+		displacedEntry.Tags = tags[displaced]
+		displacedEntry.Alias = ""
+		addToBatch(displaced, displacedEntry, true)
 		return false
 	})
 	if err := df.index.IndexBatch(ctx); err != nil {
-		log.Warn().Err(err).Msg("indexing of the last batch failed")
+		log.Warn().Err(err).Int("rooms", len(pending)).Msg("indexing of the last batch failed")
+		summary.AddError(err)
+		rollback()
+	}
+	go df.avatars.Prefetch(ctx, avatars)
+	if fresh {
+		if err := df.index.SwapIndex(ctx); err != nil {
+			log.Error().Err(err).Msg("cannot swap index")
+			summary.AddError(err)
+			seen = nil // the old live index is kept, and so its hashes
+		}
+	} else {
+		summary.Rooms.Removed = df.removeGone(ctx, hashes, seen)
+		log.Info().Uint64("unchanged", unchanged).Msg("unchanged rooms have been skipped")
+	}
+	if seen != nil {
+		if err := df.crawler.SetRoomsHashes(ctx, seen); err != nil {
+			log.Error().Err(err).Msg("cannot store rooms hashes")
+			summary.AddError(err)
+		}
+	}
+	if df.cfg.Get().Search.Optimize {
+		if err := df.index.Optimize(ctx); err != nil {
+			log.Warn().Err(err).Msg("cannot optimize index")
+			summary.AddError(err)
+		}
+	}
+	metrics.AliasCollisions.Set(collisions)
+	if collisions > 0 {
+		log.Info().Uint64("rooms", collisions).Msg("alias collisions have been resolved")
 	}
 	df.stats.SetFinishedAt(ctx, "indexing", time.Now().UTC())
 	log.Info().Str("took", time.Since(start).String()).Msg("matrix rooms have been indexed")
+
+	df.cache.Purge(ctx)
+	summary.Rooms.Indexed = int(indexed)            //nolint:gosec // that's ok
+	summary.Rooms.Unchanged = int(unchanged)        //nolint:gosec // that's ok
+	summary.Rooms.AliasCollisions = int(collisions) //nolint:gosec // that's ok
+	return summary.Finish()
 }
 
-// Full data pipeline (discovery, parsing, indexing)
-func (df *DataFacade) Full(ctx context.Context, discoveryWorkers, parsingWorkers int) {
+// removeGone removes rooms that were indexed previously, but are not present in the catalog anymore, from the live index.
+// If removal fails, hashes of such rooms are kept in seen, so removal will be retried on the next ingest.
+// Returns count of the removed rooms
+func (df *DataFacade) removeGone(ctx context.Context, hashes, seen map[string]uint64) int {
+	log := zerolog.Ctx(ctx)
+	gone := []string{}
+	for roomID := range hashes {
+		if _, ok := seen[roomID]; !ok {
+			gone = append(gone, roomID)
+		}
+	}
+	if len(gone) == 0 {
+		return 0
+	}
+
+	log.Info().Int("rooms", len(gone)).Msg("removing gone rooms from the index")
+	if err := df.index.DeleteBatch(gone); err != nil {
+		log.Warn().Err(err).Msg("cannot remove gone rooms from the index")
+		for _, roomID := range gone {
+			seen[roomID] = hashes[roomID]
+		}
+		return 0
+	}
+	return len(gone)
+}
+
+// aliasOwner is the room owning the canonical alias during ingest, see claimAlias
+type aliasOwner struct {
+	id      string
+	members int
+	home    bool // room ID belongs to the alias' server
+}
+
+// claimAlias records the room's claim of its canonical alias in the owners map (alias => owner).
+// Owner is the room from the alias' server (derived from the room ID), if there is no such room - the biggest one.
+// Returns false if the alias is owned by another room, and ID of the previous owner, if the room took the alias over
+func claimAlias(owners map[string]*aliasOwner, roomID string, room *model.MatrixRoom) (owned bool, displaced string) {
+	if room.Alias == "" {
+		return true, ""
+	}
+	home := utils.ServerFrom(room.Alias) == utils.ServerFrom(roomID)
+	current, ok := owners[room.Alias]
+	if !ok {
+		owners[room.Alias] = &aliasOwner{id: roomID, members: room.Members, home: home}
+		return true, ""
+	}
+	if (home && !current.home) || (home == current.home && room.Members > current.members) {
+		displaced = current.id
+		*current = aliasOwner{id: roomID, members: room.Members, home: home}
+		return true, displaced
+	}
+	return false, ""
+}
+
+// Reindex rebuilds search index from the stored rooms, without any federation requests.
+// Useful when the index is corrupted, but the rooms catalog is intact. Returns summary of the indexing run
+func (df *DataFacade) Reindex(ctx context.Context) *model.RunSummary {
+	span := utils.StartSpan(ctx, "dataFacade.Reindex")
+	defer span.Finish()
+
+	log := zerolog.Ctx(span.Context())
+	summary := df.finishRun(span.Context(), model.RunIndexing, df.ingest(span.Context(), true))
+
+	log.Info().Msg("collecting stats...")
+	df.stats.Collect(span.Context())
+	log.Info().Msg("stats have been collected")
+	return summary
+}
+
+// Full data pipeline (discovery, parsing, indexing), returns summary of the run with summaries of each step
+func (df *DataFacade) Full(ctx context.Context, discoveryWorkers, parsingWorkers int) *model.RunSummary {
 	span := utils.StartSpan(ctx, "dataFacade.Full")
 	defer span.Finish()
 
 	log := zerolog.Ctx(span.Context())
-	df.DiscoverServers(span.Context(), discoveryWorkers)
-	df.ParseRooms(span.Context(), parsingWorkers)
-	df.Ingest(span.Context())
+	summary := model.NewRunSummary(model.RunFull)
+	summary.Steps = append(summary.Steps,
+		df.DiscoverServers(span.Context(), discoveryWorkers),
+		df.ParseRooms(span.Context(), parsingWorkers),
+		df.Ingest(span.Context()),
+	)
 
 	log.Info().Msg("collecting stats...")
 	df.stats.Collect(span.Context())
 	log.Info().Msg("stats have been collected")
+	return df.finishRun(span.Context(), model.RunFull, summary.Finish())
+}
+
+// InspectRoom returns everything known about the room: stored record, search entry,
+// moderation and blocklist status, and presence in the search index
+func (df *DataFacade) InspectRoom(ctx context.Context, roomID string) (*model.RoomInspection, error) {
+	inspection, err := df.crawler.InspectRoom(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	inspection.Indexed, err = df.index.Has(roomID)
+	if err != nil {
+		return nil, err
+	}
+	return inspection, nil
+}
+
+// PurgeServer removes the server and all its rooms from the catalog and the search index
+func (df *DataFacade) PurgeServer(ctx context.Context, name string) (*model.ServerPurge, error) {
+	span := utils.StartSpan(ctx, "dataFacade.PurgeServer")
+	defer span.Finish()
+	log := zerolog.Ctx(span.Context())
+
+	found, roomIDs, err := df.crawler.PurgeServer(span.Context(), name)
+	if err != nil {
+		return nil, err
+	}
+	purge := &model.ServerPurge{Server: name, Found: found, Rooms: len(roomIDs)}
+	indexed := make([]string, 0, len(roomIDs))
+	for _, roomID := range roomIDs {
+		if has, err := df.index.Has(roomID); err == nil && has {
+			indexed = append(indexed, roomID)
+		}
+	}
+	if err := df.index.DeleteBatch(indexed); err != nil {
+		log.Warn().Err(err).Str("server", name).Msg("cannot remove rooms from the index")
+	} else {
+		purge.Indexed = len(indexed)
+	}
+	log.Info().Str("server", name).Int("rooms", purge.Rooms).Int("indexed", purge.Indexed).Msg("server has been purged")
+
+	df.stats.CollectServers(span.Context(), true)
+	df.search.Purge()
+	df.cache.Purge(span.Context())
+	return purge, nil
 }
 
 func (df *DataFacade) GetServersRoomsCount(ctx context.Context) map[string]int {
 	return df.crawler.GetServersRoomsCount(ctx)
 }
+
+// RebuildCatalog rebuilds servers rooms catalog from the stored rooms and purges the cache, so the catalog endpoints are up to date
+func (df *DataFacade) RebuildCatalog(ctx context.Context) (*model.CatalogRebuild, error) {
+	span := utils.StartSpan(ctx, "dataFacade.RebuildCatalog")
+	defer span.Finish()
+	log := zerolog.Ctx(span.Context())
+
+	result, err := df.crawler.RebuildCatalog(span.Context())
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Int("servers", result.Servers).Int("rooms", result.Rooms).Int("exposed", result.Exposed).Str("took", result.Took).Msg("servers rooms catalog has been rebuilt")
+
+	df.cache.Purge(span.Context())
+	return result, nil
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/etkecc/mrs/internal/model"
+)
+
+type fakeConfig struct {
+	cfg *model.Config
+}
+
+func (f *fakeConfig) Get() *model.Config {
+	return f.cfg
+}
+
+// ingestCrawler is a catalog of rooms, storing hashes of the ingested rooms
+type ingestCrawler struct {
+	dataCrawlerService
+	rooms  []*model.MatrixRoom
+	hashes map[string]uint64
+}
+
+func (c *ingestCrawler) EachRoom(_ context.Context, handler func(string, *model.MatrixRoom) bool) {
+	for _, room := range c.rooms {
+		if handler(room.ID, room) {
+			return
+		}
+	}
+}
+
+func (c *ingestCrawler) GetRoom(_ context.Context, roomID string) (*model.MatrixRoom, error) {
+	for _, room := range c.rooms {
+		if room.ID == roomID {
+			return room, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *ingestCrawler) GetRoomsHashes(context.Context) (map[string]uint64, error) {
+	return c.hashes, nil
+}
+
+func (c *ingestCrawler) GetRoomsTags(context.Context) (map[string][]string, error) {
+	return nil, nil
+}
+
+func (c *ingestCrawler) SetRoomsHashes(_ context.Context, hashes map[string]uint64) error {
+	c.hashes = hashes
+	return nil
+}
+
+// ingestIndex is an index, flushing batches of batchSize rooms, the failBatch-th flush (1-based) fails
+type ingestIndex struct {
+	dataIndexService
+	batchSize int
+	failBatch int
+	flushes   int
+	batch     []string
+	indexed   map[string]bool
+	entries   map[string]*model.Entry // the last added entry of each room, if set
+}
+
+func (i *ingestIndex) flush() error {
+	i.flushes++
+	batch := i.batch
+	i.batch = nil
+	if i.flushes == i.failBatch {
+		return errors.New("batch failed")
+	}
+	for _, roomID := range batch {
+		i.indexed[roomID] = true
+	}
+	return nil
+}
+
+func (i *ingestIndex) RoomsBatch(_ context.Context, roomID string, entry *model.Entry) (bool, error) {
+	if i.entries != nil {
+		i.entries[roomID] = entry
+	}
+	i.batch = append(i.batch, roomID)
+	if len(i.batch) >= i.batchSize {
+		return true, i.flush()
+	}
+	return false, nil
+}
+
+func (i *ingestIndex) IndexBatch(context.Context) error { return i.flush() }
+func (i *ingestIndex) EmptyIndex(context.Context) error { return nil }
+func (i *ingestIndex) SwapIndex(context.Context) error  { return nil }
+func (i *ingestIndex) Len() int                         { return len(i.indexed) }
+
+type nopStats struct{ dataStatsService }
+
+func (nopStats) SetStartedAt(context.Context, string, time.Time)  {}
+func (nopStats) SetFinishedAt(context.Context, string, time.Time) {}
+
+type nopCache struct{}
+
+func (nopCache) Purge(context.Context) map[string]string { return nil }
+
+type nopSearchPurge struct{}
+
+func (nopSearchPurge) Purge() {}
+
+type nopAvatars struct{}
+
+func (nopAvatars) Prefetch(context.Context, []string) {}
+
+func TestIngest_FailedBatchRollback(t *testing.T) {
+	crawler := &ingestCrawler{
+		rooms: []*model.MatrixRoom{
+			{ID: "!a:example.com", Name: "a"},
+			{ID: "!b:example.com", Name: "b"},
+			{ID: "!c:example.com", Name: "c"},
+			{ID: "!d:example.com", Name: "d"},
+			{ID: "!e:example.com", Name: "e"},
+		},
+	}
+	index := &ingestIndex{batchSize: 2, failBatch: 1, indexed: map[string]bool{}}
+	cfg := &fakeConfig{cfg: &model.Config{Search: &model.ConfigSearch{}, Path: &model.ConfigPaths{}}}
+	df := NewDataFacade(cfg, crawler, index, nopStats{}, nopCache{}, nopSearchPurge{}, nopAvatars{})
+
+	summary := df.ingest(context.Background(), true)
+	// first batch (a, b) failed, second one (c, d) and the last one (e) succeeded
+	for _, roomID := range []string{"!a:example.com", "!b:example.com"} {
+		if _, ok := crawler.hashes[roomID]; ok {
+			t.Errorf("hash of %s (failed batch) is stored", roomID)
+		}
+	}
+	for _, roomID := range []string{"!c:example.com", "!d:example.com", "!e:example.com"} {
+		if _, ok := crawler.hashes[roomID]; !ok {
+			t.Errorf("hash of %s is not stored", roomID)
+		}
+	}
+	if summary.Rooms.Indexed != 3 {
+		t.Errorf("indexed rooms: got %d, want 3", summary.Rooms.Indexed)
+	}
+
+	// the last batch fails too, so its room is not committed either
+	index.failBatch, index.flushes = 3, 0
+	crawler.hashes = nil
+	df.ingest(context.Background(), true)
+	if _, ok := crawler.hashes["!e:example.com"]; ok {
+		t.Error("hash of the room from the failed last batch is stored")
+	}
+	if len(crawler.hashes) != 4 {
+		t.Errorf("stored hashes: got %d, want 4", len(crawler.hashes))
+	}
+}
+
+func TestIngest_AliasCollisions(t *testing.T) {
+	alias := "#general:example.com"
+	crawler := &ingestCrawler{
+		rooms: []*model.MatrixRoom{
+			{ID: "!a:other.com", Alias: alias, Name: "a", Members: 10},
+			{ID: "!b:example.com", Alias: alias, Name: "b", Members: 1}, // home server of the alias, takes it over from a
+			{ID: "!c:other.org", Alias: alias, Name: "c", Members: 100},
+			{ID: "!d:other.org", Alias: "#d:other.org", Name: "d"},
+		},
+	}
+	index := &ingestIndex{batchSize: 10, indexed: map[string]bool{}, entries: map[string]*model.Entry{}}
+	cfg := &fakeConfig{cfg: &model.Config{Search: &model.ConfigSearch{Incremental: true}, Path: &model.ConfigPaths{}}}
+	df := NewDataFacade(cfg, crawler, index, nopStats{}, nopCache{}, nopSearchPurge{}, nopAvatars{})
+	expected := map[string]string{
+		"!a:other.com":   "",
+		"!b:example.com": alias,
+		"!c:other.org":   "",
+		"!d:other.org":   "#d:other.org",
+	}
+
+	for _, fresh := range []bool{true, false} {
+		summary := df.ingest(context.Background(), fresh)
+		if summary.Rooms.AliasCollisions != 2 {
+			t.Errorf("fresh=%t: alias collisions: got %d, want 2", fresh, summary.Rooms.AliasCollisions)
+		}
+		for roomID, expectedAlias := range expected {
+			entry := index.entries[roomID]
+			if entry == nil {
+				t.Fatalf("fresh=%t: room %s is not indexed", fresh, roomID)
+			}
+			if entry.Alias != expectedAlias {
+				t.Errorf("fresh=%t: alias of %s: got %q, want %q", fresh, roomID, entry.Alias, expectedAlias)
+			}
+			if crawler.hashes[roomID] != entry.Hash() {
+				t.Errorf("fresh=%t: stored hash of %s doesn't match the indexed entry", fresh, roomID)
+			}
+		}
+	}
+}
@@ -2,6 +2,9 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -11,19 +14,32 @@ import (
 	"github.com/etkecc/mrs/internal/model"
 )
 
+// errIndexStuck is returned when a previous batch is still being indexed after its timeout
+var errIndexStuck = errors.New("previous batch is still being indexed")
+
 type Index struct {
-	mu    sync.Mutex
-	cfg   ConfigService
-	index IndexRepository
-	batch *bleve.Batch
+	mu        sync.Mutex
+	cfg       ConfigService
+	index     IndexRepository
+	batch     *bleve.Batch
+	batchSize int           // serialized size of the current batch rooms in bytes, tracked only if the batch.rooms_bytes is set
+	stuck     chan struct{} // closed when the timed out batch is finally handled by the index, nil = no stuck batch
 }
 
 type IndexRepository interface {
 	Index(roomID string, data *model.Entry) error
 	Delete(roomID string) error
-	Swap(ctx context.Context) error
+	DeleteBatch(roomIDs []string) error
+	Stage(ctx context.Context) error
+	Swap(ctx context.Context, minDocs int, minRatio float64) error
 	IndexBatch(*bleve.Batch) error
 	NewBatch() *bleve.Batch
+	Info() (*model.IndexInfo, error)
+	Mapping() ([]byte, error)
+	Has(roomID string) (bool, error)
+	Len() int
+	Optimize(ctx context.Context) (before, after uint64, err error)
+	Snapshot(ctx context.Context, w io.Writer) error
 }
 
 // NewIndex creates new index service
@@ -36,31 +52,137 @@ func NewIndex(cfg ConfigService, index IndexRepository) *Index {
 	}
 }
 
-// EmptyIndex creates new empty index
+// EmptyIndex creates new empty index, it will replace the live index on SwapIndex
 func (i *Index) EmptyIndex(ctx context.Context) error {
-	return i.index.Swap(ctx)
+	return i.index.Stage(ctx)
+}
+
+// SwapIndex replaces the live index with the new one, if the new index passes verification
+func (i *Index) SwapIndex(ctx context.Context) error {
+	swap := i.cfg.Get().Search.Swap
+	return i.index.Swap(ctx, swap.MinDocs, swap.MinRatio)
+}
+
+// Info returns information about the search index
+func (i *Index) Info() (*model.IndexInfo, error) {
+	return i.index.Info()
+}
+
+// Mapping returns JSON-encoded mapping of the live search index, to debug search relevance
+func (i *Index) Mapping() ([]byte, error) {
+	return i.index.Mapping()
+}
+
+// Optimize merges segments of the live search index, to keep search latency stable
+func (i *Index) Optimize(ctx context.Context) error {
+	log := zerolog.Ctx(ctx)
+	started := time.Now()
+	log.Info().Msg("optimizing index...")
+	before, after, err := i.index.Optimize(ctx)
+	if err != nil {
+		return err
+	}
+	log.Info().Uint64("segments_before", before).Uint64("segments_after", after).Str("took", time.Since(started).String()).Msg("index has been optimized")
+	return nil
+}
+
+// Snapshot writes gzipped tarball of the live search index into w, to replicate it to other instances
+func (i *Index) Snapshot(ctx context.Context, w io.Writer) error {
+	log := zerolog.Ctx(ctx)
+	started := time.Now()
+	log.Info().Msg("creating index snapshot...")
+	if err := i.index.Snapshot(ctx, w); err != nil {
+		return err
+	}
+	log.Info().Str("took", time.Since(started).String()).Msg("index snapshot has been created")
+	return nil
+}
+
+// Len returns number of documents in the live search index
+func (i *Index) Len() int {
+	return i.index.Len()
 }
 
-// RoomsBatch indexes rooms in batches
-func (i *Index) RoomsBatch(ctx context.Context, roomID string, data *model.Entry) error {
+// Has checks if room is present in the search index
+func (i *Index) Has(roomID string) (bool, error) {
+	return i.index.Has(roomID)
+}
+
+// Delete room from the search index
+func (i *Index) Delete(roomID string) error {
+	return i.index.Delete(roomID)
+}
+
+// DeleteBatch removes rooms from the search index at once
+func (i *Index) DeleteBatch(roomIDs []string) error {
+	return i.index.DeleteBatch(roomIDs)
+}
+
+// RoomsBatch indexes rooms in batches, returns true if the current batch (including the room) has been indexed.
+// If the batch indexing fails, the error is returned and all rooms of the batch are not indexed
+func (i *Index) RoomsBatch(ctx context.Context, roomID string, data *model.Entry) (bool, error) {
 	i.mu.Lock()
 	defer i.mu.Unlock()
 
-	if i.batch.Size() >= i.cfg.Get().Batch.Rooms {
-		return i.IndexBatch(ctx)
+	if err := i.batch.Index(roomID, data); err != nil {
+		return false, err
 	}
-
-	return i.batch.Index(roomID, data)
+	batchCfg := i.cfg.Get().Batch
+	if batchCfg.RoomsBytes > 0 {
+		i.batchSize += data.Size()
+	}
+	if i.batch.Size() >= batchCfg.Rooms || (batchCfg.RoomsBytes > 0 && i.batchSize >= batchCfg.RoomsBytes) {
+		return true, i.indexBatch(ctx)
+	}
+	return false, nil
 }
 
 // IndexBatch performs indexing of the current batch
 func (i *Index) IndexBatch(ctx context.Context) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.indexBatch(ctx)
+}
+
+// indexBatch performs indexing of the current batch, must be called with the lock held.
+// The batch is handed over to the index and replaced with a new one, so if the index stalls,
+// the call returns an error after the timeout (and releases the lock), and the batch is considered failed.
+// The index write itself cannot be cancelled, so until the stuck batch is handled by the index,
+// the following batches are not handed over to it (to not pile up on the stalled index) and fail immediately
+func (i *Index) indexBatch(ctx context.Context) error {
 	log := zerolog.Ctx(ctx)
-	size := i.batch.Size()
+	batch, bytes := i.batch, i.batchSize
+	i.batch, i.batchSize = i.index.NewBatch(), 0
+	size := batch.Size()
+	if i.stuck != nil {
+		select {
+		case <-i.stuck:
+			i.stuck = nil
+		default:
+			return fmt.Errorf("indexing of %d rooms batch skipped: %w", size, errIndexStuck)
+		}
+	}
 	started := time.Now()
-	log.Info().Int("len", size).Msg("indexing batch...")
-	err := i.index.IndexBatch(i.batch)
-	i.batch.Reset()
-	log.Info().Int("len", size).Str("took", time.Since(started).String()).Msg("indexed batch")
+	log.Info().Int("len", size).Int("bytes", bytes).Msg("indexing batch...")
+
+	timeout := i.cfg.Get().Batch.GetTimeout()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	finished := make(chan struct{})
+	go func() {
+		done <- i.index.IndexBatch(batch)
+		close(finished)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		i.stuck = finished
+		err = fmt.Errorf("indexing of %d rooms batch has not finished in %s: %w", size, timeout, ctx.Err())
+	}
+	log.Info().Err(err).Int("len", size).Str("took", time.Since(started).String()).Msg("indexed batch")
 	return err
 }
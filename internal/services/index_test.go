@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+
+	"github.com/etkecc/mrs/internal/model"
+)
+
+// slowIndex is an index repository that stalls batches until released
+type slowIndex struct {
+	IndexRepository
+	mem     bleve.Index
+	release chan struct{}
+}
+
+func newSlowIndex(t *testing.T) *slowIndex {
+	t.Helper()
+	mem, err := bleve.NewMemOnly(bleve.NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mem.Close() })
+	return &slowIndex{mem: mem, release: make(chan struct{})}
+}
+
+func (s *slowIndex) NewBatch() *bleve.Batch {
+	return s.mem.NewBatch()
+}
+
+func (s *slowIndex) IndexBatch(batch *bleve.Batch) error {
+	<-s.release
+	return s.mem.Batch(batch)
+}
+
+func TestIndexBatch_SlowIndex(t *testing.T) {
+	repo := newSlowIndex(t)
+	cfg := &fakeConfig{cfg: &model.Config{Batch: &model.ConfigBatch{Rooms: 1, Timeout: 1}}}
+	index := NewIndex(cfg, repo)
+	ctx := context.Background()
+
+	started := time.Now()
+	flushed, err := index.RoomsBatch(ctx, "!a:example.com", &model.Entry{ID: "!a:example.com"})
+	if !flushed || err == nil || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected timed out batch, got flushed=%v err=%v", flushed, err)
+	}
+	if took := time.Since(started); took > 5*time.Second {
+		t.Fatalf("timeout is not respected, took %s", took)
+	}
+
+	// the lock is released, the following batches fail fast while the index is stuck
+	started = time.Now()
+	_, err = index.RoomsBatch(ctx, "!b:example.com", &model.Entry{ID: "!b:example.com"})
+	if !errors.Is(err, errIndexStuck) {
+		t.Fatalf("expected stuck index error, got %v", err)
+	}
+	if took := time.Since(started); took > time.Second {
+		t.Fatalf("batch on the stuck index was not rejected immediately, took %s", took)
+	}
+
+	// once the index recovers, batches are indexed again
+	close(repo.release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err = index.RoomsBatch(ctx, "!c:example.com", &model.Entry{ID: "!c:example.com"})
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("batch after recovery: %v", err)
+	}
+	if doc, err := repo.mem.Document("!c:example.com"); err != nil || doc == nil {
+		t.Errorf("room is not indexed after recovery: %v", err)
+	}
+}
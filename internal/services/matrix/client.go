@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/rs/zerolog"
 
@@ -12,7 +13,28 @@ import (
 	"github.com/etkecc/mrs/internal/utils"
 )
 
-var mediaFallbacks = []string{"https://matrix-client.matrix.org"}
+var defaultMediaFallbacks = []string{"https://matrix-client.matrix.org"}
+
+// defaultMediaTimeout is the timeout of the room's server media request before trying fallbacks
+const defaultMediaTimeout = 5 * time.Second
+
+// mediaBody is a media response body, closing it cancels the request context
+type mediaBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close response body and cancel the request context
+func (b *mediaBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// mediaResp is a successful media response
+type mediaResp struct {
+	body        io.ReadCloser
+	contentType string
+}
 
 // GetClientWellKnown returns json-eligible response for /.well-known/matrix/client
 func (s *Server) GetClientWellKnown() []byte {
@@ -146,26 +168,88 @@ func (s *Server) GetClientMediaThumbnail(ctx context.Context, serverName, mediaI
 	span := utils.StartSpan(ctx, "matrix.GetClientMediaThumbnail")
 	defer span.Finish()
 
-	query := utils.ValuesOrDefault(params, defaultThumbnailParams)
-	urls := make([]string, 0, len(mediaFallbacks)+1)
-	serverURL := s.QueryCSURL(span.Context(), serverName)
-	if serverURL != "" {
-		urls = append(urls, serverURL+"/_matrix/media/v3/thumbnail/"+serverName+"/"+mediaID+"?"+query)
+	cfg := s.cfg.Get().Matrix.Media
+	fallbacks := cfg.Fallbacks
+	if len(fallbacks) == 0 {
+		fallbacks = defaultMediaFallbacks
 	}
-	for _, serverURL := range mediaFallbacks {
-		urls = append(urls, serverURL+"/_matrix/media/v3/thumbnail/"+serverName+"/"+mediaID+"?"+query)
+	timeout := defaultMediaTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
 	}
-	for _, avatarURL := range urls {
-		resp, err := utils.Get(span.Context(), avatarURL, 0)
-		if err != nil {
-			continue
+
+	path := "/_matrix/media/v3/thumbnail/" + serverName + "/" + mediaID + "?" + utils.ValuesOrDefault(params, defaultThumbnailParams)
+	urls := make([]string, 0, len(fallbacks)+1)
+	timeouts := make([]time.Duration, 0, len(fallbacks)+1)
+	if serverURL := s.QueryCSURL(span.Context(), serverName); serverURL != "" {
+		urls = append(urls, serverURL+path)
+		timeouts = append(timeouts, timeout)
+	}
+	for _, serverURL := range fallbacks {
+		urls = append(urls, serverURL+path)
+		timeouts = append(timeouts, utils.DefaultTimeout)
+	}
+
+	var resp *mediaResp
+	if cfg.Race {
+		resp = s.raceMedia(span.Context(), urls)
+	} else {
+		for i, avatarURL := range urls {
+			if resp = s.getMedia(span.Context(), avatarURL, timeouts[i]); resp != nil {
+				break
+			}
 		}
-		if resp.StatusCode != http.StatusOK {
-			resp.Body.Close()
+	}
+	if resp == nil {
+		return nil, ""
+	}
+	return resp.body, resp.contentType
+}
+
+// raceMedia requests all media URLs at once and returns the first successful response
+func (s *Server) raceMedia(ctx context.Context, urls []string) *mediaResp {
+	results := make(chan *mediaResp, len(urls))
+	for _, mediaURL := range urls {
+		go func(mediaURL string) {
+			results <- s.getMedia(ctx, mediaURL, utils.DefaultTimeout)
+		}(mediaURL)
+	}
+
+	for i := range urls {
+		resp := <-results
+		if resp == nil {
 			continue
 		}
-		return resp.Body, resp.Header.Get("Content-Type")
-	}
+		// close the rest of responses in background
+		go func(left int) {
+			for ; left > 0; left-- {
+				if other := <-results; other != nil {
+					other.body.Close()
+				}
+			}
+		}(len(urls) - i - 1)
+		return resp
+	}
+	return nil
+}
 
-	return nil, ""
+// getMedia requests media URL with timeout, returns nil if request failed
+func (s *Server) getMedia(ctx context.Context, mediaURL string, timeout time.Duration) *mediaResp {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	resp, err := utils.Get(ctx, mediaURL, 0)
+	if err != nil {
+		cancel()
+		zerolog.Ctx(ctx).Debug().Err(err).Str("url", mediaURL).Msg("cannot get media")
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		zerolog.Ctx(ctx).Debug().Int("status", resp.StatusCode).Str("url", mediaURL).Msg("cannot get media")
+		return nil
+	}
+	return &mediaResp{
+		body:        &mediaBody{ReadCloser: resp.Body, cancel: cancel},
+		contentType: resp.Header.Get("Content-Type"),
+	}
 }
@@ -21,6 +21,15 @@ type wellKnownClientRespHomeserver struct {
 	BaseURL string `json:"base_url"`
 }
 
+// wellKnownSupportResp contains MRS-specific fields of the MSC1929 support file
+type wellKnownSupportResp struct {
+	MRS wellKnownSupportRespMRS `json:"cc.etke.mrs"`
+}
+
+type wellKnownSupportRespMRS struct {
+	NoIndex bool `json:"noindex"`
+}
+
 type serverVersionResp struct {
 	Server map[string]string `json:"server"`
 }
@@ -77,6 +77,31 @@ func (s *Server) parseClientWellKnown(ctx context.Context, serverName string) (s
 	return wellknown.Homeserver.BaseURL, nil
 }
 
+// parseSupportWellKnown returns true if server opted out of indexing in the MSC1929 support file
+func (s *Server) parseSupportWellKnown(ctx context.Context, serverName string) bool {
+	span := utils.StartSpan(ctx, "matrix.parseSupportWellKnown")
+	defer span.Finish()
+
+	resp, err := utils.Get(span.Context(), "https://"+serverName+"/.well-known/matrix/support", 0)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	datab, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false
+	}
+	var wellknown *wellKnownSupportResp
+	if wkerr := json.Unmarshal(datab, &wellknown); wkerr != nil || wellknown == nil {
+		return false
+	}
+	return wellknown.MRS.NoIndex
+}
+
 // parseServerWellKnown returns Federation API host:port
 func (s *Server) parseServerWellKnown(ctx context.Context, serverName string) (string, error) {
 	span := utils.StartSpan(ctx, "matrix.parseServerWellKnown")
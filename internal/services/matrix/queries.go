@@ -68,6 +68,7 @@ func (s *Server) GetMediaThumbnail(ctx context.Context, serverName, mediaID stri
 		body, _ := io.ReadAll(resp.Body) //nolint:errcheck // intended
 		resp.Body.Close()
 		log.Warn().Str("server", serverName).Str("mediaID", mediaID).Int("status", resp.StatusCode).Str("body", string(body)).Msg("cannot get media thumbnail")
+		return nil, ""
 	}
 	return s.getImageFromMultipart(span.Context(), resp)
 }
@@ -176,7 +177,21 @@ func (s *Server) QueryVersion(ctx context.Context, serverName string) (server, s
 
 // QueryPublicRooms over federation
 func (s *Server) QueryPublicRooms(ctx context.Context, serverName, limit, since string) (*model.RoomDirectoryResponse, error) {
-	span := utils.StartSpan(ctx, "matrix.QueryPublicRooms")
+	chunk := []*model.RoomDirectoryRoom{}
+	roomsResp, err := s.StreamPublicRooms(ctx, serverName, limit, since, func(room *model.RoomDirectoryRoom) {
+		chunk = append(chunk, room)
+	})
+	if err != nil {
+		return nil, err
+	}
+	roomsResp.Chunk = chunk
+	return roomsResp, nil
+}
+
+// StreamPublicRooms over federation, rooms are decoded one by one and passed to the handler as soon as they are read,
+// so memory usage doesn't depend on the response size. Returned response doesn't contain chunk
+func (s *Server) StreamPublicRooms(ctx context.Context, serverName, limit, since string, handler func(*model.RoomDirectoryRoom)) (*model.RoomDirectoryResponse, error) {
+	span := utils.StartSpan(ctx, "matrix.StreamPublicRooms")
 	defer span.Finish()
 
 	ctx, cancel := context.WithTimeout(span.Context(), utils.DefaultTimeout)
@@ -204,17 +219,134 @@ func (s *Server) QueryPublicRooms(ctx context.Context, serverName, limit, since
 		}
 		return nil, merr
 	}
-	data, err := io.ReadAll(resp.Body)
+
+	return s.decodePublicRooms(span.Context(), serverName, resp.Body, handler)
+}
+
+// decodePublicRooms decodes public rooms response as a stream,
+// malformed rooms are skipped, so one bad room doesn't lose the whole page
+func (s *Server) decodePublicRooms(ctx context.Context, serverName string, body io.Reader, handler func(*model.RoomDirectoryRoom)) (*model.RoomDirectoryResponse, error) {
+	dec := json.NewDecoder(body)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	var skipped, total int
+	var lastErr error
+	roomsResp := &model.RoomDirectoryResponse{}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "chunk":
+			if err := expectDelim(dec, '['); err != nil {
+				return nil, err
+			}
+			for dec.More() {
+				total++
+				var rawRoom json.RawMessage
+				if err := dec.Decode(&rawRoom); err != nil { // broken json, can't continue
+					return nil, err
+				}
+				var room *model.RoomDirectoryRoom
+				if err := json.Unmarshal(rawRoom, &room); err != nil || room == nil {
+					skipped++
+					lastErr = err
+					continue
+				}
+				handler(room)
+			}
+			if err := expectDelim(dec, ']'); err != nil {
+				return nil, err
+			}
+		case "next_batch":
+			err = dec.Decode(&roomsResp.NextBatch)
+		case "prev_batch":
+			err = dec.Decode(&roomsResp.PrevBatch)
+		case "total_room_count_estimate":
+			err = dec.Decode(&roomsResp.Total)
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode %v: %w", key, err)
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+
+	if skipped > 0 {
+		zerolog.Ctx(ctx).Warn().Err(lastErr).Str("server", serverName).Int("skipped", skipped).Int("of", total).Msg("skipped malformed rooms")
+	}
+	return roomsResp, nil
+}
+
+// expectDelim reads the next json token and checks that it's the expected delimiter
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("unexpected json token %v, expected %v", token, delim)
+	}
+	return nil
+}
+
+// QueryHierarchy over federation, returns the space with its direct children (as known to the server)
+func (s *Server) QueryHierarchy(ctx context.Context, serverName, roomID string) (*model.RoomHierarchyResponse, error) {
+	span := utils.StartSpan(ctx, "matrix.QueryHierarchy")
+	defer span.Finish()
+
+	path := "/_matrix/federation/v1/hierarchy/" + url.PathEscape(roomID) + "?suggested_only=false"
+	authHeaders, err := s.Authorize(serverName, http.MethodGet, path, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	var roomsResp *model.RoomDirectoryResponse
-	err = json.Unmarshal(data, &roomsResp)
+	ctx, cancel := context.WithTimeout(span.Context(), utils.DefaultTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(s.getURL(span.Context(), serverName, false), "/")+path, http.NoBody)
 	if err != nil {
 		return nil, err
 	}
-	return roomsResp, nil
+	for _, h := range authHeaders {
+		req.Header.Add("Authorization", h)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Encoding", utils.AcceptEncoding)
+	req.Header.Set("User-Agent", version.UserAgent)
+
+	resp, err := utils.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body) //nolint:errcheck // intended
+		if merr := s.parseErrorResp(resp.Status, body); merr != nil {
+			return nil, merr
+		}
+		return nil, fmt.Errorf("cannot get room hierarchy: %s", resp.Status)
+	}
+
+	var hierarchy *model.RoomHierarchyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&hierarchy); err != nil {
+		return nil, err
+	}
+	if hierarchy == nil || hierarchy.Room == nil {
+		return nil, fmt.Errorf("cannot get room hierarchy: empty response")
+	}
+	return hierarchy, nil
+}
+
+// QueryOptOut checks if server opted out of indexing
+func (s *Server) QueryOptOut(ctx context.Context, serverName string) bool {
+	return s.parseSupportWellKnown(ctx, serverName)
 }
 
 // QueryCSURL returns URL of Matrix CS API server
@@ -269,14 +401,22 @@ func (s *Server) buildPublicRoomsReq(ctx context.Context, serverName, limit, sin
 		req.Header.Add("Authorization", h)
 	}
 	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Encoding", utils.AcceptEncoding)
 	req.Header.Set("User-Agent", version.UserAgent)
 	return req, nil
 }
 
+// multipartImage is an image part of the multipart response, closing it closes the response body
+type multipartImage struct {
+	io.Reader
+	io.Closer
+}
+
 func (s *Server) getImageFromMultipart(ctx context.Context, resp *http.Response) (contentStream io.Reader, contentType string) {
 	log := zerolog.Ctx(ctx)
 	_, mediaParams, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
 	if err != nil {
+		resp.Body.Close()
 		log.Warn().Err(err).Msg("cannot parse content type")
 		return nil, ""
 	}
@@ -287,6 +427,7 @@ func (s *Server) getImageFromMultipart(ctx context.Context, resp *http.Response)
 			break
 		}
 		if err != nil {
+			resp.Body.Close()
 			log.Warn().Err(err).Msg("cannot read multipart")
 			return nil, ""
 		}
@@ -294,9 +435,10 @@ func (s *Server) getImageFromMultipart(ctx context.Context, resp *http.Response)
 			continue
 		}
 		if strings.HasPrefix(p.Header.Get("Content-Type"), "image/") {
-			return p, p.Header.Get("Content-Type")
+			return &multipartImage{Reader: p, Closer: resp.Body}, p.Header.Get("Content-Type")
 		}
 	}
+	resp.Body.Close()
 	log.Warn().Msg("cannot find image in multipart")
 	return nil, ""
 }
@@ -58,10 +58,10 @@ func (s *Server) PublicRooms(ctx context.Context, req *http.Request, rdReq *mode
 		limit = MatrixSearchLimit
 	}
 	if limit > MatrixSearchLimit {
-		limit = s.cfg.Get().Search.Defaults.Limit
+		limit = MatrixSearchLimit
 	}
 	offset := utils.StringToInt(rdReq.Since)
-	entries, total, err := s.search.Search(span.Context(), origin, rdReq.Filter.GenericSearchTerm, "", limit, offset)
+	entries, total, err := s.search.Search(span.Context(), origin, rdReq.Filter.GenericSearchTerm, "", limit, offset, model.SearchOptions{})
 	if err != nil {
 		log.Error().Err(err).Msg("search from matrix failed")
 		return http.StatusInternalServerError, nil
@@ -71,20 +71,16 @@ func (s *Server) PublicRooms(ctx context.Context, req *http.Request, rdReq *mode
 		chunk = append(chunk, entry.RoomDirectory())
 	}
 
-	var prev int
-	if offset >= limit {
-		prev = offset - limit
+	var prevBatch string
+	if offset > 0 { // previous page exists, even if it's a partial one
+		prevBatch = strconv.Itoa(max(offset-limit, 0))
 	}
+
 	var next int
 	if len(chunk) >= limit {
 		next = offset + len(chunk)
 	}
 
-	var prevBatch string
-	if prev > 0 {
-		prevBatch = strconv.Itoa(prev)
-	}
-
 	var nextBatch string
 	if next > 0 {
 		nextBatch = strconv.Itoa(next)
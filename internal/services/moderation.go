@@ -1,13 +1,12 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/rs/zerolog"
@@ -21,12 +20,19 @@ type EmailService interface {
 	SendModReport(text, email string) error
 }
 
+type moderationSearchService interface {
+	Purge()
+}
+
 // Moderation service
 type Moderation struct {
-	cfg   ConfigService
-	data  DataRepository
-	mail  EmailService
-	index IndexRepository
+	cfg      ConfigService
+	data     DataRepository
+	mail     EmailService
+	index    IndexRepository
+	search   moderationSearchService
+	mu       *sync.Mutex
+	notified map[string]time.Time // server name => last time its MSC1929 contacts were notified
 }
 
 // webhookPayload for hookshot
@@ -36,13 +42,25 @@ type webhookPayload struct {
 	Markdown string `json:"text"`
 }
 
+type reportWebhookPayload struct {
+	RoomID    string `json:"room_id"`
+	RoomName  string `json:"room_name"`
+	RoomAlias string `json:"room_alias"`
+	Server    string `json:"server"`
+	Link      string `json:"link"`
+	*model.RoomReport
+}
+
 // NewModeration service
-func NewModeration(cfg ConfigService, data DataRepository, index IndexRepository, mail EmailService) *Moderation {
+func NewModeration(cfg ConfigService, data DataRepository, index IndexRepository, search moderationSearchService, mail EmailService) *Moderation {
 	return &Moderation{
-		cfg:   cfg,
-		data:  data,
-		mail:  mail,
-		index: index,
+		cfg:      cfg,
+		data:     data,
+		mail:     mail,
+		index:    index,
+		search:   search,
+		mu:       &sync.Mutex{},
+		notified: map[string]time.Time{},
 	}
 }
 
@@ -61,8 +79,8 @@ func (m *Moderation) getReportText(ctx context.Context, roomID, reason string, r
 
 	text.WriteString("* ID: [")
 	text.WriteString(roomID)
-	text.WriteString("](https://matrix.to/#/")
-	text.WriteString(roomID)
+	text.WriteString("](")
+	text.WriteString((&model.Entry{ID: roomID}).DeepLink(m.cfg.Get().Public.Links))
 	text.WriteString(")\n")
 
 	text.WriteString("* Reason: ")
@@ -128,29 +146,26 @@ func (m *Moderation) sendWebhook(ctx context.Context, room *model.MatrixRoom, se
 		return nil
 	}
 
-	payload, err := json.Marshal(webhookPayload{
+	return postWebhook(ctx, m.cfg.Get().Webhooks.Moderation, webhookPayload{
 		Username: m.cfg.Get().Matrix.ServerName,
 		Markdown: m.getReportText(ctx, room.ID, reason, room, server),
 	})
-	if err != nil {
-		return err
-	}
+}
 
-	req, err := http.NewRequest(http.MethodPost, m.cfg.Get().Webhooks.Moderation, bytes.NewReader(payload))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
-		body, err := io.ReadAll(resp.Body)
-		return fmt.Errorf("backend returned HTTP %d: %s %w", resp.StatusCode, string(body), err)
+// notifyReport sends machine-readable report notification to the reports webhook
+func (m *Moderation) notifyReport(ctx context.Context, room *model.MatrixRoom, report *model.RoomReport) error {
+	if m.cfg.Get().Webhooks.Reports == "" {
+		return nil
 	}
-	return nil
+
+	return postWebhook(ctx, m.cfg.Get().Webhooks.Reports, reportWebhookPayload{
+		RoomID:     room.ID,
+		RoomName:   room.Name,
+		RoomAlias:  room.Alias,
+		Server:     room.Server,
+		Link:       room.Entry().DeepLink(m.cfg.Get().Public.Links),
+		RoomReport: report,
+	})
 }
 
 // sendEmail sends a report to the configured moderators' email
@@ -162,8 +177,12 @@ func (m *Moderation) sendEmail(ctx context.Context, room *model.MatrixRoom, serv
 	return m.mail.SendModReport(text, m.cfg.Get().Email.Moderation)
 }
 
-// Report a room
-func (m *Moderation) Report(ctx context.Context, roomID, reason string, noMSC1929 bool) error {
+// Report a room. reportedBy is the reporter's IP or login, stored for moderators
+func (m *Moderation) Report(ctx context.Context, roomID, reason, reportedBy string, noMSC1929 bool) error {
+	roomID, err := utils.NormalizeRoomID(roomID)
+	if err != nil {
+		return err
+	}
 	if m.data.IsReported(ctx, roomID) {
 		return nil
 	}
@@ -189,8 +208,12 @@ func (m *Moderation) Report(ctx context.Context, roomID, reason string, noMSC192
 	}
 
 	if !noMSC1929 {
-		if merr := m.mail.SendReport(ctx, room, server, reason, server.Contacts.Emails); merr != nil {
+		if !m.canNotify(server.Name) {
+			log.Info().Str("server", server.Name).Msg("server's owner has been notified recently, skipping report email")
+		} else if merr := m.mail.SendReport(ctx, room, server, reason, server.Contacts.Emails); merr != nil {
 			log.Warn().Err(merr).Msg("cannot send report to the server's owner")
+		} else {
+			m.setNotified(server.Name)
 		}
 	}
 
@@ -198,7 +221,40 @@ func (m *Moderation) Report(ctx context.Context, roomID, reason string, noMSC192
 		log.Error().Err(err).Msg("cannot send moderation email")
 	}
 
-	return m.data.ReportRoom(ctx, roomID, reason)
+	reportedAt := time.Now().UTC()
+	report := &model.RoomReport{
+		Reason:     reason,
+		ReportedBy: reportedBy,
+		ReportedAt: &reportedAt,
+	}
+	if err := m.notifyReport(ctx, room, report); err != nil {
+		log.Error().Err(err).Msg("cannot send report webhook")
+	}
+
+	return m.data.ReportRoom(ctx, roomID, report)
+}
+
+// canNotify checks if the server's MSC1929 contacts can be notified (rate limit)
+func (m *Moderation) canNotify(server string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	last, ok := m.notified[server]
+	return !ok || time.Since(last) >= m.cfg.Get().Email.GetReportInterval()
+}
+
+// setNotified records successful notification of the server's MSC1929 contacts, see canNotify
+func (m *Moderation) setNotified(server string) {
+	interval := m.cfg.Get().Email.GetReportInterval()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.notified[server] = now
+	for name, last := range m.notified { // cleanup, to keep the map small
+		if now.Sub(last) >= interval {
+			delete(m.notified, name)
+		}
+	}
 }
 
 // List returns full list of the banned rooms (optionally from specific server)
@@ -208,13 +264,24 @@ func (m *Moderation) List(ctx context.Context, serverName ...string) ([]string,
 
 // Ban a room
 func (m *Moderation) Ban(ctx context.Context, roomID string) error {
+	roomID, err := utils.NormalizeRoomID(roomID)
+	if err != nil {
+		return err
+	}
 	if err := m.data.BanRoom(ctx, roomID); err != nil {
 		return err
 	}
-	return m.index.Delete(roomID)
+	if err := m.index.Delete(roomID); err != nil {
+		return err
+	}
+	m.search.Purge()
+	return nil
 }
 
-// Unban a room
+// Unban a room. Invalid room IDs are passed as is, to allow removal of the bans stored before validation was introduced
 func (m *Moderation) Unban(ctx context.Context, roomID string) error {
+	if normalized, err := utils.NormalizeRoomID(roomID); err == nil {
+		roomID = normalized
+	}
 	return m.data.UnbanRoom(ctx, roomID)
 }
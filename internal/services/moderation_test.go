@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/etkecc/mrs/internal/model"
+	"github.com/etkecc/mrs/internal/utils"
+)
+
+// fakeModerationData stores reports of the rooms, the rest of DataRepository is not implemented
+type fakeModerationData struct {
+	DataRepository
+	rooms    map[string]*model.MatrixRoom
+	reported map[string]bool
+	banned   []string
+}
+
+func (d *fakeModerationData) IsReported(_ context.Context, roomID string) bool {
+	return d.reported[roomID]
+}
+
+func (d *fakeModerationData) GetRoom(_ context.Context, roomID string) (*model.MatrixRoom, error) {
+	return d.rooms[roomID], nil
+}
+
+func (d *fakeModerationData) GetServerInfo(_ context.Context, name string) (*model.MatrixServer, error) {
+	return &model.MatrixServer{Name: name}, nil
+}
+
+func (d *fakeModerationData) ReportRoom(_ context.Context, roomID string, _ *model.RoomReport) error {
+	d.reported[roomID] = true
+	return nil
+}
+
+func (d *fakeModerationData) BanRoom(_ context.Context, roomID string) error {
+	d.banned = append(d.banned, roomID)
+	return nil
+}
+
+// fakeModerationIndex records deleted rooms, the rest of IndexRepository is not implemented
+type fakeModerationIndex struct {
+	IndexRepository
+	deleted []string
+}
+
+func (i *fakeModerationIndex) Delete(roomID string) error {
+	i.deleted = append(i.deleted, roomID)
+	return nil
+}
+
+// countingSearchPurge counts purges of the cached search results
+type countingSearchPurge struct {
+	purges int
+}
+
+func (s *countingSearchPurge) Purge() {
+	s.purges++
+}
+
+// fakeMail fails the first failures report emails
+type fakeMail struct {
+	failures int
+	sent     int
+}
+
+func (m *fakeMail) SendReport(context.Context, *model.MatrixRoom, *model.MatrixServer, string, []string) error {
+	if m.failures > 0 {
+		m.failures--
+		return errors.New("postmark is down")
+	}
+	m.sent++
+	return nil
+}
+
+func (m *fakeMail) SendModReport(string, string) error {
+	return nil
+}
+
+func TestModeration_ReportInterval(t *testing.T) {
+	data := &fakeModerationData{
+		rooms: map[string]*model.MatrixRoom{
+			"!a:example.com": {ID: "!a:example.com", Server: "example.com"},
+			"!b:example.com": {ID: "!b:example.com", Server: "example.com"},
+			"!c:example.com": {ID: "!c:example.com", Server: "example.com"},
+		},
+		reported: map[string]bool{},
+	}
+	mail := &fakeMail{failures: 1}
+	cfg := &fakeConfig{cfg: &model.Config{
+		Public:   &model.ConfigPublic{API: "https://api.example.com"},
+		Matrix:   &model.ConfigMatrix{ServerName: "example.com"},
+		Webhooks: &model.ConfigWebhooks{},
+		Email:    &model.ConfigEmail{},
+	}}
+	mod := NewModeration(cfg, data, nil, nopSearchPurge{}, mail)
+
+	for _, roomID := range []string{"!a:example.com", "!b:example.com", "!c:example.com"} {
+		if err := mod.Report(context.Background(), roomID, "spam", "127.0.0.1", false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the first email failed, so the second one is sent, and the third one is rate limited
+	if mail.sent != 1 {
+		t.Errorf("sent emails: got %d, want 1", mail.sent)
+	}
+	if mail.failures != 0 {
+		t.Errorf("the first email was not attempted")
+	}
+}
+
+func TestModeration_BanValidation(t *testing.T) {
+	data := &fakeModerationData{reported: map[string]bool{}}
+	index := &fakeModerationIndex{}
+	search := &countingSearchPurge{}
+	mod := NewModeration(&fakeConfig{cfg: &model.Config{}}, data, index, search, &fakeMail{})
+
+	if err := mod.Ban(context.Background(), "!abc:Example.COM"); err != nil {
+		t.Fatal(err)
+	}
+	for _, roomID := range []string{"!abc", "abc:example.com", "#:example.com"} {
+		if err := mod.Ban(context.Background(), roomID); !errors.Is(err, utils.ErrInvalidRoomID) {
+			t.Errorf("%s: got %v, want %v", roomID, err, utils.ErrInvalidRoomID)
+		}
+		if err := mod.Report(context.Background(), roomID, "spam", "127.0.0.1", false); !errors.Is(err, utils.ErrInvalidRoomID) {
+			t.Errorf("%s report: got %v, want %v", roomID, err, utils.ErrInvalidRoomID)
+		}
+	}
+	if !slices.Equal(data.banned, []string{"!abc:example.com"}) {
+		t.Errorf("banned rooms: got %v", data.banned)
+	}
+	if !slices.Equal(index.deleted, []string{"!abc:example.com"}) {
+		t.Errorf("deleted rooms: got %v", index.deleted)
+	}
+	if search.purges != 1 {
+		t.Errorf("cached search results purges: got %d, want 1", search.purges)
+	}
+	if len(data.reported) != 0 {
+		t.Errorf("reported rooms: got %v", data.reported)
+	}
+}
@@ -86,11 +86,11 @@ func (r *Robots) parse(ctx context.Context, serverName string) {
 		return
 	}
 
+	defer resp.Body.Close()
 	if !r.isEligible(resp) {
 		r.set(serverName, nil)
 		return
 	}
-	defer resp.Body.Close()
 
 	parsed, err := robots.From(resp.StatusCode, resp.Body)
 	if err != nil {
@@ -2,10 +2,12 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/blevesearch/bleve/v2"
-	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/hashicorp/golang-lru/v2/expirable"
 	"github.com/rs/zerolog"
 	"golang.org/x/exp/slices"
 
@@ -13,34 +15,88 @@ import (
 	"github.com/etkecc/mrs/internal/utils"
 )
 
+var errEmptyQueryCursor = fmt.Errorf("%w: not a cursor of the empty query results", model.ErrInvalidCursor)
+
 // Search service
 type Search struct {
-	cfg   ConfigService
-	data  searchDataRepository
-	repo  SearchRepository
-	stats StatsService
-	block BlocklistService
+	cfg     ConfigService
+	data    searchDataRepository
+	repo    SearchRepository
+	stats   StatsService
+	block   BlocklistService
+	results *expirable.LRU[string, *searchResult]
+}
+
+// searchResult is a cached search result
+type searchResult struct {
+	entries   []*model.Entry
+	total     int
+	indexedAt time.Time // indexing finished at, when the result was cached
 }
 
 type searchDataRepository interface {
 	GetBiggestRooms(ctx context.Context, limit, offset int) []*model.MatrixRoom
+	GetRoomTags(ctx context.Context, roomID string) ([]string, error)
 }
 
-// SearchRepository interface
+// SearchRepository interface, implemented by the search backends.
+// Queries are backend-neutral specs, each backend translates them into its native queries
 type SearchRepository interface {
-	Search(ctx context.Context, searchQuery query.Query, limit, offset int, sortBy []string) ([]*model.Entry, int, error)
+	Search(ctx context.Context, searchQuery *model.Query, limit, offset int, sortBy, searchAfter []string) ([]*model.Entry, int, error)
+	Suggest(ctx context.Context, term string, fields []string, limit int) ([]string, error)
 }
 
 type StatsService interface {
 	Get() *model.IndexStats
 }
 
+// SuggestFields are fields used as a source of "did you mean" suggestions
+var SuggestFields = []string{"name", "alias"}
+
+// SearchFieldsAnalyzer field name => query analyzer,
+// required for fields with language-specific analyzers, to analyze query in the same way regardless of the room language
+var SearchFieldsAnalyzer = map[string]string{
+	"name":  "multilang",
+	"topic": "multilang",
+
+	"name.unicode":  "multilang_unicode",
+	"topic.unicode": "multilang_unicode",
+}
+
+// UnicodeFields field name => language-neutral copy of the field, that keeps emoji and isn't affected by the language detection,
+// matched along with the field itself
+var UnicodeFields = map[string]string{
+	"name":  "name.unicode",
+	"topic": "topic.unicode",
+}
+
+// ExactField is un-analyzed copy of the name field, used for quoted (exact match) queries
+const ExactField = "name.keyword"
+
+// SortAliases are shortcuts for the sort fields
+var SortAliases = map[string]string{
+	"recent": "-last_active", // recently active first, see model.MatrixRoom.LastActive
+	"new":    "-first_seen",  // recently discovered first, see model.MatrixRoom.FirstSeen
+}
+
+// MembersBoostSaturation is the joined members count of the full members boost (search.members_weight),
+// the text relevance score is multiplied by 1 + weight × min(1, log10(1 + members) / log10(1 + MembersBoostSaturation))
+const MembersBoostSaturation = 100000
+
+// FilterAliases are shortcuts for the key:value filter keys, e.g. tag:official.
+// Unlike other key:value pairs (e.g. language:EN, that boost the matching rooms), filters are required to match
+var FilterAliases = map[string]string{
+	"tag": "tags",
+}
+
 // SearchFieldsBoost field name => boost
 var SearchFieldsBoost = map[string]float64{
 	"language": 100,
 	"name":     10,
 	"server":   10,
 	"alias":    5,
+
+	ExactField: 100,
 }
 
 // NewSearch creates new search service
@@ -52,21 +108,41 @@ func NewSearch(cfg ConfigService, data searchDataRepository, repo SearchReposito
 		stats: stats,
 		block: block,
 	}
+	if cacheCfg := cfg.Get().Search.Cache; cacheCfg.Size > 0 {
+		s.results = expirable.NewLRU[string, *searchResult](cacheCfg.Size, nil, cacheCfg.GetTTL())
+	}
+	s.validateFields(utils.NewContext())
 
 	return s
 }
 
 // Search things
 // ref: https://blevesearch.com/docs/Query-String-Query/
-func (s *Search) Search(ctx context.Context, originServer, q, sortBy string, limit, offset int) ([]*model.Entry, int, error) {
+func (s *Search) Search(ctx context.Context, originServer, q, sortBy string, limit, offset int, opts model.SearchOptions) ([]*model.Entry, int, error) {
 	span := utils.StartSpan(ctx, "searchSvc.Search")
 	defer span.Finish()
 	log := zerolog.Ctx(span.Context())
+	if err := opts.Validate(); err != nil {
+		return nil, 0, err
+	}
+	cacheKey := strings.Join([]string{originServer, q, sortBy, strconv.Itoa(limit), strconv.Itoa(offset), opts.String()}, "\x00")
+	if cached, ok := s.getCached(cacheKey); ok {
+		if opts.Cursor != "" {
+			return s.addLinks(s.removeBlocked(cached.entries)), cached.total, nil
+		}
+		return s.addLinks(s.addHighlights(originServer, s.removeBlocked(cached.entries))), cached.total, nil
+	}
 	highlights := s.availableHighlights(originServer)
+	var searchAfter []string
+	if opts.Cursor != "" {
+		searchAfter, _ = model.DecodeCursor(opts.Cursor) //nolint:errcheck // validated above
+		highlights = 0                                   // highlights are shown on the first page only
+		offset = 0
+	}
 	if limit == 0 {
 		limit = s.cfg.Get().Search.Defaults.Limit
 	}
-	if offset == 0 {
+	if offset == 0 && searchAfter == nil {
 		offset = s.cfg.Get().Search.Defaults.Offset
 	}
 	limit -= highlights
@@ -78,21 +154,51 @@ func (s *Search) Search(ctx context.Context, originServer, q, sortBy string, lim
 		offset = 0
 	}
 
-	var builtQuery query.Query
-	if q == "" {
+	var builtQuery *model.Query
+	if q == "" && !opts.HasMembersRange() && !opts.HasFirstSeenRange() {
+		if searchAfter != nil { // see getEmptyQueryResults
+			position, err := strconv.Atoi(searchAfter[0])
+			if len(searchAfter) != 1 || err != nil || position < 0 {
+				return nil, 0, errEmptyQueryCursor
+			}
+			offset = position
+		}
 		entries, length := s.getEmptyQueryResults(span.Context(), limit, offset)
-		entries = s.addHighlights(originServer, entries)
-		return entries, length, nil
+		if searchAfter == nil {
+			entries = s.addHighlights(originServer, entries)
+		}
+		return s.addLinks(entries), length, nil
 	}
-	builtQuery = s.getSearchQuery(s.matchFields(q))
+	q, fields, exact := s.matchFields(q)
+	builtQuery = s.getSearchQuery(span.Context(), q, fields, exact, opts)
 	if builtQuery == nil {
 		return []*model.Entry{}, 0, nil
 	}
-	results, total, err := s.repo.Search(span.Context(), builtQuery, limit, offset, utils.StringToSlice(sortBy, s.cfg.Get().Search.Defaults.SortBy))
-	results = s.addHighlights(originServer, s.removeBlocked(results))
+	// explicit sort (e.g. -_score) keeps pure text relevance
+	if weight := s.cfg.Get().Search.MembersWeight; weight > 0 && sortBy == "" {
+		builtQuery = model.NewNumericBoostQuery(builtQuery, "members", weight, MembersBoostSaturation)
+	}
+	sortSlice := s.getSortBy(sortBy)
+	if searchAfter != nil && len(searchAfter) != len(sortSlice) { // cursor of another sort order
+		return nil, 0, fmt.Errorf("%w: %d sort values, sort order has %d", model.ErrInvalidCursor, len(searchAfter), len(sortSlice))
+	}
+	results, total, err := s.repo.Search(span.Context(), builtQuery, limit, offset, sortSlice, searchAfter)
+	results = s.removeBlocked(results)
+	if err == nil {
+		s.setCached(cacheKey, results, total)
+	}
+	if searchAfter == nil {
+		results = s.addHighlights(originServer, results)
+	}
+	span.SetData("query.length", len(q))
+	span.SetData("limit", limit)
+	span.SetData("offset", offset)
+	span.SetData("hits", len(results))
+	span.SetData("total", total)
 	log.Info().
 		Err(err).
 		Str("query", q).
+		Str("options", opts.String()).
 		Int("limit", limit).
 		Int("offset", offset).
 		Int("results", len(results)).
@@ -102,7 +208,155 @@ func (s *Search) Search(ctx context.Context, originServer, q, sortBy string, lim
 		return nil, 0, err
 	}
 
-	return results, total, nil
+	return s.addLinks(results), total, nil
+}
+
+// Suggest returns "did you mean" suggestion for the query, based on the indexed terms.
+// Returns empty string if suggestions are disabled or nothing better was found
+func (s *Search) Suggest(ctx context.Context, q string) string {
+	if !s.cfg.Get().Search.Suggestions {
+		return ""
+	}
+	span := utils.StartSpan(ctx, "searchSvc.Suggest")
+	defer span.Finish()
+	log := zerolog.Ctx(span.Context())
+
+	q, _, exact := s.matchFields(q)
+	q = strings.Join(append(exact, q), " ")
+	words := strings.Fields(strings.ToLower(q))
+	if len(words) == 0 {
+		return ""
+	}
+
+	var changed bool
+	for i, word := range words {
+		terms, err := s.repo.Suggest(span.Context(), word, SuggestFields, 1)
+		if err != nil {
+			log.Warn().Err(err).Str("word", word).Msg("cannot get suggestions")
+			return ""
+		}
+		if len(terms) == 0 {
+			continue
+		}
+		words[i] = terms[0]
+		changed = true
+	}
+	if !changed {
+		return ""
+	}
+
+	return strings.Join(words, " ")
+}
+
+// validateFields warns about unknown fields in the search.fields config, they are ignored
+func (s *Search) validateFields(ctx context.Context) {
+	log := zerolog.Ctx(ctx)
+	fieldsCfg := s.cfg.Get().Search.Fields
+	check := func(lang string, fields []string) {
+		for _, field := range fields {
+			if !slices.Contains(model.SearchFields, field) {
+				log.Warn().Str("field", field).Str("language", lang).Msg("unknown field in search.fields config, ignoring")
+			}
+		}
+	}
+	check("", fieldsCfg.Default)
+	for lang, fields := range fieldsCfg.Languages {
+		check(lang, fields)
+	}
+}
+
+// getSearchFields returns configured fields for full-text search, for the given language (if any).
+// Unknown fields are ignored, nil means all model.SearchFields
+func (s *Search) getSearchFields(lang string) []string {
+	fieldsCfg := s.cfg.Get().Search.Fields
+	fields := fieldsCfg.Default
+	for cfgLang, langFields := range fieldsCfg.Languages {
+		if lang != "" && strings.EqualFold(cfgLang, lang) {
+			fields = langFields
+			break
+		}
+	}
+
+	known := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if slices.Contains(model.SearchFields, field) {
+			known = append(known, field)
+		}
+	}
+	if len(known) == 0 {
+		return nil
+	}
+	return known
+}
+
+// getSortBy returns sort order with room ID as the last (tie-breaking) criteria,
+// to keep order of rooms with the same score/members stable across pages
+func (s *Search) getSortBy(sortBy string) []string {
+	sortSlice := utils.StringToSlice(sortBy, s.cfg.Get().Search.Defaults.SortBy)
+	for i, field := range sortSlice {
+		if alias, ok := SortAliases[field]; ok {
+			sortSlice[i] = alias
+		}
+	}
+	for _, field := range sortSlice {
+		if strings.TrimPrefix(field, "-") == "_id" {
+			return sortSlice
+		}
+	}
+	return append(sortSlice, "_id")
+}
+
+// Purge drops all cached search results, must be called when rooms are removed from the index outside of ingest (e.g., ban)
+func (s *Search) Purge() {
+	if s.results == nil {
+		return
+	}
+	s.results.Purge()
+}
+
+// getCached returns copy of the cached search result, if it was cached after the last indexing
+func (s *Search) getCached(key string) (*searchResult, bool) {
+	if s.results == nil {
+		return nil, false
+	}
+	cached, ok := s.results.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if !cached.indexedAt.Equal(s.stats.Get().Indexing.FinishedAt) { // new index since then
+		s.results.Remove(key)
+		return nil, false
+	}
+	return &searchResult{
+		entries:   copyEntries(cached.entries),
+		total:     cached.total,
+		indexedAt: cached.indexedAt,
+	}, true
+}
+
+// setCached stores copy of the search result in the cache
+func (s *Search) setCached(key string, entries []*model.Entry, total int) {
+	if s.results == nil {
+		return
+	}
+	s.results.Add(key, &searchResult{
+		entries:   copyEntries(entries),
+		total:     total,
+		indexedAt: s.stats.Get().Indexing.FinishedAt,
+	})
+}
+
+// copyEntries returns copy of the entries, so the cached entries are never changed by the callers
+func copyEntries(entries []*model.Entry) []*model.Entry {
+	if entries == nil {
+		return nil
+	}
+	copied := make([]*model.Entry, 0, len(entries))
+	for _, entry := range entries {
+		entryCopy := *entry
+		copied = append(copied, &entryCopy)
+	}
+	return copied
 }
 
 func (s *Search) availableHighlights(originServer string) int {
@@ -120,6 +374,7 @@ func (s *Search) availableHighlights(originServer string) int {
 	return count
 }
 
+// addHighlights inserts highlighted rooms of the origin server into the entries, the passed entries slice is not modified
 func (s *Search) addHighlights(originServer string, entries []*model.Entry) []*model.Entry {
 	if len(entries) == 0 {
 		return entries
@@ -132,24 +387,52 @@ func (s *Search) addHighlights(originServer string, entries []*model.Entry) []*m
 		}
 
 		entry := highlight.Entry()
+		if entry.AvatarURL == "" {
+			entry.AvatarURL = model.AvatarURL(s.cfg.Get().Public.API, entry.Avatar)
+		}
 		if highlight.Position < 0 || highlight.Position > len(entries) {
-			entries = append(entries, entry)
+			entries = append(entries[:len(entries):len(entries)], entry)
 			continue
 		}
-		entries = append(entries[:highlight.Position], append([]*model.Entry{entry}, entries[highlight.Position:]...)...)
+		entries = slices.Insert(slices.Clip(entries), highlight.Position, entry)
 	}
 
 	return entries
 }
 
+// addLinks sets deep links of the entries, must be called on the copies of the cached entries only
+func (s *Search) addLinks(entries []*model.Entry) []*model.Entry {
+	baseURL := s.cfg.Get().Public.Links
+	for _, entry := range entries {
+		entry.Link = entry.DeepLink(baseURL)
+	}
+	return entries
+}
+
+// getEmptyQueryResults returns page of the biggest rooms list. Sort value of each entry is its 1-based position in the list,
+// so cursor of the next page (see model.EncodeCursor) is the offset of that page
 func (s *Search) getEmptyQueryResults(ctx context.Context, limit, offset int) (entries []*model.Entry, length int) {
 	rooms := s.data.GetBiggestRooms(ctx, limit, offset)
 	entries = make([]*model.Entry, 0, len(rooms))
-	for _, room := range rooms {
-		entries = append(entries, room.Entry())
+	log := zerolog.Ctx(ctx)
+	for i, room := range rooms {
+		entry := room.Entry()
+		entry.Sort = []string{strconv.Itoa(offset + i + 1)}
+		tags, err := s.data.GetRoomTags(ctx, room.ID)
+		if err != nil {
+			log.Warn().Err(err).Str("id", room.ID).Msg("cannot get room tags")
+		}
+		entry.Tags = tags
+		entries = append(entries, entry)
+	}
+
+	// total is an estimate, because the biggest rooms list is built from all parsed rooms
+	length = s.stats.Get().Rooms.Indexed
+	if minLength := offset + len(entries); length < minLength {
+		length = minLength
 	}
 
-	return entries, len(entries)
+	return entries, length
 }
 
 // removeBlocked removes results from blocked servers from the search results
@@ -170,9 +453,11 @@ func (s *Search) removeBlocked(results []*model.Entry) []*model.Entry {
 	return allowed
 }
 
-func (s *Search) matchFields(queryStr string) (sanitizedQuery string, fields map[string]string) {
+// matchFields extracts quoted (exact match) spans and key:value pairs from the query
+func (s *Search) matchFields(queryStr string) (sanitizedQuery string, fields map[string]string, exact []string) {
+	queryStr, exact = s.matchExact(queryStr)
 	if !strings.Contains(queryStr, ":") { // if no key:value pair(-s) - nothing is here
-		return queryStr, nil
+		return queryStr, nil, exact
 	}
 	fields = map[string]string{}
 	parts := strings.Split(queryStr, " ") // e.g. "language:EN foss"
@@ -195,39 +480,100 @@ func (s *Search) matchFields(queryStr string) (sanitizedQuery string, fields map
 	}
 	queryStr = strings.TrimSpace(queryStr)
 
-	return queryStr, fields
+	return queryStr, fields, exact
 }
 
-type bleveQuery interface {
-	query.Query
-	SetField(string)
-	SetBoost(float64)
+// ValidateQuery checks the key:value filters syntax of the query, e.g. `language:` is malformed,
+// because it would be silently ignored otherwise. Only known keys (see isFilterKey) are checked,
+// so words with a colon, like `:)` or `http:`, are allowed
+func (s *Search) ValidateQuery(queryStr string) error {
+	queryStr, _ = s.matchExact(queryStr)
+	malformed := []string{}
+	for _, part := range strings.Fields(queryStr) {
+		key, value, ok := strings.Cut(part, ":")
+		if !ok || !isFilterKey(key) {
+			continue
+		}
+		if strings.TrimSpace(value) == "" {
+			malformed = append(malformed, part)
+		}
+	}
+	if len(malformed) > 0 {
+		return fmt.Errorf("malformed key:value filters (both key and value are required, e.g. language:EN): %s", strings.Join(malformed, ", "))
+	}
+	return nil
+}
+
+// isFilterKey checks if the key of the key:value pair is a known filter: filter alias, searchable or boosted field
+func isFilterKey(key string) bool {
+	if _, ok := FilterAliases[key]; ok {
+		return true
+	}
+	if _, ok := SearchFieldsBoost[key]; ok {
+		return true
+	}
+	return slices.Contains(model.SearchFields, key)
 }
 
-func (s *Search) newMatchQuery(match, field string, phrase bool) bleveQuery {
-	var searchQuery bleveQuery
+// matchExact extracts quoted spans from the query, e.g. `"Rust Programming" chat`
+func (s *Search) matchExact(queryStr string) (sanitizedQuery string, exact []string) {
+	if strings.Count(queryStr, `"`) < 2 {
+		return queryStr, nil
+	}
+
+	var sanitized strings.Builder
+	for {
+		start := strings.Index(queryStr, `"`)
+		if start == -1 {
+			break
+		}
+		end := strings.Index(queryStr[start+1:], `"`)
+		if end == -1 { // unpaired quote
+			break
+		}
+		end += start + 1
+		sanitized.WriteString(queryStr[:start])
+		sanitized.WriteString(" ")
+		if span := strings.TrimSpace(queryStr[start+1 : end]); span != "" {
+			exact = append(exact, span)
+		}
+		queryStr = queryStr[end+1:]
+	}
+	sanitized.WriteString(queryStr)
+
+	return strings.Join(strings.Fields(sanitized.String()), " "), exact
+}
+
+func (s *Search) newMatchQuery(match, field string, phrase bool) *model.Query {
+	kind := model.QueryMatch
 	if phrase {
-		searchQuery = bleve.NewMatchPhraseQuery(match)
-	} else {
-		searchQuery = bleve.NewMatchQuery(match)
+		kind = model.QueryPhrase
 	}
-	searchQuery.SetField(field)
-	searchQuery.SetBoost(SearchFieldsBoost[field])
+	searchQuery := model.NewFieldQuery(kind, field, match, SearchFieldsBoost[field])
+	searchQuery.Analyzer = SearchFieldsAnalyzer[field]
 
 	return searchQuery
 }
 
-func (s *Search) newFuzzyQuery(match, field string) bleveQuery {
-	searchQuery := bleve.NewFuzzyQuery(match)
-	searchQuery.SetField(field)
-	searchQuery.SetBoost(SearchFieldsBoost[field])
+// newExactQuery matches whole (lowercased, but otherwise not analyzed) room name
+func (s *Search) newExactQuery(match string) *model.Query {
+	return model.NewFieldQuery(model.QueryTerm, ExactField, strings.ToLower(match), SearchFieldsBoost[ExactField])
+}
 
-	return searchQuery
+func (s *Search) newFuzzyQuery(match, field string) *model.Query {
+	return model.NewFieldQuery(model.QueryFuzzy, field, match, SearchFieldsBoost[field])
 }
 
-// shouldReject checks if query or fields contain words from the stoplist
-func (s *Search) shouldReject(q string, fields map[string]string) bool {
+// shouldReject checks if query, fields, or exact match spans contain words from the stoplist
+func (s *Search) shouldReject(q string, fields map[string]string, exact []string) bool {
 	stopwords := s.cfg.Get().Blocklist.Queries
+	for _, span := range exact {
+		for _, k := range strings.Fields(span) {
+			if slices.Contains(stopwords, k) {
+				return true
+			}
+		}
+	}
 	for k, v := range fields {
 		if slices.Contains(stopwords, k) {
 			return true
@@ -244,34 +590,130 @@ func (s *Search) shouldReject(q string, fields map[string]string) bool {
 	return false
 }
 
-func (s *Search) getSearchQuery(q string, fields map[string]string) query.Query {
+// getTermQueries returns queries matching the term across the searchable fields
+func (s *Search) getTermQueries(term string, phrase bool, opts model.SearchOptions) []*model.Query {
+	searchFields := opts.Fields
+	if len(searchFields) == 0 {
+		searchFields = model.SearchFields
+	}
+
+	fuzzyQueries := make([]*model.Query, 0, len(searchFields))
+	matchQueries := make([]*model.Query, 0, len(searchFields))
+	for _, field := range searchFields {
+		fuzzyQuery := s.newFuzzyQuery(term, field)
+		matchQuery := s.newMatchQuery(term, field, phrase)
+		if boost, ok := opts.Boost[field]; ok {
+			fuzzyQuery.Boost = boost
+			matchQuery.Boost = boost
+		}
+		fuzzyQueries = append(fuzzyQueries, fuzzyQuery)
+		matchQueries = append(matchQueries, matchQuery)
+
+		if unicodeField, ok := UnicodeFields[field]; ok {
+			unicodeQuery := s.newMatchQuery(term, unicodeField, phrase)
+			unicodeQuery.Boost = SearchFieldsBoost[field]
+			if boost, ok := opts.Boost[field]; ok {
+				unicodeQuery.Boost = boost
+			}
+			matchQueries = append(matchQueries, unicodeQuery)
+		}
+	}
+
+	return append(fuzzyQueries, matchQueries...)
+}
+
+func (s *Search) getSearchQuery(ctx context.Context, q string, fields map[string]string, exact []string, opts model.SearchOptions) *model.Query {
+	span := utils.StartSpan(ctx, "searchSvc.getSearchQuery")
+	defer span.Finish()
+	span.SetData("query.length", len(q))
+	span.SetData("query.fields", len(fields))
+	span.SetData("query.exact", len(exact))
+
 	// base/standard query
 	q = strings.TrimSpace(q)
-	if s.shouldReject(q, fields) {
+	if s.shouldReject(q, fields, exact) {
 		return nil
 	}
+	if len(opts.Fields) == 0 { // not restricted by the request
+		opts.Fields = s.getSearchFields(fields["language"])
+	}
 
-	phrase := strings.Contains(q, " ")
-	queries := []query.Query{
-		s.newFuzzyQuery(q, "name"),
-		s.newFuzzyQuery(q, "alias"),
-		s.newFuzzyQuery(q, "topic"),
-		s.newFuzzyQuery(q, "server"),
+	var queries []*model.Query
+	words := strings.Fields(q)
+	switch {
+	case q == "":
+		// only exact match spans and/or fields
+	case opts.Mode == model.SearchModeAnd && len(words) > 1:
+		// each word must be present in any of the searchable fields
+		terms := make([]*model.Query, 0, len(words))
+		for _, word := range words {
+			terms = append(terms, model.NewOrQuery(s.getTermQueries(word, false, opts)...))
+		}
+		queries = []*model.Query{model.NewAndQuery(terms...)}
+	default:
+		queries = s.getTermQueries(q, strings.Contains(q, " "), opts)
+	}
 
-		s.newMatchQuery(q, "name", phrase),
-		s.newMatchQuery(q, "alias", phrase),
-		s.newMatchQuery(q, "topic", phrase),
-		s.newMatchQuery(q, "server", phrase),
+	// quoted spans, like `"Rust Programming"` - exact name matches are pinned to the top,
+	// phrase matches (across the searchable fields) are kept as well
+	for _, span := range exact {
+		queries = append(queries, s.newExactQuery(span))
+		queries = append(queries, s.getTermQueries(span, strings.Contains(span, " "), opts)...)
 	}
 
+	// required filters, like "tag:official", and ranges
+	filters := []*model.Query{}
 	// optional fields, like "language:EN"
-	if len(fields) > 0 {
-		boolQ := bleve.NewBooleanQuery()
-		for field, fieldQ := range fields {
-			boolQ.AddMust(s.newMatchQuery(fieldQ, field, false))
+	fieldQueries := make([]*model.Query, 0, len(fields))
+	for field, fieldQ := range fields {
+		if alias, ok := FilterAliases[field]; ok {
+			filters = append(filters, s.newMatchQuery(fieldQ, alias, false))
+			continue
 		}
-		queries = append(queries, boolQ)
+		fieldQueries = append(fieldQueries, s.newMatchQuery(fieldQ, field, false))
+	}
+	if len(fieldQueries) > 0 {
+		queries = append(queries, model.NewAndQuery(fieldQueries...))
+	}
+	if membersQ := s.getMembersQuery(opts); membersQ != nil {
+		filters = append(filters, membersQ)
 	}
+	if firstSeenQ := s.getFirstSeenQuery(opts); firstSeenQ != nil {
+		filters = append(filters, firstSeenQ)
+	}
+	switch {
+	case len(queries) == 0 && len(filters) == 0:
+		return nil
+	case len(queries) == 0:
+		return model.NewAndQuery(filters...)
+	case len(filters) == 0:
+		return model.NewOrQuery(queries...)
+	default:
+		return model.NewAndQuery(append([]*model.Query{model.NewOrQuery(queries...)}, filters...)...)
+	}
+}
 
-	return bleve.NewDisjunctionQuery(queries...)
+// getFirstSeenQuery returns first seen time range query, if requested
+func (s *Search) getFirstSeenQuery(opts model.SearchOptions) *model.Query {
+	if !opts.HasFirstSeenRange() {
+		return nil
+	}
+	return &model.Query{Kind: model.QueryDateRange, Field: "first_seen", After: opts.SeenAfter, Before: opts.SeenBefore}
+}
+
+// getMembersQuery returns joined members range query, if requested
+func (s *Search) getMembersQuery(opts model.SearchOptions) *model.Query {
+	if !opts.HasMembersRange() {
+		return nil
+	}
+	var minMembers, maxMembers *float64
+	if opts.MinMembers > 0 {
+		value := float64(opts.MinMembers)
+		minMembers = &value
+	}
+	if opts.MaxMembers > 0 {
+		value := float64(opts.MaxMembers)
+		maxMembers = &value
+	}
+	return &model.Query{Kind: model.QueryNumericRange, Field: "members", Min: minMembers, Max: maxMembers}
 }
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/etkecc/mrs/internal/model"
+)
+
+// fakeSearchRepo returns the entries for any query, recording the last query
+type fakeSearchRepo struct {
+	entries []*model.Entry
+	query   *model.Query
+	calls   int
+}
+
+func (r *fakeSearchRepo) Search(_ context.Context, searchQuery *model.Query, _, _ int, _, _ []string) ([]*model.Entry, int, error) {
+	r.query = searchQuery
+	r.calls++
+	entries := make([]*model.Entry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		entryCopy := *entry
+		entries = append(entries, &entryCopy)
+	}
+	return entries, len(entries), nil
+}
+
+func (r *fakeSearchRepo) Suggest(context.Context, string, []string, int) ([]string, error) {
+	return nil, nil
+}
+
+type nopBlocklist struct{}
+
+func (nopBlocklist) Add(string)           {}
+func (nopBlocklist) ByID(string) bool     { return false }
+func (nopBlocklist) ByServer(string) bool { return false }
+func (nopBlocklist) Slice() []string      { return nil }
+func (nopBlocklist) Reset()               {}
+
+type fakeStats struct {
+	stats *model.IndexStats
+}
+
+func (s *fakeStats) Get() *model.IndexStats {
+	return s.stats
+}
+
+func newTestSearch(search *model.ConfigSearch, repo SearchRepository, data searchDataRepository) *Search {
+	cfg := &fakeConfig{cfg: &model.Config{
+		Public:    &model.ConfigPublic{API: "https://api.example.com"},
+		Search:    search,
+		Blocklist: &model.ConfigBlocklist{},
+	}}
+	return NewSearch(cfg, data, repo, nopBlocklist{}, &fakeStats{stats: &model.IndexStats{}})
+}
+
+func TestSearch_CacheIsNotMutated(t *testing.T) {
+	repo := &fakeSearchRepo{entries: []*model.Entry{
+		{ID: "!a:example.com", Name: "a"},
+		{ID: "!b:example.com", Name: "b"},
+		{ID: "!c:example.com", Name: "c"},
+	}}
+	search := newTestSearch(&model.ConfigSearch{
+		Cache: model.ConfigSearchCache{Size: 10, TTL: 60},
+		Highlights: []*model.ConfigSearchHighlight{
+			{Position: 1, Servers: []string{"origin.com"}, ID: "!highlight:example.com", Name: "highlight"},
+		},
+	}, repo, nil)
+
+	for n := range 3 {
+		entries, _, err := search.Search(context.Background(), "origin.com", "test", "", 10, 0, model.SearchOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			ids = append(ids, entry.ID)
+		}
+		expected := []string{"!a:example.com", "!highlight:example.com", "!b:example.com", "!c:example.com"}
+		if len(ids) != len(expected) {
+			t.Fatalf("request %d: got %v, want %v", n, ids, expected)
+		}
+		for i := range expected {
+			if ids[i] != expected[i] {
+				t.Fatalf("request %d: got %v, want %v", n, ids, expected)
+			}
+		}
+		// callers may change the returned entries, that must not affect the cache
+		entries[0].Name = "changed"
+	}
+	if repo.calls != 1 {
+		t.Errorf("search repository calls: got %d, want 1 (cached)", repo.calls)
+	}
+
+	entries, _, _ := search.Search(context.Background(), "origin.com", "test", "", 10, 0, model.SearchOptions{}) //nolint:errcheck // checked above
+	if len(entries) != 4 || entries[0].Name != "a" {
+		t.Errorf("cached entries have been changed: %+v", entries[0])
+	}
+}
+
+// fakeSearchData returns the rooms as the biggest ones, with tags
+type fakeSearchData struct {
+	rooms []*model.MatrixRoom
+	tags  map[string][]string
+}
+
+func (d *fakeSearchData) GetBiggestRooms(_ context.Context, limit, offset int) []*model.MatrixRoom {
+	if offset >= len(d.rooms) {
+		return nil
+	}
+	return d.rooms[offset:min(offset+limit, len(d.rooms))]
+}
+
+func (d *fakeSearchData) GetRoomTags(_ context.Context, roomID string) ([]string, error) {
+	return d.tags[roomID], nil
+}
+
+func TestSearch_Purge(t *testing.T) {
+	repo := &fakeSearchRepo{entries: []*model.Entry{{ID: "!a:example.com", Name: "a"}}}
+	search := newTestSearch(&model.ConfigSearch{Cache: model.ConfigSearchCache{Size: 10}}, repo, nil)
+	for range 2 {
+		if _, _, err := search.Search(context.Background(), "", "test", "", 10, 0, model.SearchOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if repo.calls != 1 {
+		t.Fatalf("search repository calls: got %d, want 1 (cached)", repo.calls)
+	}
+
+	search.Purge() // e.g., a room is banned
+	if _, _, err := search.Search(context.Background(), "", "test", "", 10, 0, model.SearchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if repo.calls != 2 {
+		t.Errorf("search repository calls after purge: got %d, want 2", repo.calls)
+	}
+}
+
+func TestSearch_TagFilterIsRequired(t *testing.T) {
+	repo := &fakeSearchRepo{}
+	search := newTestSearch(&model.ConfigSearch{}, repo, nil)
+	if _, _, err := search.Search(context.Background(), "", "rust tag:official", "", 10, 0, model.SearchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	q := repo.query
+	if q == nil || q.Kind != model.QueryAnd || len(q.Queries) != 2 {
+		t.Fatalf("expected AND of the text query and the tag filter, got %+v", q)
+	}
+	if q.Queries[0].Kind != model.QueryOr {
+		t.Errorf("text query: got %q, want %q", q.Queries[0].Kind, model.QueryOr)
+	}
+	if tag := q.Queries[1]; tag.Field != "tags" || tag.Value != "official" {
+		t.Errorf("tag filter: got %+v", tag)
+	}
+
+	// language is a preference, not a filter
+	if _, _, err := search.Search(context.Background(), "", "rust language:EN", "", 10, 0, model.SearchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if repo.query.Kind != model.QueryOr {
+		t.Errorf("language query: got %q, want %q", repo.query.Kind, model.QueryOr)
+	}
+}
+
+func TestSearch_EmptyQueryTags(t *testing.T) {
+	data := &fakeSearchData{
+		rooms: []*model.MatrixRoom{{ID: "!a:example.com", Name: "a"}, {ID: "!b:example.com", Name: "b"}},
+		tags:  map[string][]string{"!a:example.com": {"official"}},
+	}
+	search := newTestSearch(&model.ConfigSearch{}, &fakeSearchRepo{}, data)
+	entries, _, err := search.Search(context.Background(), "", "", "", 10, 0, model.SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if len(entries[0].Tags) != 1 || entries[0].Tags[0] != "official" {
+		t.Errorf("tags of the tagged room: got %v", entries[0].Tags)
+	}
+	if len(entries[1].Tags) != 0 {
+		t.Errorf("tags of the untagged room: got %v", entries[1].Tags)
+	}
+}
+
+func TestSearch_EmptyQueryCursor(t *testing.T) {
+	data := &fakeSearchData{rooms: []*model.MatrixRoom{
+		{ID: "!a:example.com"}, {ID: "!b:example.com"}, {ID: "!c:example.com"}, {ID: "!d:example.com"}, {ID: "!e:example.com"},
+	}}
+	search := newTestSearch(&model.ConfigSearch{}, &fakeSearchRepo{}, data)
+
+	var ids []string
+	var cursor string
+	for range 3 {
+		entries, _, err := search.Search(context.Background(), "", "", "", 2, 0, model.SearchOptions{Cursor: cursor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			ids = append(ids, entry.ID)
+		}
+		if len(entries) > 0 {
+			cursor = model.EncodeCursor(entries[len(entries)-1].Sort)
+		}
+	}
+	expected := []string{"!a:example.com", "!b:example.com", "!c:example.com", "!d:example.com", "!e:example.com"}
+	if !slices.Equal(ids, expected) {
+		t.Errorf("got %v, want %v", ids, expected)
+	}
+
+	invalid := model.SearchOptions{Cursor: model.EncodeCursor([]string{"prefix-coded", "_id"})}
+	if _, _, err := search.Search(context.Background(), "", "", "", 2, 0, invalid); !errors.Is(err, model.ErrInvalidCursor) {
+		t.Errorf("cursor of the text query results: got %v, want %v", err, model.ErrInvalidCursor)
+	}
+}
+
+func TestSearch_ShortCursor(t *testing.T) {
+	repo := &fakeSearchRepo{entries: []*model.Entry{{ID: "!a:example.com", Name: "test"}}}
+	search := newTestSearch(&model.ConfigSearch{}, repo, nil)
+
+	short := model.SearchOptions{Cursor: model.EncodeCursor([]string{"1.0"})} // hand-crafted, sort order is -_score,_id
+	if _, _, err := search.Search(context.Background(), "", "test", "-_score", 2, 0, short); !errors.Is(err, model.ErrInvalidCursor) {
+		t.Errorf("got %v, want %v", err, model.ErrInvalidCursor)
+	}
+	if repo.calls != 0 {
+		t.Errorf("search repository is called with the short cursor")
+	}
+
+	valid := model.SearchOptions{Cursor: model.EncodeCursor([]string{"1.0", "!a:example.com"})}
+	if _, _, err := search.Search(context.Background(), "", "test", "-_score", 2, 0, valid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearch_Links(t *testing.T) {
+	repo := &fakeSearchRepo{entries: []*model.Entry{
+		{ID: "!a:example.com", Alias: "#a:example.com", Name: "a"},
+		{ID: "!b:example.com", Name: "b"},
+	}}
+	data := &fakeSearchData{rooms: []*model.MatrixRoom{{ID: "!c:example.com", Name: "c"}}}
+	search := newTestSearch(&model.ConfigSearch{Cache: model.ConfigSearchCache{Size: 10, TTL: 60}}, repo, data)
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{"query", "test", []string{"https://matrix.to/#/#a:example.com", "https://matrix.to/#/!b:example.com?via=example.com"}},
+		{"cached query", "test", []string{"https://matrix.to/#/#a:example.com", "https://matrix.to/#/!b:example.com?via=example.com"}},
+		{"empty query", "", []string{"https://matrix.to/#/!c:example.com?via=example.com"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, _, err := search.Search(context.Background(), "", test.query, "", 10, 0, model.SearchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(test.expected) {
+				t.Fatalf("got %d entries, want %d", len(entries), len(test.expected))
+			}
+			for n, entry := range entries {
+				if entry.Link != test.expected[n] {
+					t.Errorf("link of %s: got %q, want %q", entry.ID, entry.Link, test.expected[n])
+				}
+			}
+		})
+	}
+	if repo.calls != 1 {
+		t.Errorf("repository calls: got %d, want 1 (second query is cached)", repo.calls)
+	}
+}
+
+func TestValidateQuery(t *testing.T) {
+	search := newTestSearch(&model.ConfigSearch{}, &fakeSearchRepo{}, nil)
+	tests := []struct {
+		query string
+		valid bool
+	}{
+		{"rust", true},
+		{"rust language:EN", true},
+		{"rust tag:official", true},
+		{"smile :)", true},
+		{"http: links", true},
+		{"http://example.com", true},
+		{`"language:" quoted`, true},
+		{"rust language:", false},
+		{"tag:", false},
+		{"server: name:", false},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			err := search.ValidateQuery(test.query)
+			if (err == nil) != test.valid {
+				t.Errorf("valid: got %v, want %v (%v)", err == nil, test.valid, err)
+			}
+		})
+	}
+}
@@ -1,16 +1,13 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
-	"github.com/goccy/go-json"
 	"github.com/rs/zerolog"
 
 	"github.com/etkecc/mrs/internal/metrics"
@@ -18,10 +15,14 @@ import (
 	"github.com/etkecc/mrs/internal/utils"
 )
 
+// tlFullResolution is the period of the stats timeline kept without downsampling
+const tlFullResolution = 30 * 24 * time.Hour
+
 type StatsRepository interface {
 	DataRepository
 	GetIndexStatsTL(ctx context.Context, prefix string) (map[time.Time]*model.IndexStats, error)
 	SetIndexStatsTL(ctx context.Context, calculatedAt time.Time, stats *model.IndexStats) error
+	ReplaceIndexStatsTL(ctx context.Context, statsTL map[time.Time]*model.IndexStats) error
 	GetIndexStats(ctx context.Context) *model.IndexStats
 	SetIndexOnlineServers(ctx context.Context, servers int) error
 	SetIndexIndexableServers(ctx context.Context, servers int) error
@@ -86,6 +87,21 @@ func (s *Stats) GetTL(ctx context.Context) map[time.Time]*model.IndexStats {
 	return tl
 }
 
+// Diff returns the difference between two most recent stats timeline entries,
+// or nil if there are less than two entries
+func (s *Stats) Diff(ctx context.Context) *model.IndexStatsDiff {
+	tl := s.GetTL(ctx)
+	if len(tl) < 2 {
+		return nil
+	}
+	keys := utils.MapKeys(tl)
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Before(keys[j])
+	})
+	from, to := keys[len(keys)-2], keys[len(keys)-1]
+	return model.NewIndexStatsDiff(from, tl[from], to, tl[to])
+}
+
 // SetStartedAt of the process
 func (s *Stats) SetStartedAt(ctx context.Context, process string, startedAt time.Time) {
 	if err := s.data.SetStartedAt(ctx, process, startedAt); err != nil {
@@ -179,9 +195,50 @@ func (s *Stats) Collect(ctx context.Context) {
 	if err := s.data.SetIndexStatsTL(span.Context(), time.Now().UTC(), s.stats); err != nil {
 		log.Error().Err(err).Msg("cannot set stats timeline")
 	}
+	s.compactTL(span.Context())
 	s.sendWebhook(span.Context())
 }
 
+// compactTL downsamples the stats timeline: entries of the last tlFullResolution are kept as is,
+// older entries are thinned to the latest one per day
+func (s *Stats) compactTL(ctx context.Context) {
+	span := utils.StartSpan(ctx, "stats.compactTL")
+	defer span.Finish()
+	log := zerolog.Ctx(span.Context())
+
+	tl, err := s.data.GetIndexStatsTL(span.Context(), "")
+	if err != nil {
+		log.Error().Err(err).Msg("cannot get stats timeline")
+		return
+	}
+
+	cutoff := time.Now().UTC().Add(-tlFullResolution)
+	days := map[string]time.Time{} // day => latest entry of the day
+	compacted := make(map[time.Time]*model.IndexStats, len(tl))
+	for calculatedAt, stats := range tl {
+		if calculatedAt.After(cutoff) {
+			compacted[calculatedAt] = stats
+			continue
+		}
+		day := calculatedAt.UTC().Format(time.DateOnly)
+		if latest, ok := days[day]; !ok || calculatedAt.After(latest) {
+			days[day] = calculatedAt
+		}
+	}
+	for _, calculatedAt := range days {
+		compacted[calculatedAt] = tl[calculatedAt]
+	}
+	if len(compacted) == len(tl) {
+		return
+	}
+
+	if err := s.data.ReplaceIndexStatsTL(span.Context(), compacted); err != nil {
+		log.Error().Err(err).Msg("cannot compact stats timeline")
+		return
+	}
+	log.Info().Int("before", len(tl)).Int("after", len(compacted)).Msg("stats timeline has been compacted")
+}
+
 // sendWebhook send request to webhook if provided
 func (s *Stats) sendWebhook(ctx context.Context) {
 	if s.cfg.Get().Webhooks.Stats == "" {
@@ -197,30 +254,11 @@ func (s *Stats) sendWebhook(ctx context.Context) {
 		user = parsedUIURL.Hostname()
 	}
 
-	payload, err := json.Marshal(webhookPayload{
+	if err := postWebhook(span.Context(), s.cfg.Get().Webhooks.Stats, webhookPayload{
 		Username: user,
 		Markdown: s.getWebhookText(),
-	})
-	if err != nil {
-		log.Error().Err(err).Msg("webhook payload marshaling failed")
-		return
-	}
-
-	req, err := http.NewRequest(http.MethodPost, s.cfg.Get().Webhooks.Stats, bytes.NewReader(payload))
-	if err != nil {
-		log.Error().Err(err).Msg("webhook request failed")
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
+	}); err != nil {
 		log.Error().Err(err).Msg("webhook sending failed")
-		return
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
-		body, err := io.ReadAll(resp.Body)
-		log.Error().Err(err).Int("status_code", resp.StatusCode).Str("body", string(body)).Msg("webhook sending failed")
 	}
 }
 
@@ -2,15 +2,22 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/rs/zerolog"
 
+	"github.com/etkecc/mrs/internal/metrics"
 	"github.com/etkecc/mrs/internal/model"
+	"github.com/etkecc/mrs/internal/utils"
 )
 
+// deniedExamples is the number of dropped rooms logged per topics denylist pattern
+const deniedExamples = 5
+
 // based on W3C email regex, ref: https://www.w3.org/TR/2016/REC-html51-20161101/sec-forms.html#email-state-typeemail
 var domainRegex = regexp.MustCompile(`^(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z0-9][a-zA-Z0-9-]{0,61}[a-zA-Z0-9]$`)
 
@@ -20,22 +27,44 @@ type Validator struct {
 	block  BlocklistService
 	matrix FederationService
 	robots RobotsService
+
+	mu     *sync.Mutex
+	topics []*regexp.Regexp
+	denied map[string]int // topics denylist pattern -> matches
 }
 
 // NewValidator creates new validation service
 func NewValidator(cfg ConfigService, block BlocklistService, matrix FederationService, robots RobotsService) *Validator {
-	return &Validator{
+	v := &Validator{
 		cfg:    cfg,
 		block:  block,
 		matrix: matrix,
 		robots: robots,
+		mu:     &sync.Mutex{},
+		denied: map[string]int{},
+	}
+	v.compileTopics(utils.NewContext())
+
+	return v
+}
+
+// compileTopics compiles topics denylist patterns, invalid patterns are skipped
+func (v *Validator) compileTopics(ctx context.Context) {
+	log := zerolog.Ctx(ctx)
+	for _, pattern := range v.cfg.Get().Blocklist.Topics {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			log.Error().Err(err).Str("pattern", pattern).Msg("invalid topics denylist pattern, skipping")
+			continue
+		}
+		v.topics = append(v.topics, re)
 	}
 }
 
 // Domain checks if domain name is valid
 func (v *Validator) Domain(server string) bool {
 	// own server
-	if v.cfg.Get().Matrix.ServerName == server {
+	if v.cfg.Get().IsSelf(server) {
 		return false
 	}
 
@@ -52,48 +81,57 @@ func (v *Validator) Domain(server string) bool {
 	return true
 }
 
-// IsOnline checks if matrix server is online and federatable
-func (v *Validator) IsOnline(ctx context.Context, server string) (string, bool) {
+// IsOnline checks if matrix server is online and federatable.
+// Returns resolved server name (empty if the server is unreachable) and the reason why the server is not online
+func (v *Validator) IsOnline(ctx context.Context, server string) (string, error) {
 	// check if domain is valid
 	if !v.Domain(server) {
-		return "", false
+		return "", errors.New("invalid domain")
 	}
 
 	// check if online
 	name, err := v.matrix.QueryServerName(ctx, server)
-	if name == "" || err != nil {
-		return "", false
+	if err != nil {
+		return "", fmt.Errorf("server name: %w", err)
+	}
+	if name == "" {
+		return "", errors.New("server name: empty")
 	}
 
 	// check if federatable
 	if _, _, err := v.matrix.QueryVersion(ctx, server); err != nil {
-		return name, false
+		return name, fmt.Errorf("version: %w", err)
 	}
 
-	return name, true
+	return name, nil
 }
 
-// IsIndexable check if server is indexable
-func (v *Validator) IsIndexable(ctx context.Context, server string) bool {
+// IsIndexable check if server is indexable, returns the reason why it is not
+func (v *Validator) IsIndexable(ctx context.Context, server string) error {
 	log := zerolog.Ctx(ctx).With().Str("server", server).Logger()
 	if !v.Domain(server) {
 		log.Info().Str("reason", "domain").Msg("not indexable")
-		return false
+		return errors.New("invalid domain")
 	}
 	if v.block.ByServer(server) {
+		metrics.ServersBlocked.Inc()
 		log.Info().Str("reason", "blocklist").Msg("not indexable")
-		return false
+		return errors.New("blocklist")
 	}
 	if !v.robots.Allowed(ctx, server, RobotsTxtPublicRooms) {
 		log.Info().Str("reason", "robots.txt").Msg("not indexable")
-		return false
+		return errors.New("robots.txt: public rooms are disallowed")
+	}
+	if v.matrix.QueryOptOut(ctx, server) {
+		log.Info().Str("reason", "opt-out").Msg("not indexable")
+		return errors.New("opt-out: MSC1929 noindex")
 	}
 	if _, err := v.matrix.QueryPublicRooms(ctx, server, "1", ""); err != nil {
 		log.Info().Err(err).Str("reason", "publicRooms").Msg("not indexable")
-		return false
+		return fmt.Errorf("public rooms: %w", err)
 	}
 	log.Info().Msg("indexable")
-	return true
+	return nil
 }
 
 // isBlockedByTopic checks if room's topic contains "<matrix.server_name from MRS config>: noindex" string
@@ -114,24 +152,50 @@ func (v *Validator) isBlockedByTopic(topic string) bool {
 	return false
 }
 
+// isDeniedByTopic checks if room's name or topic matches any pattern of the topics denylist
+func (v *Validator) isDeniedByTopic(ctx context.Context, room *model.MatrixRoom) bool {
+	for _, re := range v.topics {
+		if !re.MatchString(room.Name) && !re.MatchString(room.Topic) {
+			continue
+		}
+
+		pattern := strings.TrimPrefix(re.String(), "(?i)")
+		metrics.IncRoomsDenied(pattern)
+		v.mu.Lock()
+		v.denied[pattern]++
+		matches := v.denied[pattern]
+		v.mu.Unlock()
+		if matches <= deniedExamples {
+			zerolog.Ctx(ctx).Info().
+				Str("pattern", pattern).
+				Int("matches", matches).
+				Str("id", room.ID).
+				Str("name", room.Name).
+				Str("topic", utils.Truncate(room.Topic, 100)).
+				Msg("room denied by topic")
+		}
+		return true
+	}
+	return false
+}
+
+// IsRoomBlocked checks if room (its ID, alias, or server) or the server publishing it is blocked
+func (v *Validator) IsRoomBlocked(server string, room *model.MatrixRoom) bool {
+	return v.block.ByID(room.ID) || v.block.ByID(room.Alias) || v.block.ByServer(room.Server) || v.block.ByServer(server)
+}
+
 // IsRoomAllowed checks if room is allowed
 func (v *Validator) IsRoomAllowed(ctx context.Context, server string, room *model.MatrixRoom) bool {
 	if room.ID == "" {
 		return false
 	}
-	if v.block.ByID(room.ID) {
+	if v.IsRoomBlocked(server, room) {
 		return false
 	}
-	if v.block.ByID(room.Alias) {
-		return false
-	}
-	if v.block.ByServer(room.Server) {
-		return false
-	}
-	if v.block.ByServer(server) {
+	if v.isBlockedByTopic(room.Topic) {
 		return false
 	}
-	if v.isBlockedByTopic(room.Topic) {
+	if v.isDeniedByTopic(ctx, room) {
 		return false
 	}
 
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/goccy/go-json"
+)
+
+// postWebhook sends payload as JSON to the webhook URL
+func postWebhook(ctx context.Context, webhookURL string, payload any) error {
+	payloadb, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payloadb))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		return fmt.Errorf("backend returned HTTP %d: %s %w", resp.StatusCode, string(body), err)
+	}
+	return nil
+}
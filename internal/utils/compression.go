@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// AcceptEncoding is the list of supported response encodings.
+// brotli is not supported, because it requires an extra dependency
+const AcceptEncoding = "gzip, deflate"
+
+// countingReader counts bytes read from the underlying reader
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+// Read from the underlying reader
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// decompressedBody decompresses response body and logs bytes saved by the compression on close
+type decompressedBody struct {
+	ctx          context.Context
+	uri          string
+	encoding     string
+	body         io.ReadCloser
+	compressed   *countingReader
+	decompressed *countingReader
+	decoder      io.Closer
+}
+
+// Read decompressed data
+func (b *decompressedBody) Read(p []byte) (int, error) {
+	return b.decompressed.Read(p)
+}
+
+// Close decoder and the original body
+func (b *decompressedBody) Close() error {
+	b.decoder.Close()
+	zerolog.Ctx(b.ctx).Debug().
+		Str("req", b.uri).
+		Str("encoding", b.encoding).
+		Int64("compressed", b.compressed.n).
+		Int64("decompressed", b.decompressed.n).
+		Int64("saved", b.decompressed.n-b.compressed.n).
+		Msg("response decompressed")
+	return b.body.Close()
+}
+
+// decompress replaces compressed response body with the decompressed one.
+// Go's http transport does that transparently only when Accept-Encoding is not set explicitly,
+// so it has to be done manually for requests with AcceptEncoding header
+func decompress(ctx context.Context, req *http.Request, resp *http.Response) error {
+	if req.Header.Get("Accept-Encoding") == "" || resp.Uncompressed || resp.Body == nil || resp.Body == http.NoBody {
+		return nil
+	}
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		return nil
+	}
+
+	compressed := &countingReader{Reader: resp.Body}
+	var decoder io.ReadCloser
+	var err error
+	switch encoding {
+	case "gzip", "x-gzip":
+		decoder, err = gzip.NewReader(compressed)
+	case "deflate":
+		decoder, err = zlib.NewReader(compressed)
+	default:
+		zerolog.Ctx(ctx).Warn().Str("req", req.URL.String()).Str("encoding", encoding).Msg("unsupported response encoding")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	resp.Body = &decompressedBody{
+		ctx:          ctx,
+		uri:          req.URL.String(),
+		encoding:     encoding,
+		body:         resp.Body,
+		compressed:   compressed,
+		decompressed: &countingReader{Reader: decoder},
+		decoder:      decoder,
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
@@ -2,7 +2,13 @@ package utils
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/getsentry/sentry-go"
@@ -20,19 +26,128 @@ const (
 	RetryDelay = 5 * time.Second
 )
 
+// httpTransport of outbound requests, using the outbound proxy (see SetProxy)
+var httpTransport = newTransport()
+
 // httpClient with timeout
-var httpClient = &http.Client{Timeout: DefaultTimeout}
+var httpClient = &http.Client{Timeout: DefaultTimeout, Transport: httpTransport}
+
+// newTransport returns a copy of the default transport that uses the outbound proxy
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert // that's ok
+	transport.Proxy = proxyFunc
+	return transport
+}
+
+var (
+	// userAgent of outbound requests, may contain operator contact
+	userAgent = version.UserAgent
+	// fromHeader of outbound requests, operator email
+	fromHeader string
+)
+
+// SetTLS configures TLS verification of outbound requests:
+// caBundle is a path to PEM file with additional CA certificates to trust,
+// insecureSkipVerify disables verification completely.
+// Must be called before any request is performed
+func SetTLS(caBundle string, insecureSkipVerify bool) error {
+	if caBundle == "" && !insecureSkipVerify {
+		return nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecureSkipVerify, //nolint:gosec // opt-in, intended for testing only
+	}
+	if caBundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(caBundle)
+		if err != nil {
+			return err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", caBundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	httpTransport.TLSClientConfig = tlsConfig
+	return nil
+}
 
-// Get performs HTTP GET request with timeout, User-Agent, and retrier
+// SetContact adds operator contact to the User-Agent and sets From header of outbound requests
+func SetContact(contact, from string) {
+	userAgent = version.UserAgent
+	if contact != "" {
+		userAgent += " (+" + contact + ")"
+	}
+	fromHeader = from
+}
+
+// httpSemaphore limits in-flight outbound requests, nil = unlimited
+var httpSemaphore chan struct{}
+
+// SetMaxConcurrentRequests sets the max number of in-flight outbound http requests,
+// a request is in-flight until its response body is closed. 0 means unlimited.
+// Must be called before any request is performed
+func SetMaxConcurrentRequests(limit int) {
+	if limit <= 0 {
+		httpSemaphore = nil
+		return
+	}
+	httpSemaphore = make(chan struct{}, limit)
+}
+
+// releaseBody releases semaphore slot when response body is closed
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+// Close response body and release semaphore slot
+func (b *releaseBody) Close() error {
+	defer b.once.Do(b.release)
+	return b.ReadCloser.Close()
+}
+
+// doLimited performs http request, respecting max concurrent requests limit
+func doLimited(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if httpSemaphore == nil {
+		return httpClient.Do(req)
+	}
+
+	select {
+	case httpSemaphore <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release := func() { <-httpSemaphore }
+
+	resp, err := httpClient.Do(req)
+	if err != nil || resp == nil || resp.Body == nil {
+		release()
+		return resp, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// Get performs HTTP GET request with timeout, User-Agent, compression, and retrier
 func Get(ctx context.Context, uri string, maxRetries ...int) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, http.NoBody)
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Accept-Encoding", AcceptEncoding)
 	return Do(req, maxRetries...)
 }
 
-// Do performs HTTP request with timeout, User-Agent, and retrier
+// Do performs HTTP request with timeout, User-Agent, and retrier.
+// If request has Accept-Encoding header, the response body will be decompressed
 func Do(req *http.Request, maxRetries ...int) (*http.Response, error) {
 	// creating a custom http.client transaction if not already present to avoid unlabeled transactions
 	name := req.Method + " " + req.URL.String()
@@ -68,7 +183,10 @@ func Do(req *http.Request, maxRetries ...int) (*http.Response, error) {
 	}()
 
 	req = req.WithContext(ctx)
-	req.Header.Set("User-Agent", version.UserAgent)
+	req.Header.Set("User-Agent", userAgent)
+	if fromHeader != "" {
+		req.Header.Set("From", fromHeader)
+	}
 	// no direct return, to use response and error in defer
 	var retries int
 	if len(maxRetries) > 0 {
@@ -77,6 +195,12 @@ func Do(req *http.Request, maxRetries ...int) (*http.Response, error) {
 		retries = MaxRetries
 	}
 	resp, err = httpRetry(ctx, req, retries)
+	if err == nil && resp != nil {
+		if derr := decompress(ctx, req, resp); derr != nil {
+			resp.Body.Close()
+			resp, err = nil, derr
+		}
+	}
 	return resp, err
 }
 
@@ -84,14 +208,14 @@ func Do(req *http.Request, maxRetries ...int) (*http.Response, error) {
 // that retries only on 5xx status codes
 func httpRetry(ctx context.Context, req *http.Request, retries int, currentRetry ...int) (*http.Response, error) {
 	if retries == 0 {
-		return httpClient.Do(req)
+		return doLimited(ctx, req)
 	}
 
 	retry := 1
 	if len(currentRetry) > 0 {
 		retry = currentRetry[0]
 	}
-	resp, err := httpClient.Do(req)
+	resp, err := doLimited(ctx, req)
 	if err != nil {
 		return resp, err
 	}
@@ -105,6 +229,7 @@ func httpRetry(ctx context.Context, req *http.Request, retries int, currentRetry
 		if retry <= retries {
 			delay := time.Duration(retry) * RetryDelay
 			log.Warn().Str("in", delay.String()).Msg("retrying")
+			resp.Body.Close() // release the connection before waiting
 			time.Sleep(delay)
 			retry++
 			return httpRetry(ctx, req, retries, retry)
@@ -10,13 +10,21 @@ import (
 	zlogsentry "github.com/archdx/zerolog-sentry"
 	"github.com/getsentry/sentry-go"
 	"github.com/rs/zerolog"
+
+	"github.com/etkecc/mrs/internal/version"
 )
 
+// defaultSentrySampleRate is used for both errors and traces, if not configured
+const defaultSentrySampleRate = 0.25
+
 var (
-	loglevel      zerolog.Level
-	sentryDSN     string
-	sentryName    string
-	sentryVersion = func() string {
+	loglevel               zerolog.Level
+	sentryDSN              string
+	sentryName             string
+	sentryEnvironment      string
+	sentrySampleRate       = defaultSentrySampleRate
+	sentryTracesSampleRate = defaultSentrySampleRate
+	sentryVersion          = func() string {
 		if info, ok := debug.ReadBuildInfo(); ok {
 			for _, setting := range info.Settings {
 				if setting.Key == "vcs.revision" {
@@ -48,6 +56,19 @@ func SetSentryDSN(dsn string) {
 	sentryDSN = dsn
 }
 
+// SetSentryOptions sets sentry sample rates (0 = default) and environment
+func SetSentryOptions(sampleRate, tracesSampleRate float64, environment string) {
+	sentrySampleRate = defaultSentrySampleRate
+	if sampleRate > 0 {
+		sentrySampleRate = sampleRate
+	}
+	sentryTracesSampleRate = defaultSentrySampleRate
+	if tracesSampleRate > 0 {
+		sentryTracesSampleRate = tracesSampleRate
+	}
+	sentryEnvironment = environment
+}
+
 // NewContext creates a new context with a logger and sentry hub
 func NewContext(parent ...context.Context) context.Context {
 	ctx := context.Background()
@@ -63,12 +84,17 @@ func NewContext(parent ...context.Context) context.Context {
 	return newLogger(ctx).WithContext(ctx)
 }
 
-// StartSpan starts a new span, and if there is no transaction, it starts a new transaction
+// StartSpan starts a new span, and if there is no transaction, it starts a new transaction.
+// If sentry is not configured, spans are not sampled (no-op)
 func StartSpan(ctx context.Context, operation string) *sentry.Span {
+	options := []sentry.SpanOption{sentry.WithDescription(operation)}
+	if sentryDSN == "" {
+		options = append(options, sentry.WithSpanSampled(sentry.SampledFalse))
+	}
 	if transaction := sentry.TransactionFromContext(ctx); transaction == nil {
-		ctx = sentry.StartTransaction(ctx, operation, sentry.WithDescription(operation)).Context()
+		ctx = sentry.StartTransaction(ctx, operation, options...).Context()
 	}
-	return sentry.StartSpan(ctx, operation, sentry.WithDescription(operation))
+	return sentry.StartSpan(ctx, operation, options...)
 }
 
 func newSentryWriter(ctx context.Context) (io.Writer, error) {
@@ -95,11 +121,15 @@ func newLogger(ctx context.Context) zerolog.Logger {
 }
 
 func getSentryOptions() []zlogsentry.WriterOption {
-	return []zlogsentry.WriterOption{
+	options := []zlogsentry.WriterOption{
 		zlogsentry.WithBreadcrumbs(),
 		zlogsentry.WithTracing(),
-		zlogsentry.WithSampleRate(0.25),
-		zlogsentry.WithTracingSampleRate(0.25),
-		zlogsentry.WithRelease(sentryName + "@" + sentryVersion),
+		zlogsentry.WithSampleRate(sentrySampleRate),
+		zlogsentry.WithTracingSampleRate(sentryTracesSampleRate),
+		zlogsentry.WithRelease(sentryName + "@" + version.Version + "+" + sentryVersion),
+	}
+	if sentryEnvironment != "" {
+		options = append(options, zlogsentry.WithEnvironment(sentryEnvironment))
 	}
+	return options
 }
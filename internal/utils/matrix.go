@@ -1,12 +1,125 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
 	"strings"
 
 	"github.com/goccy/go-json"
 	"github.com/matrix-org/gomatrixserverlib"
+	"golang.org/x/net/idna"
 )
 
+// serverNameRegex matches hostname or IPv6 literal with optional port, ref: https://spec.matrix.org/v1.11/appendices/#server-name
+var serverNameRegex = regexp.MustCompile(`^(?:(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)*[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?|\[[0-9a-fA-F:.]{2,45}\])(?::[0-9]{1,5})?$`)
+
+// mediaIDRegex matches media ID, ref: https://spec.matrix.org/v1.11/client-server-api/#security-considerations-5
+var mediaIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// tldRegex matches single DNS label (IDN labels must be punycode-encoded)
+var tldRegex = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// ErrInvalidRoomID is returned when a string is neither valid room ID nor valid room alias
+var ErrInvalidRoomID = errors.New("invalid room ID or alias")
+
+// IsValidServerName checks if server name is a plausible hostname or IP literal (with optional port)
+func IsValidServerName(name string) bool {
+	if name == "" || len(name) > 255 {
+		return false
+	}
+	if !serverNameRegex.MatchString(name) {
+		return false
+	}
+
+	host := name
+	if h, _, err := net.SplitHostPort(name); err == nil {
+		host = h
+	}
+	if strings.HasPrefix(host, "[") {
+		return net.ParseIP(strings.Trim(host, "[]")) != nil
+	}
+	return true
+}
+
+// ParseMXC splits mxc://server/mediaID URI into server name and media ID,
+// ok is false if the URI is malformed
+func ParseMXC(uri string) (server, mediaID string, ok bool) {
+	rest, found := strings.CutPrefix(uri, "mxc://")
+	if !found {
+		return "", "", false
+	}
+	server, mediaID, found = strings.Cut(rest, "/")
+	if !found || !IsValidServerName(server) || !mediaIDRegex.MatchString(mediaID) {
+		return "", "", false
+	}
+	return server, mediaID, true
+}
+
+// NormalizeServer converts server name to its canonical form:
+// lowercase, without trailing dots, internationalized domain names converted to punycode, port is kept as is
+func NormalizeServer(name string) string {
+	host, port, err := net.SplitHostPort(strings.TrimSpace(name))
+	if err != nil { // no port
+		host, port = strings.TrimSpace(name), ""
+	}
+	host = strings.TrimRight(host, ".")
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+		host = ascii
+	}
+	host = strings.ToLower(host)
+	if port == "" {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// NormalizeTLD validates top-level domain (with or without leading dot, IDN TLDs are converted to punycode)
+// and returns it in the form used by ServerTLD
+func NormalizeTLD(tld string) (string, error) {
+	normalized := NormalizeServer(strings.TrimPrefix(strings.TrimSpace(tld), "."))
+	if !tldRegex.MatchString(normalized) {
+		return "", fmt.Errorf("invalid TLD %q", tld)
+	}
+	return normalized, nil
+}
+
+// ServerTLD returns top-level domain of the (normalized) server name, ignoring port.
+// IP literals and single-label names have no TLD, so empty string is returned
+func ServerTLD(name string) string {
+	host := name
+	if h, _, err := net.SplitHostPort(name); err == nil {
+		host = h
+	}
+	if net.ParseIP(strings.Trim(host, "[]")) != nil {
+		return ""
+	}
+	idx := strings.LastIndex(host, ".")
+	if idx == -1 {
+		return ""
+	}
+	return host[idx+1:]
+}
+
+// NormalizeRoomID validates room ID (!opaque:server) or room alias (#local:server)
+// and returns it with the normalized (lowercased) server part
+func NormalizeRoomID(id string) (string, error) {
+	id = strings.TrimSpace(id)
+	if !strings.HasPrefix(id, "!") && !strings.HasPrefix(id, "#") {
+		return "", fmt.Errorf("%w %q: must start with ! or #", ErrInvalidRoomID, id)
+	}
+	idx := strings.Index(id, ":")
+	if idx < 2 {
+		return "", fmt.Errorf("%w %q: must have non-empty local part and server", ErrInvalidRoomID, id)
+	}
+	server := NormalizeServer(id[idx+1:])
+	if !IsValidServerName(server) {
+		return "", fmt.Errorf("%w %q: invalid server", ErrInvalidRoomID, id)
+	}
+	return id[:idx+1] + server, nil
+}
+
 // Server returns server name from the matrix ID (room id/alias, user ID, etc)
 func ServerFrom(matrixID string) string {
 	idx := strings.LastIndex(matrixID, ":")
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeServer(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"example.com", "example.com"},
+		{" Example.COM. ", "example.com"},
+		{"Example.com:8448", "example.com:8448"},
+		{"Bücher.example", "xn--bcher-kva.example"},
+		{"bücher.example:8448", "xn--bcher-kva.example:8448"},
+		{"Example.com.:8448", "example.com:8448"},
+		{"xn--bcher-kva.example", "xn--bcher-kva.example"},
+		{"[::1]:8448", "[::1]:8448"},
+		{"127.0.0.1", "127.0.0.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if normalized := NormalizeServer(test.name); normalized != test.expected {
+				t.Errorf("got %q, want %q", normalized, test.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizeRoomID(t *testing.T) {
+	tests := []struct {
+		id       string
+		expected string
+	}{
+		{"!abc:example.com", "!abc:example.com"},
+		{" !AbC:Example.COM ", "!AbC:example.com"},
+		{"#General:Example.com:8448", "#General:example.com:8448"},
+		{"#general:bücher.example", "#general:xn--bcher-kva.example"},
+	}
+	for _, test := range tests {
+		t.Run(test.id, func(t *testing.T) {
+			normalized, err := NormalizeRoomID(test.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if normalized != test.expected {
+				t.Errorf("got %q, want %q", normalized, test.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizeRoomID_Invalid(t *testing.T) {
+	for _, id := range []string{"", "abc:example.com", "@user:example.com", "!abc", "!:example.com", "#general:", "!abc:exa mple.com"} {
+		t.Run(id, func(t *testing.T) {
+			if _, err := NormalizeRoomID(id); !errors.Is(err, ErrInvalidRoomID) {
+				t.Errorf("got %v, want %v", err, ErrInvalidRoomID)
+			}
+		})
+	}
+}
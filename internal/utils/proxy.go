@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/etkecc/mrs/internal/metrics"
+)
+
+// ProxyCheckTimeout is the timeout of a single proxy health check
+const ProxyCheckTimeout = 10 * time.Second
+
+// Proxy names, used in logs and metrics instead of URLs that may contain credentials
+const (
+	ProxyPrimary  = "primary"
+	ProxyFallback = "fallback"
+)
+
+// outboundProxy is the state of the outbound proxy, see SetProxy
+var outboundProxy struct {
+	sync.RWMutex
+	proxies []*url.URL // reachable in order of preference: primary, fallback
+	names   []string   // names of the proxies, same order
+	direct  bool       // fall back to direct requests when no proxy is reachable
+	active  *url.URL   // the proxy of outbound requests, nil = direct
+}
+
+// SetProxy configures the outbound proxy of federation requests:
+// primary is used by default, fallback (if set) is used when the primary one is unreachable,
+// and direct enables direct requests when no proxy is reachable.
+// Without any proxy configured the standard HTTP_PROXY/HTTPS_PROXY environment variables are respected.
+// Must be called before any request is performed
+func SetProxy(primary, fallback string, direct bool) error {
+	outboundProxy.Lock()
+	defer outboundProxy.Unlock()
+
+	outboundProxy.proxies = nil
+	outboundProxy.names = nil
+	outboundProxy.active = nil
+	outboundProxy.direct = direct
+	for i, uri := range []string{primary, fallback} {
+		if uri == "" {
+			continue
+		}
+		parsed, err := url.Parse(uri)
+		if err != nil {
+			return err
+		}
+		if parsed.Host == "" {
+			return fmt.Errorf("proxy %q has no host", uri)
+		}
+		outboundProxy.proxies = append(outboundProxy.proxies, parsed)
+		outboundProxy.names = append(outboundProxy.names, []string{ProxyPrimary, ProxyFallback}[i])
+	}
+	if len(outboundProxy.proxies) > 0 {
+		outboundProxy.active = outboundProxy.proxies[0]
+	}
+	return nil
+}
+
+// HasProxy returns true if outbound proxy is configured
+func HasProxy() bool {
+	outboundProxy.RLock()
+	defer outboundProxy.RUnlock()
+	return len(outboundProxy.proxies) > 0
+}
+
+// ActiveProxy returns the name of the proxy used by outbound requests, empty string = direct requests
+func ActiveProxy() string {
+	outboundProxy.RLock()
+	defer outboundProxy.RUnlock()
+	return activeProxyName()
+}
+
+// activeProxyName returns the name of the active proxy, must be called under lock
+func activeProxyName() string {
+	for i, proxy := range outboundProxy.proxies {
+		if proxy == outboundProxy.active {
+			return outboundProxy.names[i]
+		}
+	}
+	return ""
+}
+
+// proxyFunc is the http.Transport.Proxy of outbound requests, returning the active proxy
+func proxyFunc(req *http.Request) (*url.URL, error) {
+	outboundProxy.RLock()
+	defer outboundProxy.RUnlock()
+	if len(outboundProxy.proxies) == 0 {
+		return http.ProxyFromEnvironment(req)
+	}
+	return outboundProxy.active, nil
+}
+
+// CheckProxy checks reachability of the configured proxies
+// and switches outbound requests to the first reachable one (or to direct requests, if enabled).
+// When no proxy is reachable and direct requests are disabled, the primary proxy is kept,
+// so federation failures are reported as "proxy down" instead of "all servers are dead"
+func CheckProxy(ctx context.Context) {
+	outboundProxy.RLock()
+	proxies := outboundProxy.proxies
+	names := outboundProxy.names
+	outboundProxy.RUnlock()
+	if len(proxies) == 0 {
+		return
+	}
+
+	log := zerolog.Ctx(ctx)
+	var active *url.URL
+	for i, proxy := range proxies {
+		err := dialProxy(ctx, proxy)
+		metrics.SetProxyUp(names[i], err == nil)
+		if err != nil {
+			log.Error().Err(err).Str("proxy", names[i]).Msg("outbound proxy is unreachable")
+			continue
+		}
+		if active == nil {
+			active = proxy
+		}
+	}
+
+	outboundProxy.Lock()
+	defer outboundProxy.Unlock()
+	previous := activeProxyName()
+	switch {
+	case active != nil:
+	case outboundProxy.direct:
+		log.Warn().Msg("no outbound proxy is reachable, falling back to direct requests")
+	default:
+		log.Error().Msg("no outbound proxy is reachable, federation requests will fail")
+		active = proxies[0]
+	}
+	outboundProxy.active = active
+	metrics.SetProxyDirect(active == nil)
+	if current := activeProxyName(); current != previous {
+		log.Warn().Str("from", proxyLogName(previous)).Str("to", proxyLogName(current)).Msg("outbound requests switched")
+		httpTransport.CloseIdleConnections()
+	}
+}
+
+// proxyLogName returns human-readable name of the proxy
+func proxyLogName(name string) string {
+	if name == "" {
+		return "direct"
+	}
+	return name
+}
+
+// dialProxy checks if the proxy accepts TCP connections
+func dialProxy(ctx context.Context, proxy *url.URL) error {
+	port := proxy.Port()
+	if port == "" {
+		switch proxy.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ProxyCheckTimeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", net.JoinHostPort(proxy.Hostname(), port))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+)
+
+// listen starts a TCP listener acting as a reachable proxy
+func listen(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return "http://" + ln.Addr().String()
+}
+
+// unreachable returns address of a closed TCP port
+func unreachable(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return "http://" + addr
+}
+
+func activeProxyURL(t *testing.T) string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, "https://example.com", http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy, err := proxyFunc(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxy == nil {
+		return ""
+	}
+	return proxy.String()
+}
+
+func TestCheckProxy(t *testing.T) {
+	t.Cleanup(func() { SetProxy("", "", false) }) //nolint:errcheck // reset
+	up := listen(t)
+	down := unreachable(t)
+
+	tests := []struct {
+		name     string
+		primary  string
+		fallback string
+		direct   bool
+		active   string
+		expected string
+	}{
+		{"primary up", up, down, true, ProxyPrimary, up},
+		{"fallback", down, up, false, ProxyFallback, up},
+		{"direct", down, down, true, "", ""},
+		{"all down", down, "", false, ProxyPrimary, down},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := SetProxy(test.primary, test.fallback, test.direct); err != nil {
+				t.Fatal(err)
+			}
+			CheckProxy(context.Background())
+			if active := ActiveProxy(); active != test.active {
+				t.Errorf("active proxy: got %q, want %q", active, test.active)
+			}
+			if proxy := activeProxyURL(t); proxy != test.expected {
+				t.Errorf("proxy of requests: got %q, want %q", proxy, test.expected)
+			}
+		})
+	}
+}
+
+func TestSetProxy_Invalid(t *testing.T) {
+	t.Cleanup(func() { SetProxy("", "", false) }) //nolint:errcheck // reset
+	if err := SetProxy("proxy:3128", "", false); err == nil {
+		t.Error("expected error for proxy without scheme")
+	}
+	if err := SetProxy("", "", false); err != nil {
+		t.Fatal(err)
+	}
+	if HasProxy() {
+		t.Error("expected no proxy")
+	}
+}
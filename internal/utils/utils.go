@@ -3,11 +3,17 @@ package utils
 import (
 	"bytes"
 	"crypto/subtle"
+	"html"
+	"regexp"
 	"strconv"
 	"strings"
 	"text/template"
+	"unicode"
 )
 
+// htmlTagRegex matches HTML tags and comments, e.g. <b>, </a>, <br/>, <!-- comment -->
+var htmlTagRegex = regexp.MustCompile(`(?s)<!--.*?-->|</?[a-zA-Z][^<>]*>`)
+
 // MapKeys returns keys of the map
 func MapKeys[K comparable, V any](datamap map[K]V) []K {
 	keys := make([]K, 0, len(datamap))
@@ -75,6 +81,27 @@ func Truncate(s string, length int) string {
 	return out + "..."
 }
 
+// StripControl removes control characters (except new lines and tabs) from the string
+func StripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// StripHTML removes HTML tags from the string and unescapes HTML entities, e.g. "<b>Rust</b> &amp; Go" -> "Rust & Go"
+func StripHTML(s string) string {
+	if !strings.ContainsAny(s, "<&") {
+		return s
+	}
+	return html.UnescapeString(htmlTagRegex.ReplaceAllString(s, ""))
+}
+
 // Uniq removes duplicates from slice
 func Uniq[T comparable](slice []T) []T {
 	uniq := map[T]struct{}{}
@@ -106,7 +133,8 @@ func StringToInt(value string, optionalDefaultValue ...int) int {
 	return vInt
 }
 
-// StringToSlice converts comma-separated string to slice with optional default value
+// StringToSlice converts comma-separated string to slice of trimmed values,
+// empty (or whitespace-only) string is replaced with the optional default value
 func StringToSlice(value string, optionalDefaultValue ...string) []string {
 	var defaultValue string
 	if len(optionalDefaultValue) > 0 {
@@ -114,10 +142,14 @@ func StringToSlice(value string, optionalDefaultValue ...string) []string {
 	}
 
 	value = strings.TrimSpace(value)
-	if idx := strings.Index(value, ","); idx == -1 {
+	if value == "" {
 		value = defaultValue
 	}
-	return strings.Split(value, ",")
+	parts := strings.Split(value, ",")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	return parts
 }
 
 // SliceToString converts slice of strings into single string (using strings.Join) with optional hook
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStringToSlice(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		defaultValue string
+		expected     []string
+	}{
+		{"single value", "recent", "-_score", []string{"recent"}},
+		{"multiple values", "-members, name", "-_score", []string{"-members", "name"}},
+		{"empty", " ", "-_score", []string{"-_score"}},
+		{"empty default", "", "", []string{""}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := StringToSlice(test.value, test.defaultValue); !slices.Equal(got, test.expected) {
+				t.Errorf("got %q, want %q", got, test.expected)
+			}
+		})
+	}
+}
@@ -1,5 +1,10 @@
 package version
 
+import (
+	"runtime"
+	"runtime/debug"
+)
+
 const (
 	// Version of MRS
 	Version = "v0.0.0"
@@ -15,3 +20,33 @@ var (
 	// Server header returned by MRS
 	Server = Name + "/" + Version
 )
+
+// BuildInfo describes the running build
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Info returns the running build metadata, commit and build date are available only
+// if the binary was built with VCS stamping (default for go build within a git repo)
+func Info() BuildInfo {
+	info := BuildInfo{
+		Version:   Version,
+		GoVersion: runtime.Version(),
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Commit = setting.Value
+		case "vcs.time":
+			info.BuildDate = setting.Value
+		}
+	}
+	return info
+}